@@ -6,10 +6,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/gallery"
+	"github.com/vladwithcode/sibra-site/internal/jobs"
+	"github.com/vladwithcode/sibra-site/internal/leads"
 	"github.com/vladwithcode/sibra-site/internal/routes"
+	"github.com/vladwithcode/sibra-site/internal/sla"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+	"github.com/vladwithcode/sibra-site/internal/tiles"
 )
 
 func main() {
@@ -26,6 +33,58 @@ func main() {
 		panic(fmt.Sprintf("DB is not available: %v", err))
 	}
 
+	if _, err = storage.Open(); err != nil {
+		panic(fmt.Sprintf("Error while opening the media storage: %v", err))
+	}
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	go jobs.Every(jobsCtx, "market-snapshots", 24*time.Hour, func(ctx context.Context) error {
+		_, err := db.ComputeMarketSnapshots(ctx, time.Now())
+		return err
+	})
+	// The hood tiles are drawn with the prices in effect, the ones dated in
+	// the future change them when they take effect
+	go jobs.Every(jobsCtx, "hood-prices", time.Hour, func(ctx context.Context) error {
+		n, err := db.ApplyHoodPrices(ctx)
+		if n > 0 {
+			tiles.Invalidate(tiles.LayerHoods)
+		}
+		return err
+	})
+	go jobs.Every(jobsCtx, "media-check", 24*time.Hour, func(ctx context.Context) error {
+		report, err := gallery.Check(ctx, gallery.CheckOptions{
+			Quarantine: os.Getenv(gallery.EnvVarQuarantine) == "true",
+			PurgeAfter: gallery.DefaultPurgeAfter,
+			MinAge:     gallery.DefaultMinAge,
+		})
+		if report != nil {
+			log.Printf("media-check: %v\n", report)
+		}
+		return err
+	})
+	if after := leads.ReassignAfter(); after > 0 {
+		go jobs.Every(jobsCtx, "lead-reassign", leads.ReassignInterval, func(ctx context.Context) error {
+			n, err := leads.Reassign(ctx, after, time.Now())
+			if n > 0 {
+				log.Printf("lead-reassign: %d requests reassigned\n", n)
+			}
+			return err
+		})
+	}
+	go jobs.Every(jobsCtx, "rate-limits", time.Hour, func(ctx context.Context) error {
+		_, err := db.DeleteExpiredRateLimits(ctx, time.Now())
+		return err
+	})
+	go jobs.Every(jobsCtx, "lead-sla", sla.CheckInterval, func(ctx context.Context) error {
+		reminded, escalated, err := sla.Check(ctx, time.Now())
+		if reminded > 0 || escalated > 0 {
+			log.Printf("lead-sla: %d reminded, %d escalated\n", reminded, escalated)
+		}
+		return err
+	})
+
 	portStr := os.Getenv("PORT")
 	if portStr == "" {
 		portStr = "8080"
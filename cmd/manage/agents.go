@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+func runAgents(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: list, active")
+	}
+
+	switch args[0] {
+	case "list":
+		return listAgents()
+	case "active":
+		return setAgentActive(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func listAgents() error {
+	agents, err := db.FindAgents(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, agent := range agents {
+		flags := ""
+		if agent.GoldenBoy {
+			flags += " golden"
+		}
+		if !agent.Active {
+			flags += " inactivo"
+		}
+		fmt.Printf("%-24s %-8s %s %s%s\n", agent.Username, agent.Role, agent.Name, agent.Lastname, flags)
+	}
+
+	return nil
+}
+
+func setAgentActive(args []string) error {
+	fs := flag.NewFlagSet("agents active", flag.ExitOnError)
+	username := fs.String("user", "", "username of the agent")
+	set := fs.String("set", "", "on to receive leads, off to stop receiving them")
+	fs.Parse(args)
+
+	if *username == "" {
+		return errors.New("missing -user")
+	}
+
+	var active bool
+	switch *set {
+	case "on":
+		active = true
+	case "off":
+		active = false
+	default:
+		return errors.New("-set must be on or off")
+	}
+
+	if err := db.SetUserActive(context.Background(), *username, active); err != nil {
+		return err
+	}
+
+	log.Printf("Agente %s actualizado (activo: %v)\n", *username, active)
+	return nil
+}
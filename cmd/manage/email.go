@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/ics"
+	"github.com/vladwithcode/sibra-site/internal/templates/emails"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func runEmail(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: test")
+	}
+
+	switch args[0] {
+	case "test":
+		return testEmail(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// testEmail sends a sample of the visit invite, with its HTML and text
+// bodies and the calendar attached, to check the SMTP settings. Pointed to
+// a local sink (SMTP_HOST=localhost SMTP_PORT=1025 SMTP_TLS=none) it
+// shows how the emails look without sending them.
+func testEmail(args []string) error {
+	fs := flag.NewFlagSet("email test", flag.ExitOnError)
+	to := fs.String("to", "", "address to send the test email to")
+	fs.Parse(args)
+
+	if *to == "" {
+		return errors.New("missing -to")
+	}
+
+	now := time.Now()
+	req := db.NewRequest(db.RequestTypeQuote)
+	req.Name = "Cliente de prueba"
+	req.PhoneDisplay = "618 123 4567"
+	req.Email = *to
+	req.PropertyAddress = "Calle de prueba 123, Centro"
+	req.AgentName = "Asesor de prueba"
+	req.ScheduledDate = now.Add(24 * time.Hour).Truncate(time.Hour)
+	req.UpdatedAt = now
+
+	cal := visits.ClientCalendar(req)
+	var invite bytes.Buffer
+	if _, err := cal.WriteTo(&invite); err != nil {
+		return err
+	}
+
+	msg := &email.Message{
+		To:      []string{*to},
+		Subject: "Prueba de correo | Sibra Durango",
+		Attachments: []email.Attachment{{
+			Filename:    "visita.ics",
+			ContentType: ics.ContentType + "; method=" + ics.MethodPublish,
+			Data:        invite.Bytes(),
+		}},
+	}
+	ctx := context.Background()
+	err := msg.Render(ctx, emails.ClientVisitConfirmed(req), emails.ClientVisitConfirmedText(req))
+	if err != nil {
+		return err
+	}
+
+	if err = email.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	log.Printf("Correo de prueba enviado a %s\n", *to)
+	return nil
+}
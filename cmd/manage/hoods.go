@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/geo"
+	"github.com/vladwithcode/sibra-site/internal/tiles"
+)
+
+func runHoods(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: import")
+	}
+
+	switch args[0] {
+	case "import":
+		return importHoods(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func importHoods(args []string) error {
+	fs := flag.NewFlagSet("hoods import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only parse and validate the file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("expected exactly one file")
+	}
+
+	fileName := fs.Arg(0)
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	features, err := geo.ReadFeatures(fileName, file)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range features {
+		name := f.Name
+		if name == "" {
+			name = "(sin nombre, se omitirá)"
+		}
+		log.Printf("%s: %d polígono(s)\n", name, len(f.Boundaries))
+	}
+
+	if *dryRun {
+		log.Printf("%d colonias válidas\n", len(features))
+		return nil
+	}
+
+	defer connect()()
+
+	created, updated, skipped, err := db.ImportHoods(context.Background(), features)
+	if err != nil {
+		return err
+	}
+
+	tiles.Invalidate(tiles.LayerHoods)
+	log.Printf("Creadas: %d, actualizadas: %d, omitidas: %d\n", created, updated, skipped)
+	return nil
+}
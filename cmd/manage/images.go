@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/gallery"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+)
+
+func runImages(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: backfill, watermark, hash")
+	}
+
+	switch args[0] {
+	case "backfill":
+		return backfillImages(args[1:])
+	case "watermark":
+		return watermarkImages(args[1:])
+	case "hash":
+		return hashImages(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// backfillImages generates the variants of the pictures uploaded before
+// the image pipeline existed
+func backfillImages(args []string) error {
+	fs := flag.NewFlagSet("images backfill", flag.ExitOnError)
+	propertyId := fs.String("property", "", "only process the property with this id")
+	force := fs.Bool("force", false, "regenerate the pictures that already have variants")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	filter := &db.PropertyFilter{}
+	if *propertyId != "" {
+		filter.Ids = &[]string{*propertyId}
+	}
+
+	properties, err := db.GetProperties(ctx, filter, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if !imaging.WebPAvailable() {
+		log.Println("cwebp no está instalado, solo se generarán variantes JPEG")
+	}
+
+	processed, failed := 0, 0
+	for _, prop := range properties {
+		if prop.ImgVariants == nil {
+			prop.ImgVariants = map[string]imaging.Variants{}
+		}
+
+		changed := false
+		for _, pic := range prop.AllImages() {
+			if _, ok := prop.ImgVariants[pic]; ok && !*force {
+				continue
+			}
+
+			variants, err := gallery.Render(ctx, prop, pic)
+			if err != nil {
+				log.Printf("%s/%s: %v\n", prop.Id, pic, err)
+				failed++
+				continue
+			}
+
+			prop.ImgVariants[pic] = *variants
+			changed = true
+			processed++
+		}
+
+		if changed {
+			if err = db.UpdatePropertyImgVariants(ctx, prop.Id, prop.ImgVariants); err != nil {
+				return fmt.Errorf("%s: %w", prop.Id, err)
+			}
+		}
+	}
+
+	log.Printf("Procesadas: %d, con error: %d\n", processed, failed)
+	return nil
+}
+
+// watermarkImages renders again the pictures of the properties, optionally
+// toggling their watermark first
+func watermarkImages(args []string) error {
+	fs := flag.NewFlagSet("images watermark", flag.ExitOnError)
+	propertyId := fs.String("property", "", "only process the property with this id")
+	set := fs.String("set", "", "enable (on) or disable (off) the watermark before rendering")
+	fs.Parse(args)
+
+	var watermark *bool
+	switch *set {
+	case "":
+	case "on", "off":
+		enabled := *set == "on"
+		watermark = &enabled
+	default:
+		return fmt.Errorf("invalid -set %q, expected on or off", *set)
+	}
+
+	ctx := context.Background()
+	filter := &db.PropertyFilter{}
+	if *propertyId != "" {
+		filter.Ids = &[]string{*propertyId}
+	}
+
+	properties, err := db.GetProperties(ctx, filter, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if watermark != nil && *watermark {
+		if _, err = imaging.DefaultWatermark(); err != nil {
+			return err
+		}
+	}
+
+	processed, failed := 0, 0
+	for _, prop := range properties {
+		if watermark != nil && prop.Watermark != *watermark {
+			if err = db.UpdatePropertyWatermark(ctx, prop.Id, *watermark); err != nil {
+				return fmt.Errorf("%s: %w", prop.Id, err)
+			}
+			prop.Watermark = *watermark
+		}
+
+		if prop.ImgVariants == nil {
+			prop.ImgVariants = map[string]imaging.Variants{}
+		}
+
+		pics := prop.AllImages()
+		for _, pic := range pics {
+			variants, err := gallery.Render(ctx, prop, pic)
+			if err != nil {
+				log.Printf("%s/%s: %v\n", prop.Id, pic, err)
+				failed++
+				continue
+			}
+
+			prop.ImgVariants[pic] = *variants
+			processed++
+		}
+
+		if len(pics) > 0 {
+			if err = db.UpdatePropertyImgVariants(ctx, prop.Id, prop.ImgVariants); err != nil {
+				return fmt.Errorf("%s: %w", prop.Id, err)
+			}
+		}
+	}
+
+	log.Printf("Procesadas: %d, con error: %d\n", processed, failed)
+	return nil
+}
+
+// hashImages computes the perceptual hashes of the pictures uploaded before
+// the duplicate detection existed
+func hashImages(args []string) error {
+	fs := flag.NewFlagSet("images hash", flag.ExitOnError)
+	propertyId := fs.String("property", "", "only process the property with this id")
+	force := fs.Bool("force", false, "compute again the hashes already stored")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	filter := &db.PropertyFilter{}
+	if *propertyId != "" {
+		filter.Ids = &[]string{*propertyId}
+	}
+
+	properties, err := db.GetProperties(ctx, filter, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	processed, failed := 0, 0
+	for _, prop := range properties {
+		images, err := db.FindPropertyImages(ctx, prop.Id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prop.Id, err)
+		}
+
+		hashes := map[string]uint64{}
+		for _, img := range images {
+			if img.PHash != nil && !*force {
+				continue
+			}
+
+			decoded, err := gallery.LoadOriginal(ctx, prop, img.FileName)
+			if err != nil {
+				log.Printf("%s/%s: %v\n", prop.Id, img.FileName, err)
+				failed++
+				continue
+			}
+
+			hashes[img.FileName] = imaging.DHash(decoded)
+			processed++
+		}
+
+		if err = db.UpdatePropertyImageHashes(ctx, prop.Id, hashes); err != nil {
+			return fmt.Errorf("%s: %w", prop.Id, err)
+		}
+	}
+
+	log.Printf("Procesadas: %d, con error: %d\n", processed, failed)
+
+	matches, err := db.FindDuplicateImages(ctx, "", db.DuplicateMaxDistance)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		log.Printf("Posible duplicado: %s (%s) y %s (%s), %d bits\n", m.PropertyAddress, m.FileName, m.OtherAddress, m.OtherFileName, m.Distance)
+	}
+
+	return nil
+}
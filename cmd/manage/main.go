@@ -0,0 +1,109 @@
+// Command manage groups the maintenance tasks of the site.
+//
+// Usage:
+//
+//	go run ./cmd/manage <command> [subcommand] [flags]
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/joho/godotenv"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+	// The command calls connect itself, only when it needs the database
+	// and the storage
+	connects bool
+}
+
+var commands = map[string]command{
+	"agents": {
+		usage: "agents list\n  agents active -user username -set on|off",
+		run:   runAgents,
+	},
+	"email": {
+		usage: "email test -to address",
+		run:   runEmail,
+	},
+	"hoods": {
+		usage:    "hoods import [-dry-run] <file.geojson|file.kml>",
+		run:      runHoods,
+		connects: true,
+	},
+	"images": {
+		usage: "images backfill [-property id] [-force]\n  images watermark [-property id] [-set on|off]\n  images hash [-property id] [-force]",
+		run:   runImages,
+	},
+	"media": {
+		usage: "media check [-quarantine] [-purge-after 720h] [-min-age 1h] [-v]",
+		run:   runMedia,
+	},
+	"market": {
+		usage: "market snapshot [-month YYYY-MM] [-backfill N]",
+		run:   runMarket,
+	},
+	"phones": {
+		usage: "phones normalize [-dry-run]",
+		run:   runPhones,
+	},
+	"pois": {
+		usage: "pois import [-category c] [-source s] [-replace] [-dry-run] <file.geojson|file.csv>",
+		run:   runPois,
+	},
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: manage <command> [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// connect opens the database and the media storage, it exits when they are
+// not available. The returned func closes the database.
+func connect() func() {
+	dbPool, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Error while connecting to DB: %v\n", err)
+	}
+
+	if _, err = storage.Open(); err != nil {
+		dbPool.Close()
+		log.Fatalf("Error while opening the media storage: %v\n", err)
+	}
+
+	return dbPool.Close
+}
+
+func main() {
+	godotenv.Overload(".env")
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+
+	if !cmd.connects {
+		defer connect()()
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
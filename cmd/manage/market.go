@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+func runMarket(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: snapshot")
+	}
+
+	switch args[0] {
+	case "snapshot":
+		return snapshotMarket(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func snapshotMarket(args []string) error {
+	fs := flag.NewFlagSet("market snapshot", flag.ExitOnError)
+	month := fs.String("month", "", "month to compute as YYYY-MM, defaults to the current month")
+	backfill := fs.Int("backfill", 0, "also compute this many months before -month")
+	fs.Parse(args)
+
+	period := db.MonthStart(time.Now())
+	if *month != "" {
+		t, err := time.Parse("2006-01", *month)
+		if err != nil {
+			return fmt.Errorf("invalid month %q: %w", *month, err)
+		}
+		period = t
+	}
+
+	if *backfill < 0 {
+		return errors.New("-backfill must not be negative")
+	}
+
+	for i := *backfill; i >= 0; i-- {
+		p := period.AddDate(0, -i, 0)
+		count, err := db.ComputeMarketSnapshots(context.Background(), p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Format("2006-01"), err)
+		}
+		log.Printf("%s: %d snapshots\n", p.Format("2006-01"), count)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/vladwithcode/sibra-site/internal/gallery"
+)
+
+func runMedia(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: check")
+	}
+
+	switch args[0] {
+	case "check":
+		return checkMedia(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// checkMedia reports the stored pictures that don't match the database,
+// optionally moving the orphans to the quarantine
+func checkMedia(args []string) error {
+	fs := flag.NewFlagSet("media check", flag.ExitOnError)
+	quarantine := fs.Bool("quarantine", false, "move the orphan files to the quarantine of the private store")
+	purgeAfter := fs.Duration("purge-after", 0, "delete the quarantined files older than this, e.g. 720h")
+	minAge := fs.Duration("min-age", gallery.DefaultMinAge, "ignore the files written more recently than this")
+	verbose := fs.Bool("v", false, "list every orphan and missing file")
+	fs.Parse(args)
+
+	report, err := gallery.Check(context.Background(), gallery.CheckOptions{
+		Quarantine: *quarantine,
+		PurgeAfter: *purgeAfter,
+		MinAge:     *minAge,
+	})
+	if report == nil {
+		return err
+	}
+
+	slices.Sort(report.OrphanDirs)
+	for _, id := range report.OrphanDirs {
+		log.Printf("Directorio huérfano: propiedad %s\n", id)
+	}
+	if *verbose {
+		for _, orphan := range report.OrphanFiles {
+			log.Printf("Archivo huérfano (%s): %s\n", orphan.Store, orphan.Key)
+		}
+		slices.Sort(report.Missing)
+		for _, key := range report.Missing {
+			log.Printf("Archivo faltante: %s\n", key)
+		}
+	}
+
+	log.Println(report)
+	return err
+}
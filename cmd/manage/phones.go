@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/phone"
+)
+
+func runPhones(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: normalize")
+	}
+
+	switch args[0] {
+	case "normalize":
+		return normalizePhones(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// normalizePhones rewrites the phones of the requests and users stored as
+// they were typed into their E.164 and display forms. The phones that
+// can't be parsed are left as they are and listed to be fixed by hand.
+func normalizePhones(args []string) error {
+	fs := flag.NewFlagSet("phones normalize", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list the changes")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	tables := []struct {
+		name string
+		find func(context.Context) ([]*db.StoredPhone, error)
+		set  func(context.Context, *db.StoredPhone) error
+	}{
+		{"solicitudes", db.FindRequestPhones, db.SetRequestPhone},
+		{"usuarios", db.FindUserPhones, db.SetUserPhone},
+	}
+
+	for _, table := range tables {
+		stored, err := table.find(ctx)
+		if err != nil {
+			return err
+		}
+
+		updated, invalid := 0, 0
+		for _, p := range stored {
+			e164, display, err := phone.Normalize(p.Phone)
+			if err != nil {
+				log.Printf("%s %s: teléfono inválido %q\n", table.name, p.Id, p.Phone)
+				invalid++
+				continue
+			}
+			if e164 == p.Phone && display == p.Display {
+				continue
+			}
+
+			log.Printf("%s %s: %q -> %s (%s)\n", table.name, p.Id, p.Phone, e164, display)
+			updated++
+			if *dryRun {
+				continue
+			}
+
+			p.Phone, p.Display = e164, display
+			if err := table.set(ctx, p); err != nil {
+				return fmt.Errorf("%s %s: %w", table.name, p.Id, err)
+			}
+		}
+
+		log.Printf("%s: %d actualizados, %d inválidos de %d\n", table.name, updated, invalid, len(stored))
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/geo"
+)
+
+func runPois(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand, expected: import")
+	}
+
+	switch args[0] {
+	case "import":
+		return importPois(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func importPois(args []string) error {
+	fs := flag.NewFlagSet("pois import", flag.ExitOnError)
+	category := fs.String("category", "", "category of every point, overrides the one in the file")
+	source := fs.String("source", "", "name of the dataset, defaults to the file name")
+	replace := fs.Bool("replace", false, "delete the points previously imported from the same source")
+	dryRun := fs.Bool("dry-run", false, "only parse and validate the file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("expected exactly one file")
+	}
+
+	fileName := fs.Arg(0)
+	if *source == "" {
+		*source = filepath.Base(fileName)
+	}
+
+	var forcedCategory db.PoiCategory
+	if *category != "" {
+		c, err := db.ParsePoiCategory(*category)
+		if err != nil {
+			return err
+		}
+		forcedCategory = c
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	points, err := geo.ReadPoints(fileName, file)
+	if err != nil {
+		return err
+	}
+
+	pois := make([]*db.Poi, 0, len(points))
+	skipped := 0
+	for _, p := range points {
+		c := forcedCategory
+		if c == "" {
+			c, err = db.ParsePoiCategory(p.Category)
+			if err != nil {
+				skipped++
+				continue
+			}
+		}
+
+		pois = append(pois, &db.Poi{
+			Name:     p.Name,
+			Category: c,
+			Lat:      p.Point.Lat(),
+			Lon:      p.Point.Lon(),
+		})
+	}
+
+	if *dryRun {
+		log.Printf("%d puntos válidos, %d sin categoría reconocida\n", len(pois), skipped)
+		return nil
+	}
+
+	created, updated, err := db.ImportPois(context.Background(), *source, pois, *replace)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Creados: %d, actualizados: %d, omitidos: %d\n", created, updated, skipped)
+	return nil
+}
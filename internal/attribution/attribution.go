@@ -0,0 +1,184 @@
+// Package attribution remembers where the clients came from the first time
+// they visited the site, the UTM parameters of the ad, the page that sent
+// them and the page they landed on, so their requests can be credited to
+// the source and campaign that brought them.
+package attribution
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+const (
+	// CookieName is the cookie with the first touch of the client
+	CookieName = "sibra_origen"
+	// CookieMaxAge is how long the first touch is credited
+	CookieMaxAge = 90 * 24 * time.Hour
+
+	// Longest values kept, as the columns of the requests
+	maxParamLen = 128
+	maxURLLen   = 512
+)
+
+// Paths that are not pages the clients land on
+var skipPrefixes = []string{
+	"/static/",
+	"/api/",
+	"/admin",
+	"/tiles/",
+	"/calendario/",
+	"/documentos/",
+}
+
+// Search engines, their visits are organic. The names without a dot match
+// any label of the host (google matches www.google.com.mx), the ones with
+// a dot match the domain.
+var searchEngines = []string{"google", "bing", "yahoo", "duckduckgo", "ecosia"}
+
+// Social networks, matched like the search engines
+var socialNetworks = []string{"facebook", "instagram", "tiktok", "twitter", "linkedin", "youtube", "whatsapp", "x.com", "t.co"}
+
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+	// Cut at a rune boundary
+	for max > 0 && !isRuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func hostMatches(host string, names []string) bool {
+	labels := strings.Split(host, ".")
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			if host == name || strings.HasSuffix(host, "."+name) {
+				return true
+			}
+		} else if slices.Contains(labels, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the attribution of a page view. Without UTM
+// parameters the source is guessed from the click ids of the ads and from
+// the referrer.
+func FromRequest(r *http.Request, now time.Time) db.Attribution {
+	query := r.URL.Query()
+	a := db.Attribution{
+		Source:       truncate(strings.ToLower(query.Get("utm_source")), maxParamLen),
+		Medium:       truncate(strings.ToLower(query.Get("utm_medium")), maxParamLen),
+		Campaign:     truncate(query.Get("utm_campaign"), maxParamLen),
+		Term:         truncate(query.Get("utm_term"), maxParamLen),
+		Content:      truncate(query.Get("utm_content"), maxParamLen),
+		LandingPage:  truncate(r.URL.RequestURI(), maxURLLen),
+		FirstTouchAt: now,
+	}
+
+	referrerHost := ""
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host != "" && ref.Host != r.Host {
+		a.Referrer = truncate(ref.String(), maxURLLen)
+		referrerHost = strings.TrimPrefix(strings.ToLower(ref.Hostname()), "www.")
+	}
+
+	if a.Source != "" {
+		return a
+	}
+
+	switch {
+	case query.Get("gclid") != "":
+		a.Source, a.Medium = "google", "cpc"
+	case query.Get("fbclid") != "":
+		a.Source, a.Medium = "facebook", "social"
+	case referrerHost == "":
+		// Direct visit, the source stays empty
+	case hostMatches(referrerHost, searchEngines):
+		a.Source, a.Medium = referrerHost, "organic"
+	case hostMatches(referrerHost, socialNetworks):
+		a.Source, a.Medium = referrerHost, "social"
+	default:
+		a.Source, a.Medium = referrerHost, "referral"
+	}
+
+	return a
+}
+
+func encode(a db.Attribution) string {
+	data, _ := json.Marshal(a)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Read returns the first touch stored in the cookie of the request, ok is
+// false when there is none
+func Read(r *http.Request) (a db.Attribution, ok bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return a, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || json.Unmarshal(data, &a) != nil {
+		return db.Attribution{}, false
+	}
+
+	// The cookie can be edited, the values are cut as the new ones
+	a.Source = truncate(a.Source, maxParamLen)
+	a.Medium = truncate(a.Medium, maxParamLen)
+	a.Campaign = truncate(a.Campaign, maxParamLen)
+	a.Term = truncate(a.Term, maxParamLen)
+	a.Content = truncate(a.Content, maxParamLen)
+	a.Referrer = truncate(a.Referrer, maxURLLen)
+	a.LandingPage = truncate(a.LandingPage, maxURLLen)
+
+	return a, true
+}
+
+func isPageView(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("HX-Request") != "" {
+		return false
+	}
+
+	for _, prefix := range skipPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Middleware sets the first touch cookie on the first page view of the
+// client, the later visits keep the first one
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPageView(r) {
+			if _, ok := Read(r); !ok {
+				http.SetCookie(w, &http.Cookie{
+					Name:     CookieName,
+					Value:    encode(FromRequest(r, time.Now())),
+					Path:     "/",
+					MaxAge:   int(CookieMaxAge.Seconds()),
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
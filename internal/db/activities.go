@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ActivityKind string
+
+const (
+	ActivityKindNote     ActivityKind = "nota"
+	ActivityKindCall     ActivityKind = "llamada"
+	ActivityKindWhatsapp ActivityKind = "whatsapp"
+)
+
+// ActivityKinds are the activity kinds in the order they are listed
+var ActivityKinds = []ActivityKind{
+	ActivityKindNote,
+	ActivityKindCall,
+	ActivityKindWhatsapp,
+}
+
+var activityKindLabels = map[ActivityKind]string{
+	ActivityKindNote:     "Nota",
+	ActivityKindCall:     "Llamada",
+	ActivityKindWhatsapp: "WhatsApp",
+}
+
+var ErrInvalidActivityKind = errors.New("invalid activity kind")
+
+func ParseActivityKind(s string) (ActivityKind, error) {
+	kind := ActivityKind(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := activityKindLabels[kind]; !ok {
+		return "", ErrInvalidActivityKind
+	}
+
+	return kind, nil
+}
+
+func (k ActivityKind) Label() string {
+	return activityKindLabels[k]
+}
+
+// Activity is a note of an agent on a request, or a call or conversation
+// they had with the client
+type Activity struct {
+	Id        string       `json:"id" db:"id"`
+	Request   string       `json:"request" db:"request"`
+	Author    string       `json:"author" db:"author"`
+	Kind      ActivityKind `json:"kind" db:"kind"`
+	Body      string       `json:"body" db:"body"`
+	CreatedAt time.Time    `json:"createdAt" db:"created_at"`
+
+	// Only set when the activity is read, from the author row
+	AuthorName string `json:"authorName" db:"author_name"`
+}
+
+const activityColumns = `
+	a.id, a.request, COALESCE(a.author::text, '') AS author, a.kind, a.body, a.created_at,
+	COALESCE(u.name || ' ' || u.lastname, '') AS author_name
+`
+
+func CreateActivity(ctx context.Context, activity *Activity) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Logging an activity answers the request
+	activity.Id = uuid.Must(uuid.NewV7()).String()
+	err = conn.QueryRow(ctx, `
+		WITH answered AS (
+			UPDATE requests SET first_response_at = COALESCE(first_response_at, NOW())
+			WHERE id = $2
+		)
+		INSERT INTO request_activities (id, request, author, kind, body)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, activity.Id, activity.Request, activity.Author, activity.Kind, activity.Body).Scan(&activity.CreatedAt)
+
+	return err
+}
+
+// FindRequestActivities returns the activities of the request, newest
+// first
+func FindRequestActivities(ctx context.Context, requestId string) ([]*Activity, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+activityColumns+`
+		FROM request_activities a
+		LEFT JOIN users u ON a.author = u.id
+		WHERE a.request = $1
+		ORDER BY a.created_at DESC
+	`, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Activity])
+}
+
+// FindContactActivities returns the activities of every request of the
+// contact, newest first
+func FindContactActivities(ctx context.Context, contactId string) ([]*Activity, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+activityColumns+`
+		FROM request_activities a
+		JOIN requests r ON a.request = r.id
+		LEFT JOIN users u ON a.author = u.id
+		WHERE r.contact = $1
+		ORDER BY a.created_at DESC
+	`, contactId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Activity])
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Attribution is where the client came from the first time they visited
+// the site, it is attached to their requests
+type Attribution struct {
+	Source   string `json:"source,omitempty" db:"utm_source"`
+	Medium   string `json:"medium,omitempty" db:"utm_medium"`
+	Campaign string `json:"campaign,omitempty" db:"utm_campaign"`
+	Term     string `json:"term,omitempty" db:"utm_term"`
+	Content  string `json:"content,omitempty" db:"utm_content"`
+	// External page the client came from, empty for direct visits
+	Referrer string `json:"referrer,omitempty" db:"referrer"`
+	// Path and query of the first page the client saw
+	LandingPage  string    `json:"landingPage,omitempty" db:"landing_page"`
+	FirstTouchAt time.Time `json:"firstTouchAt" db:"first_touch_at"`
+}
+
+// DirectSource is the source shown for the requests without one
+const DirectSource = "(directo)"
+
+// SourceLabel is the source to show, DirectSource when there is none
+func (a *Attribution) SourceLabel() string {
+	if a.Source == "" {
+		return DirectSource
+	}
+	return a.Source
+}
+
+// Conversions counts the requests that got to each step, from the lead to
+// the visit that was attended
+type Conversions struct {
+	Leads     int `db:"leads"`
+	Visits    int `db:"visits"`
+	Confirmed int `db:"confirmed"`
+	Attended  int `db:"attended"`
+}
+
+// ConfirmedPercent is the share of the leads that were confirmed
+func (c *Conversions) ConfirmedPercent() float64 {
+	if c.Leads == 0 {
+		return 0
+	}
+	return float64(c.Confirmed) / float64(c.Leads) * 100
+}
+
+// AttendedPercent is the share of the leads that ended in a visit
+func (c *Conversions) AttendedPercent() float64 {
+	if c.Leads == 0 {
+		return 0
+	}
+	return float64(c.Attended) / float64(c.Leads) * 100
+}
+
+// SourceConversions are the conversions of a source and campaign
+type SourceConversions struct {
+	Source   string `db:"source"`
+	Medium   string `db:"medium"`
+	Campaign string `db:"campaign"`
+	Conversions
+}
+
+// PropertyConversions are the conversions of the requests sent from the
+// page of a property
+type PropertyConversions struct {
+	PropertyId string `db:"property_id"`
+	Address    string `db:"address"`
+	Conversions
+}
+
+// conversionColumns counts the requests that reached each step, a request
+// to attend again was attended before. The params are numbered from
+// nextParamIdx, to follow the ones of the filter.
+func conversionColumns(nextParamIdx int) (string, []any) {
+	columns := fmt.Sprintf(`
+		count(*) AS leads,
+		count(*) FILTER (WHERE r.type = $%d) AS visits,
+		count(*) FILTER (WHERE r.status = ANY($%d::text[])) AS confirmed,
+		count(*) FILTER (WHERE r.status = ANY($%d::text[])) AS attended
+	`, nextParamIdx, nextParamIdx+1, nextParamIdx+2)
+
+	return columns, []any{
+		RequestTypeQuote,
+		[]RequestStatus{RequestStatusConfirmed, RequestStatusDone, RequestStatusRepeat},
+		[]RequestStatus{RequestStatusDone, RequestStatusRepeat},
+	}
+}
+
+// conversionQuery builds a query of the conversions of the requests that
+// match the filter, grouped by the columns
+func conversionQuery(filter *RequestFilter, columns, from, groupBy, orderBy string) (string, []any) {
+	queryConditions, queryParams, nextParamIdx := buildRequestFilterConditions(filter)
+	counts, countParams := conversionColumns(nextParamIdx)
+
+	query := "SELECT " + columns + counts + from + " WHERE 1=1"
+	if len(queryConditions) > 0 {
+		query = query + " AND " + strings.Join(queryConditions, " AND ")
+	}
+	if groupBy != "" {
+		query += " GROUP BY " + groupBy
+	}
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+
+	return query, append(queryParams, countParams...)
+}
+
+// FindSourceConversions returns the conversions of the requests that match
+// the filter by source, medium and campaign, the ones with the most leads
+// first
+func FindSourceConversions(ctx context.Context, filter *RequestFilter) ([]*SourceConversions, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query, queryParams := conversionQuery(
+		filter,
+		`COALESCE(r.utm_source, '') AS source,
+		COALESCE(r.utm_medium, '') AS medium,
+		COALESCE(r.utm_campaign, '') AS campaign,`,
+		" FROM requests r",
+		"1, 2, 3",
+		"leads DESC, source, medium, campaign",
+	)
+	rows, err := conn.Query(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[SourceConversions])
+}
+
+// FindPropertyConversions returns the conversions of the requests that
+// match the filter by the property they were sent from
+func FindPropertyConversions(ctx context.Context, filter *RequestFilter) ([]*PropertyConversions, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query, queryParams := conversionQuery(
+		filter,
+		`p.id::text AS property_id,
+		p.address || ', ' || p.nb_hood AS address,`,
+		" FROM requests r JOIN properties p ON r.property = p.id",
+		"p.id",
+		"leads DESC, address",
+	)
+	rows, err := conn.Query(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[PropertyConversions])
+}
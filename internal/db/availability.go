@@ -0,0 +1,265 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSlotTaken           = errors.New("the agent already has a visit at that time")
+	ErrInvalidAvailability = errors.New("the availability must start before it ends")
+)
+
+// Availability is a range of a weekday in which the agent takes visits,
+// in minutes from midnight
+type Availability struct {
+	Id          string       `json:"id" db:"id"`
+	Agent       string       `json:"agent" db:"agent"`
+	Weekday     time.Weekday `json:"weekday" db:"weekday"`
+	StartMinute int          `json:"startMinute" db:"start_minute"`
+	EndMinute   int          `json:"endMinute" db:"end_minute"`
+}
+
+func formatMinute(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+func (a *Availability) Start() string {
+	return formatMinute(a.StartMinute)
+}
+
+func (a *Availability) End() string {
+	return formatMinute(a.EndMinute)
+}
+
+// BlockedDate is a day the agent does not take visits
+type BlockedDate struct {
+	Id     string    `json:"id" db:"id"`
+	Agent  string    `json:"agent" db:"agent"`
+	Date   time.Time `json:"date" db:"date"`
+	Reason string    `json:"reason" db:"reason"`
+}
+
+func FindAgentAvailability(ctx context.Context, agentId string) ([]*Availability, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, agent, weekday, start_minute, end_minute
+		FROM agent_availability
+		WHERE agent = $1
+		ORDER BY weekday, start_minute
+	`, agentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Availability])
+}
+
+func CreateAvailability(ctx context.Context, a *Availability) error {
+	if a.StartMinute >= a.EndMinute {
+		return ErrInvalidAvailability
+	}
+
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	a.Id = uuid.Must(uuid.NewV7()).String()
+	_, err = conn.Exec(ctx, `
+		INSERT INTO agent_availability (id, agent, weekday, start_minute, end_minute)
+		VALUES (@id, @agent, @weekday, @start_minute, @end_minute)
+	`, pgx.NamedArgs{
+		"id":           a.Id,
+		"agent":        a.Agent,
+		"weekday":      int(a.Weekday),
+		"start_minute": a.StartMinute,
+		"end_minute":   a.EndMinute,
+	})
+
+	return err
+}
+
+// DeleteAvailability removes the range, only if it belongs to the agent
+func DeleteAvailability(ctx context.Context, id, agentId string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM agent_availability WHERE id = $1 AND agent = $2", id, agentId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindBlockedDates returns the days off of the agent from the given date
+func FindBlockedDates(ctx context.Context, agentId string, from time.Time) ([]*BlockedDate, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, agent, date, reason
+		FROM agent_blocked_dates
+		WHERE agent = $1 AND date >= $2::date
+		ORDER BY date
+	`, agentId, from.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[BlockedDate])
+}
+
+func CreateBlockedDate(ctx context.Context, b *BlockedDate) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	b.Id = uuid.Must(uuid.NewV7()).String()
+	_, err = conn.Exec(ctx, `
+		INSERT INTO agent_blocked_dates (id, agent, date, reason)
+		VALUES (@id, @agent, @date::date, @reason)
+		ON CONFLICT (agent, date) DO UPDATE SET reason = EXCLUDED.reason
+	`, pgx.NamedArgs{
+		"id":     b.Id,
+		"agent":  b.Agent,
+		"date":   b.Date.Format(time.DateOnly),
+		"reason": b.Reason,
+	})
+
+	return err
+}
+
+// DeleteBlockedDate removes the day off, only if it belongs to the agent
+func DeleteBlockedDate(ctx context.Context, id, agentId string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM agent_blocked_dates WHERE id = $1 AND agent = $2", id, agentId)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindAgentVisits returns the start of the visits of the agent scheduled
+// between from and to, the quarantined ones are left out
+func FindAgentVisits(ctx context.Context, agentId string, from, to time.Time) ([]time.Time, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT scheduled_date
+		FROM requests
+		WHERE agent = $1 AND type = $2 AND status <> $3 AND scheduled_date >= $4 AND scheduled_date < $5
+		ORDER BY scheduled_date
+	`, agentId, RequestTypeQuote, RequestStatusSpam, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[time.Time])
+}
+
+// CreateVisitRequest creates the visit request unless the agent already
+// has a visit less than duration apart from it. The agent row is locked
+// so two visits for the same agent can't be booked at once.
+func CreateVisitRequest(ctx context.Context, req *Request, duration time.Duration) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT 1 FROM users WHERE id = $1 FOR UPDATE", req.Agent)
+	if err != nil {
+		return err
+	}
+
+	var taken bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM requests
+			WHERE agent = $1 AND type = $2 AND status <> $3 AND scheduled_date > $4 AND scheduled_date < $5
+		)
+	`, req.Agent, RequestTypeQuote, RequestStatusSpam, req.ScheduledDate.Add(-duration), req.ScheduledDate.Add(duration)).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlotTaken
+	}
+
+	req.Id = uuid.Must(uuid.NewV7()).String()
+	err = tx.QueryRow(ctx, insertRequestQuery, insertRequestArgs(req)).Scan(&req.Contact)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
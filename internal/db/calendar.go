@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// CalendarStatuses are the statuses of the visits listed in the calendar
+// of the agents, the visits that are pending or must be attended again
+// have no confirmed time
+var CalendarStatuses = []RequestStatus{
+	RequestStatusConfirmed,
+	RequestStatusDone,
+}
+
+func newCalendarToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// GetCalendarToken returns the calendar token of the user, creating it the
+// first time
+func GetCalendarToken(ctx context.Context, userId string) (string, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var token string
+	err = conn.QueryRow(ctx, `
+		UPDATE users SET calendar_token = COALESCE(calendar_token, $2)
+		WHERE id = $1
+		RETURNING calendar_token
+	`, userId, newCalendarToken()).Scan(&token)
+
+	return token, err
+}
+
+// ResetCalendarToken replaces the calendar token of the user, the
+// subscriptions to the old feed stop working
+func ResetCalendarToken(ctx context.Context, userId string) (string, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var token string
+	err = conn.QueryRow(ctx, `
+		UPDATE users SET calendar_token = $2
+		WHERE id = $1
+		RETURNING calendar_token
+	`, userId, newCalendarToken()).Scan(&token)
+
+	return token, err
+}
+
+// FindUserByCalendarToken returns the active user the calendar token
+// belongs to
+func FindUserByCalendarToken(ctx context.Context, token string) (*User, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var user User
+	err = conn.QueryRow(ctx, `
+		SELECT id, name, lastname, username, role, email, phone, golden_boy, active
+		FROM users
+		WHERE calendar_token = $1 AND active
+	`, token).Scan(
+		&user.Id,
+		&user.Name,
+		&user.Lastname,
+		&user.Username,
+		&user.Role,
+		&user.Email,
+		&user.Phone,
+		&user.GoldenBoy,
+		&user.Active,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// FindCalendarVisits returns the visits of the agent in CalendarStatuses
+// scheduled from since, soonest first
+func FindCalendarVisits(ctx context.Context, agentId string, since time.Time) ([]*Request, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+requestColumns+requestJoins+`
+		WHERE r.agent = $1 AND r.type = $2 AND r.status = ANY($3::text[])
+			AND r.scheduled_date >= $4
+		ORDER BY r.scheduled_date
+	`, agentId, RequestTypeQuote, CalendarStatuses, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*Request{}
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrContactTaken     = errors.New("another contact has the same phone or email")
+	ErrMergeSameContact = errors.New("a contact can't be merged with itself")
+)
+
+// Contact is a client of the site, the requests with the same phone
+// belong to the same contact
+type Contact struct {
+	Id    string `json:"id" db:"id"`
+	Name  string `json:"name" db:"name"`
+	Phone string `json:"phone" db:"phone"`
+	Email string `json:"email" db:"email"`
+	// Phones and emails of the contacts merged into this one, their
+	// requests are linked to it too
+	OtherPhones []string  `json:"otherPhones" db:"other_phones"`
+	OtherEmails []string  `json:"otherEmails" db:"other_emails"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+
+	// Only set when the contacts are listed
+	RequestCount  int       `json:"requestCount" db:"request_count"`
+	LastRequestAt time.Time `json:"lastRequestAt" db:"last_request_at"`
+}
+
+const contactColumns = `
+	c.id, c.name, COALESCE(c.phone, '') AS phone, COALESCE(c.email, '') AS email,
+	c.other_phones::text[] AS other_phones, c.other_emails::text[] AS other_emails,
+	c.created_at, c.updated_at,
+	(SELECT count(*) FROM requests r WHERE r.contact = c.id) AS request_count,
+	COALESCE((SELECT max(r.date) FROM requests r WHERE r.contact = c.id), c.created_at) AS last_request_at
+`
+
+// buildContactSearch matches the contacts by name, email or the digits of
+// the phone
+func buildContactSearch(search string) (string, []any) {
+	search = strings.TrimSpace(search)
+	if search == "" {
+		return "", nil
+	}
+
+	return ` WHERE c.name ILIKE $1 OR c.email ILIKE $1 OR array_to_string(c.other_emails, ' ') ILIKE $1
+			OR c.phone LIKE $2 OR array_to_string(c.other_phones, ' ') LIKE $2`,
+		[]any{"%" + search + "%", "%" + onlyDigits(search) + "%"}
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func GetContactsPagination(ctx context.Context, search string, limit, page int) (*Pagination, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	where, params := buildContactSearch(search)
+
+	var count int
+	err = conn.QueryRow(ctx, "SELECT count(*) FROM contacts c"+where, params...).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPagination(count, limit, page), nil
+}
+
+// FindContacts returns the contacts matching search, the ones with the
+// latest requests first
+func FindContacts(ctx context.Context, search string, limit, page int) ([]*Contact, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	where, params := buildContactSearch(search)
+	query := "SELECT " + contactColumns + " FROM contacts c" + where + " ORDER BY last_request_at DESC"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+		if page > 1 {
+			query += fmt.Sprintf(" OFFSET %d", limit*(page-1))
+		}
+	}
+
+	rows, err := conn.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Contact])
+}
+
+func FindContactById(ctx context.Context, id string) (*Contact, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+contactColumns+" FROM contacts c WHERE c.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Contact])
+}
+
+// FindContactByKey returns the contact with the phone or email, the ones
+// of merged contacts included
+func FindContactByKey(ctx context.Context, key string) (*Contact, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+contactColumns+`
+		FROM contacts c
+		WHERE c.email = lower(trim($1))
+			OR lower(trim($1)) = ANY(c.other_emails)
+			OR c.phone = normalize_contact_phone($1)
+			OR normalize_contact_phone($1) = ANY(c.other_phones)
+		LIMIT 1
+	`, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Contact])
+}
+
+// FindContactDuplicates returns the other contacts that may be the same
+// client, with the same name or email
+func FindContactDuplicates(ctx context.Context, contact *Contact) ([]*Contact, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+contactColumns+`
+		FROM contacts c
+		WHERE c.id <> $1 AND (
+			(lower(c.name) = lower($2) AND $2 <> '')
+			OR (lower(c.email) = lower($3) AND $3 <> '')
+		)
+		ORDER BY last_request_at DESC
+		LIMIT 10
+	`, contact.Id, strings.TrimSpace(contact.Name), contact.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Contact])
+}
+
+// UpdateContact saves the name, phone and email of the contact. The phone
+// is normalized like the phones of the requests and the email is
+// lowercased, ErrContactTaken is returned when they belong to another
+// contact.
+func UpdateContact(ctx context.Context, contact *Contact) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = conn.QueryRow(ctx, `
+		UPDATE contacts SET
+			name = @name,
+			phone = normalize_contact_phone(@phone),
+			email = NULLIF(lower(trim(@email)), ''),
+			updated_at = NOW()
+		WHERE id = @id
+		RETURNING COALESCE(phone, ''), COALESCE(email, ''), updated_at
+	`, pgx.NamedArgs{
+		"id":    contact.Id,
+		"name":  contact.Name,
+		"phone": contact.Phone,
+		"email": contact.Email,
+	}).Scan(&contact.Phone, &contact.Email, &contact.UpdatedAt)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return ErrContactTaken
+	}
+
+	return err
+}
+
+// MergeContacts moves the requests of the duplicate to the contact and
+// deletes the duplicate. The phone and email of the duplicate are kept
+// when the contact has none, else they are added to its other phones and
+// emails with the ones the duplicate had, so the next requests from them
+// are linked to it.
+func MergeContacts(ctx context.Context, contactId, duplicateId string) error {
+	if contactId == duplicateId {
+		return ErrMergeSameContact
+	}
+
+	tx, conn, err := GetTxAndPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	defer tx.Rollback(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err = tx.Exec(ctx, `
+		UPDATE requests SET contact = $1, updated_at = NOW() WHERE contact = $2
+	`, contactId, duplicateId)
+	if err != nil {
+		return err
+	}
+
+	// The duplicate goes first, the phone and email are unique
+	var phone, email sql.NullString
+	var otherPhones, otherEmails []string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM contacts WHERE id = $1
+		RETURNING phone, email, other_phones::text[], other_emails::text[]
+	`, duplicateId).Scan(&phone, &email, &otherPhones, &otherEmails)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE contacts SET
+			phone = COALESCE(phone, $2),
+			email = COALESCE(email, $3),
+			other_phones = ARRAY(
+				SELECT DISTINCT p FROM unnest(other_phones || $4::varchar[] || $2::varchar) p
+				WHERE p IS NOT NULL AND p <> COALESCE(phone, $2)
+			),
+			other_emails = ARRAY(
+				SELECT DISTINCT e FROM unnest(other_emails || $5::varchar[] || $3::varchar) e
+				WHERE e IS NOT NULL AND e <> COALESCE(email, $3)
+			),
+			updated_at = NOW()
+		WHERE id = $1
+	`, contactId, phone, email, otherPhones, otherEmails)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
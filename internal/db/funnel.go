@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportPeriod is how the volume of requests is grouped
+type ReportPeriod string
+
+const (
+	ReportPeriodDay  ReportPeriod = "dia"
+	ReportPeriodWeek ReportPeriod = "semana"
+)
+
+var ReportPeriods = []ReportPeriod{ReportPeriodDay, ReportPeriodWeek}
+
+var reportPeriodLabels = map[ReportPeriod]string{
+	ReportPeriodDay:  "Por día",
+	ReportPeriodWeek: "Por semana",
+}
+
+var ErrInvalidReportPeriod = errors.New("invalid report period")
+
+func ParseReportPeriod(s string) (ReportPeriod, error) {
+	period := ReportPeriod(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := reportPeriodLabels[period]; !ok {
+		return "", ErrInvalidReportPeriod
+	}
+
+	return period, nil
+}
+
+func (p ReportPeriod) Label() string {
+	return reportPeriodLabels[p]
+}
+
+// truncField is the date_trunc field of the period, weeks start on monday
+func (p ReportPeriod) truncField() string {
+	if p == ReportPeriodWeek {
+		return "week"
+	}
+	return "day"
+}
+
+// start returns the start of the period t is in
+func (p ReportPeriod) start(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if p == ReportPeriodWeek {
+		// Go weeks start on sunday
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t
+}
+
+func (p ReportPeriod) next(t time.Time) time.Time {
+	if p == ReportPeriodWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// RequestVolume are the conversions of the requests created in a period
+type RequestVolume struct {
+	Period time.Time `db:"period"`
+	Conversions
+}
+
+// FindRequestVolume returns the conversions of the requests that match the
+// filter by the day or week they were created in loc, oldest first. When
+// the filter has a range, the periods without requests are included.
+func FindRequestVolume(ctx context.Context, filter *RequestFilter, period ReportPeriod, loc *time.Location) ([]*RequestVolume, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	queryConditions, queryParams, nextParamIdx := buildRequestFilterConditions(filter)
+	counts, countParams := conversionColumns(nextParamIdx + 1)
+	query := fmt.Sprintf(
+		"SELECT date_trunc('%s', r.date AT TIME ZONE $%d) AS period,",
+		period.truncField(),
+		nextParamIdx,
+	) + counts + " FROM requests r WHERE 1=1"
+	if len(queryConditions) > 0 {
+		query = query + " AND " + strings.Join(queryConditions, " AND ")
+	}
+	query += " GROUP BY 1 ORDER BY 1"
+
+	queryParams = append(queryParams, loc.String())
+	rows, err := conn.Query(ctx, query, append(queryParams, countParams...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RequestVolume])
+	if err != nil {
+		return nil, err
+	}
+
+	// The periods are read as UTC wall times of loc
+	for _, v := range volume {
+		v.Period = time.Date(v.Period.Year(), v.Period.Month(), v.Period.Day(), 0, 0, 0, 0, loc)
+	}
+
+	if filter.CreatedFrom == nil || filter.CreatedTo == nil {
+		return volume, nil
+	}
+
+	byPeriod := map[time.Time]*RequestVolume{}
+	for _, v := range volume {
+		byPeriod[v.Period] = v
+	}
+
+	filled := []*RequestVolume{}
+	for t := period.start(*filter.CreatedFrom); !t.After(*filter.CreatedTo); t = period.next(t) {
+		if v, ok := byPeriod[t]; ok {
+			filled = append(filled, v)
+		} else {
+			filled = append(filled, &RequestVolume{Period: t})
+		}
+	}
+
+	return filled, nil
+}
+
+// FindRequestFunnel returns how many of the requests that match the filter
+// got to be confirmed and attended
+func FindRequestFunnel(ctx context.Context, filter *RequestFilter) (*Conversions, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query, queryParams := conversionQuery(filter, "", " FROM requests r", "", "")
+	rows, err := conn.Query(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Conversions])
+}
+
+// RequestBreakdown counts the requests of a type in a status
+type RequestBreakdown struct {
+	Type   RequestType   `db:"type"`
+	Status RequestStatus `db:"status"`
+	Count  int           `db:"count"`
+}
+
+// FindRequestBreakdown counts the requests that match the filter by their
+// type and status
+func FindRequestBreakdown(ctx context.Context, filter *RequestFilter) ([]*RequestBreakdown, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	queryConditions, queryParams, _ := buildRequestFilterConditions(filter)
+	query := "SELECT r.type, r.status, count(*) AS count FROM requests r WHERE 1=1"
+	if len(queryConditions) > 0 {
+		query = query + " AND " + strings.Join(queryConditions, " AND ")
+	}
+	query += " GROUP BY r.type, r.status"
+
+	rows, err := conn.Query(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RequestBreakdown])
+}
@@ -2,29 +2,68 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"math/big"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/encoding/wkb"
+	"github.com/vladwithcode/sibra-site/internal/geo"
+)
+
+var (
+	ErrHoodNameRequired = errors.New("hood name is required")
 )
 
 type Hood struct {
-	Id               string       `sql,json:"id"`
-	Name             string       `sql,json:"name"`
-	Boundaries       [][2]float64 `sql,json:"boundaries"`
-	SqMeterPrice     big.Float    `sql:"sq_meter_price" json:"sqMeterPrice"`
-	Location         *orb.Point   `sql,json:"location"`
-	Lon              *float64     `sql,json:"lon"`
-	Lat              *float64     `sql,json:"lat"`
-	AvgPropertyPrice *big.Float   `sql:"avg_property_price" json:"avgPropertyPrice"`
-	MaxPropertyPrice *big.Float   `sql:"max_property_price" json:"maxPropertyPrice"`
-	MinPropertyPrice *big.Float   `sql:"min_property_price" json:"minPropertyPrice"`
-	AvgLandSize      *float64     `sql:"avg_land_size" json:"avgLandSize"`
-	MaxLandSize      *float64     `sql:"max_land_size" json:"maxLandSize"`
-	MinLandSize      *float64     `sql:"min_land_size" json:"minLandSize"`
-	PropertyCount    *int         `sql:"property_count" json:"propertyCount"`
+	Id                 string           `sql,json:"id"`
+	Name               string           `sql,json:"name"`
+	Boundaries         orb.MultiPolygon `json:"boundaries" db:"boundaries"`
+	DisplayBoundaries  orb.MultiPolygon `json:"displayBoundaries" db:"display_boundaries"`
+	SqMeterPrice       big.Float        `sql:"sq_meter_price" json:"sqMeterPrice"`
+	PriceEffectiveDate time.Time        `json:"priceEffectiveDate,omitzero" db:"price_effective_date"`
+	Location           *orb.Point       `sql,json:"location"`
+	Lon                *float64         `sql,json:"lon"`
+	Lat                *float64         `sql,json:"lat"`
+	AvgPropertyPrice   *big.Float       `sql:"avg_property_price" json:"avgPropertyPrice"`
+	MaxPropertyPrice   *big.Float       `sql:"max_property_price" json:"maxPropertyPrice"`
+	MinPropertyPrice   *big.Float       `sql:"min_property_price" json:"minPropertyPrice"`
+	AvgLandSize        *float64         `sql:"avg_land_size" json:"avgLandSize"`
+	MaxLandSize        *float64         `sql:"max_land_size" json:"maxLandSize"`
+	MinLandSize        *float64         `sql:"min_land_size" json:"minLandSize"`
+	PropertyCount      *int             `sql:"property_count" json:"propertyCount"`
+}
+
+// HoodPrice is an entry of the price per m² history of a hood. The price
+// applies from EffectiveDate until the next entry takes effect.
+type HoodPrice struct {
+	Id            string    `json:"id" db:"id"`
+	Hood          string    `json:"hood" db:"hood"`
+	SqMeterPrice  float64   `json:"sqMeterPrice" db:"sq_meter_price"`
+	EffectiveDate time.Time `json:"effectiveDate" db:"effective_date"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+}
+
+// SetBoundaries replaces the boundaries of the hood, generating the
+// simplified display boundaries and placing the hood's location at the
+// center of its bounding box
+func (h *Hood) SetBoundaries(mp orb.MultiPolygon) {
+	h.Boundaries = mp
+	h.DisplayBoundaries = geo.SimplifyMultiPolygon(mp, geo.DefaultSimplifyTolerance)
+
+	if len(mp) == 0 {
+		h.Location, h.Lon, h.Lat = nil, nil, nil
+		return
+	}
+
+	center := mp.Bound().Center()
+	lon, lat := center.Lon(), center.Lat()
+	h.Location = &center
+	h.Lon = &lon
+	h.Lat = &lat
 }
 
 func (h *Hood) GetCoords() (wkbCoords []byte, err error) {
@@ -38,6 +77,113 @@ func (h *Hood) GetCoords() (wkbCoords []byte, err error) {
 	return
 }
 
+func (h *Hood) PriceFloat() float64 {
+	price, _ := h.SqMeterPrice.Float64()
+	return price
+}
+
+// geomArg encodes g as WKB to be used with ST_GeomFromWKB, empty geometries
+// are sent as NULL
+func geomArg(g orb.Geometry) (any, error) {
+	if g == nil {
+		return nil, nil
+	}
+	if mp, ok := g.(orb.MultiPolygon); ok && len(mp) == 0 {
+		return nil, nil
+	}
+
+	return wkb.Marshal(g)
+}
+
+func hoodArgs(hood *Hood) (pgx.NamedArgs, error) {
+	boundaries, err := geomArg(hood.Boundaries)
+	if err != nil {
+		return nil, err
+	}
+	displayBoundaries, err := geomArg(hood.DisplayBoundaries)
+	if err != nil {
+		return nil, err
+	}
+	wkbCoords, err := hood.GetCoords()
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.NamedArgs{
+		"id":                 hood.Id,
+		"name":               hood.Name,
+		"boundaries":         boundaries,
+		"display_boundaries": displayBoundaries,
+		"sq_meter_price":     hood.PriceFloat(),
+		"location":           wkbCoords,
+		"lon":                hood.Lon,
+		"lat":                hood.Lat,
+	}, nil
+}
+
+const hoodSelectQuery = `
+	SELECT
+		h.id, h.name,
+		ST_AsBinary(h.boundaries), ST_AsBinary(h.display_boundaries),
+		COALESCE(hp.sq_meter_price, h.sq_meter_price, 0), hp.effective_date,
+		ST_AsBinary(h.location), h.lon, h.lat,
+		pc.property_count
+	FROM hoods h
+	LEFT JOIN LATERAL (
+		SELECT sq_meter_price, effective_date
+		FROM hood_prices
+		WHERE hood = h.id AND effective_date <= CURRENT_DATE
+		ORDER BY effective_date DESC
+		LIMIT 1
+	) hp ON true
+	LEFT JOIN LATERAL (
+		SELECT count(*)::int AS property_count
+		FROM properties p
+		WHERE p.nb_hood = h.name
+	) pc ON true
+`
+
+func scanHood(row pgx.Row) (*Hood, error) {
+	var (
+		hood          Hood
+		price         float64
+		effectiveDate sql.NullTime
+		location      orb.Point
+		propCount     sql.NullInt32
+	)
+	locationScanner := wkb.Scanner(&location)
+
+	err := row.Scan(
+		&hood.Id,
+		&hood.Name,
+		wkb.Scanner(&hood.Boundaries),
+		wkb.Scanner(&hood.DisplayBoundaries),
+		&price,
+		&effectiveDate,
+		locationScanner,
+		&hood.Lon,
+		&hood.Lat,
+		&propCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hood.SqMeterPrice = *big.NewFloat(price)
+	if effectiveDate.Valid {
+		hood.PriceEffectiveDate = effectiveDate.Time
+	}
+	if locationScanner.Valid {
+		hood.Location = &location
+	}
+	if propCount.Valid {
+		count := int(propCount.Int32)
+		hood.PropertyCount = &count
+	}
+
+	return &hood, nil
+}
+
 func CreateHood(hood *Hood) error {
 	conn, err := GetPool()
 	if err != nil {
@@ -48,24 +194,31 @@ func CreateHood(hood *Hood) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	wkbCoords, err := hood.GetCoords()
+	if hood.Name == "" {
+		return ErrHoodNameRequired
+	}
 
+	id, _ := uuid.NewV7()
+	hood.Id = id.String()
+
+	args, err := hoodArgs(hood)
 	if err != nil {
 		return err
 	}
 
-	id, _ := uuid.NewV7()
-
 	_, err = conn.Exec(
 		ctx,
-		"INSERT INTO hoods (id, name, boundaries, sq_meter_price, location, lon, lat) VALUES $1, $2, $3, $4, $5, $6, $7",
-		id,
-		hood.Name,
-		hood.Boundaries,
-		hood.SqMeterPrice,
-		wkbCoords,
-		hood.Lon,
-		hood.Lat,
+		`INSERT INTO hoods (
+			id, name, boundaries, display_boundaries, sq_meter_price, location, lon, lat
+		) VALUES (
+			@id, @name,
+			ST_Multi(ST_GeomFromWKB(@boundaries, 4326)),
+			ST_Multi(ST_GeomFromWKB(@display_boundaries, 4326)),
+			@sq_meter_price,
+			ST_GeomFromWKB(@location, 4326),
+			@lon, @lat
+		)`,
+		args,
 	)
 
 	if err != nil {
@@ -87,30 +240,338 @@ func FindHoods() (hoods []*Hood, err error) {
 
 	rows, err := conn.Query(
 		ctx,
-		"SELECT * FROM hoods",
+		hoodSelectQuery+" ORDER BY h.name",
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	hoods = []*Hood{}
-	var price float64
 	for rows.Next() {
-		hood := Hood{}
-		var coords []byte
-		rows.Scan(
-			&hood.Id,
-			&hood.Name,
-			&hood.Boundaries,
-			&price,
-			&coords,
-			&hood.Lon,
-			&hood.Lat,
+		hood, err := scanHood(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		hoods = append(hoods, hood)
+	}
+
+	return hoods, rows.Err()
+}
+
+func FindHoodById(ctx context.Context, id string) (*Hood, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return scanHood(conn.QueryRow(ctx, hoodSelectQuery+" WHERE h.id = $1", id))
+}
+
+func FindHoodByName(ctx context.Context, name string) (*Hood, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return scanHood(conn.QueryRow(ctx, hoodSelectQuery+" WHERE lower(h.name) = lower($1)", name))
+}
+
+// UpdateHood saves the hood. The properties are linked to their hood by
+// its name, so they are moved to the new name when the hood is renamed.
+func UpdateHood(ctx context.Context, hood *Hood) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if hood.Name == "" {
+		return ErrHoodNameRequired
+	}
+
+	args, err := hoodArgs(hood)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldName string
+	err = tx.QueryRow(ctx, "SELECT name FROM hoods WHERE id = $1 FOR UPDATE", hood.Id).Scan(&oldName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE hoods SET
+			name = @name,
+			boundaries = ST_Multi(ST_GeomFromWKB(@boundaries, 4326)),
+			display_boundaries = ST_Multi(ST_GeomFromWKB(@display_boundaries, 4326)),
+			location = ST_GeomFromWKB(@location, 4326),
+			lon = @lon,
+			lat = @lat
+		WHERE id = @id`,
+		args,
+	)
+	if err != nil {
+		return err
+	}
+
+	if oldName != hood.Name {
+		_, err = tx.Exec(ctx, "UPDATE properties SET nb_hood = $1 WHERE nb_hood = $2", hood.Name, oldName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func DeleteHoodById(ctx context.Context, id string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM hoods WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// AddHoodPrice registers a new price per m² for the hood, starting on
+// effectiveDate. Registering a price for an existing date replaces it.
+func AddHoodPrice(ctx context.Context, hoodId string, price float64, effectiveDate time.Time) (*HoodPrice, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hp := HoodPrice{
+		Id:            uuid.Must(uuid.NewV7()).String(),
+		Hood:          hoodId,
+		SqMeterPrice:  price,
+		EffectiveDate: effectiveDate,
+		CreatedAt:     time.Now(),
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO hood_prices (id, hood, sq_meter_price, effective_date, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (hood, effective_date) DO UPDATE SET
+			sq_meter_price = EXCLUDED.sq_meter_price,
+			created_at = EXCLUDED.created_at`,
+		hp.Id,
+		hp.Hood,
+		hp.SqMeterPrice,
+		hp.EffectiveDate,
+		hp.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the cached price in hoods in sync with the price in effect
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE hoods h SET sq_meter_price = hp.sq_meter_price
+		FROM (
+			SELECT sq_meter_price FROM hood_prices
+			WHERE hood = $1 AND effective_date <= CURRENT_DATE
+			ORDER BY effective_date DESC
+			LIMIT 1
+		) hp
+		WHERE h.id = $1`,
+		hoodId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hp, tx.Commit(ctx)
+}
+
+// ApplyHoodPrices copies to the hoods the prices that took effect since
+// they were registered, the ones dated in the future. It returns how many
+// hoods changed their price.
+func ApplyHoodPrices(ctx context.Context) (int64, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, `
+		UPDATE hoods h SET sq_meter_price = hp.sq_meter_price
+		FROM (
+			SELECT DISTINCT ON (hood) hood, sq_meter_price
+			FROM hood_prices
+			WHERE effective_date <= CURRENT_DATE
+			ORDER BY hood, effective_date DESC
+		) hp
+		WHERE h.id = hp.hood AND h.sq_meter_price IS DISTINCT FROM hp.sq_meter_price
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func FindHoodPrices(ctx context.Context, hoodId string) ([]*HoodPrice, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT id, hood, sq_meter_price, effective_date, created_at
+		FROM hood_prices
+		WHERE hood = $1
+		ORDER BY effective_date DESC`,
+		hoodId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []*HoodPrice{}
+	for rows.Next() {
+		var hp HoodPrice
+		err = rows.Scan(
+			&hp.Id,
+			&hp.Hood,
+			&hp.SqMeterPrice,
+			&hp.EffectiveDate,
+			&hp.CreatedAt,
 		)
-		hood.SqMeterPrice = *big.NewFloat(price)
+		if err != nil {
+			return nil, err
+		}
 
-		hoods = append(hoods, &hood)
+		prices = append(prices, &hp)
+	}
+
+	return prices, rows.Err()
+}
+
+// ImportHoods creates or updates (matching by name) a hood for each
+// feature. Features without a name are skipped.
+func ImportHoods(ctx context.Context, features []geo.Feature) (created, updated, skipped int, err error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	for _, f := range features {
+		if f.Name == "" {
+			skipped++
+			continue
+		}
+
+		hood := Hood{
+			Id:   uuid.Must(uuid.NewV7()).String(),
+			Name: f.Name,
+		}
+		hood.SetBoundaries(f.Boundaries)
+
+		var args pgx.NamedArgs
+		args, err = hoodArgs(&hood)
+		if err != nil {
+			return
+		}
+
+		var inserted bool
+		err = tx.QueryRow(
+			ctx,
+			`INSERT INTO hoods (
+				id, name, boundaries, display_boundaries, sq_meter_price, location, lon, lat
+			) VALUES (
+				@id, @name,
+				ST_Multi(ST_GeomFromWKB(@boundaries, 4326)),
+				ST_Multi(ST_GeomFromWKB(@display_boundaries, 4326)),
+				0,
+				ST_GeomFromWKB(@location, 4326),
+				@lon, @lat
+			)
+			ON CONFLICT (name) DO UPDATE SET
+				boundaries = EXCLUDED.boundaries,
+				display_boundaries = EXCLUDED.display_boundaries,
+				location = EXCLUDED.location,
+				lon = EXCLUDED.lon,
+				lat = EXCLUDED.lat
+			RETURNING (xmax = 0)`,
+			args,
+		).Scan(&inserted)
+		if err != nil {
+			return
+		}
+
+		if inserted {
+			created++
+		} else {
+			updated++
+		}
 	}
 
+	err = tx.Commit(ctx)
 	return
 }
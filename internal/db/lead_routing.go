@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FindNextAgent returns the active editor that should receive the next
+// general lead, leaving out the agents in exclude. The leads assigned in
+// the last 30 days are divided by the weight of each agent, golden boys
+// weigh goldenBoyWeight, and the agent with the least load wins. Ties go
+// to whoever was assigned a lead the longest ago, so the agents take
+// turns. When visitAt is set, the agents with a visit less than
+// visitDuration apart from it are left out too.
+func FindNextAgent(ctx context.Context, exclude []string, goldenBoyWeight float64, visitAt time.Time, visitDuration time.Duration) (*User, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if exclude == nil {
+		exclude = []string{}
+	}
+
+	var user User
+	err = conn.QueryRow(ctx, `
+		SELECT u.id, u.name, u.lastname, u.username, u.role, u.email, u.phone, u.golden_boy, u.active
+		FROM users u
+		LEFT JOIN LATERAL (
+			SELECT count(*) AS assigned, max(r.assigned_at) AS last_assigned
+			FROM requests r
+			WHERE r.agent = u.id AND r.assigned_at > NOW() - interval '30 days'
+		) recent ON true
+		WHERE u.role = $1 AND u.active AND NOT (u.id = ANY($2::text[]::uuid[]))
+			AND ($4::timestamptz IS NULL OR NOT EXISTS (
+				SELECT 1 FROM requests v
+				WHERE v.agent = u.id AND v.type = $5 AND v.status <> $7
+					AND v.scheduled_date > $4::timestamptz - $6::interval
+					AND v.scheduled_date < $4::timestamptz + $6::interval
+			))
+		ORDER BY
+			recent.assigned::float8 / (CASE WHEN u.golden_boy THEN $3::float8 ELSE 1 END),
+			recent.last_assigned NULLS FIRST,
+			u.id
+		LIMIT 1
+	`, RoleEditor, exclude, goldenBoyWeight, sql.NullTime{
+		Time:  visitAt,
+		Valid: !visitAt.IsZero(),
+	}, RequestTypeQuote, visitDuration, RequestStatusSpam).Scan(
+		&user.Id,
+		&user.Name,
+		&user.Lastname,
+		&user.Username,
+		&user.Role,
+		&user.Email,
+		&user.Phone,
+		&user.GoldenBoy,
+		&user.Active,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// AssignRequest gives the request to the agent and adds the agent to the
+// ones that have had it. The request must still be pending with the agent
+// and assignment it was read with, it fails with ErrRequestChanged when
+// it was attended or reassigned since then.
+func AssignRequest(ctx context.Context, req *Request, agentId string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var assignedAt time.Time
+	err = conn.QueryRow(ctx, `
+		UPDATE requests SET
+			agent = @agent,
+			assigned_at = NOW(),
+			tried_agents = array_append(array_remove(tried_agents, @agent::uuid), @agent::uuid),
+			sla_reminded_at = NULL,
+			updated_at = NOW()
+		WHERE id = @id AND status = @pending
+			AND agent IS NOT DISTINCT FROM @old_agent::uuid
+			AND assigned_at IS NOT DISTINCT FROM @old_assigned_at
+		RETURNING assigned_at
+	`, pgx.NamedArgs{
+		"id":      req.Id,
+		"agent":   agentId,
+		"pending": RequestStatusPending,
+		"old_agent": sql.NullString{
+			String: req.Agent,
+			Valid:  req.Agent != "",
+		},
+		"old_assigned_at": sql.NullTime{
+			Time:  req.AssignedAt,
+			Valid: !req.AssignedAt.IsZero(),
+		},
+	}).Scan(&assignedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRequestChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	req.Agent = agentId
+	req.AssignedAt = assignedAt
+	// The new agent gets their own reminder
+	req.SLARemindedAt = time.Time{}
+	req.TriedAgents = append(req.TriedAgents, agentId)
+
+	return nil
+}
+
+// FindUnansweredRequests returns the pending requests assigned before
+// assignedBefore, the agent has not attended them yet, and the ones that
+// could not be assigned to anyone
+func FindUnansweredRequests(ctx context.Context, assignedBefore time.Time) ([]*Request, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+requestColumns+requestJoins+`
+		WHERE r.status = $1 AND (r.assigned_at < $2 OR r.assigned_at IS NULL)
+		ORDER BY r.assigned_at NULLS FIRST
+	`, RequestStatusPending, assignedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*Request{}
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// SetUserActive includes or leaves out the user from the lead routing
+func SetUserActive(ctx context.Context, username string, active bool) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "UPDATE users SET active = $1 WHERE username = $2", active, username)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
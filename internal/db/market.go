@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// MarketDimensionAll is stored in the dimension columns of a snapshot that
+// aggregates every value of that dimension
+const MarketDimensionAll = "*"
+
+// MarketDimensionNone is stored in the dimension columns of a snapshot of
+// the properties without a value in that dimension, e.g. with no hood,
+// so they are never taken for the rows that aggregate every value
+const MarketDimensionNone = "-"
+
+type MarketSnapshot struct {
+	Id                 string    `json:"id" db:"id"`
+	Period             time.Time `json:"period" db:"period"`
+	Hood               string    `json:"hood" db:"hood"`
+	City               string    `json:"city" db:"city"`
+	Contract           string    `json:"contract" db:"contract"`
+	PropertyType       string    `json:"propertyType" db:"property_type"`
+	ActiveCount        int       `json:"activeCount" db:"active_count"`
+	MedianPrice        *float64  `json:"medianPrice" db:"median_price"`
+	MedianSqMeterPrice *float64  `json:"medianSqMeterPrice" db:"median_sq_meter_price"`
+	NewListingCount    int       `json:"newListingCount" db:"new_listing_count"`
+	MedianDaysOnMarket *int      `json:"medianDaysOnMarket" db:"median_days_on_market"`
+	SoldCount          int       `json:"soldCount" db:"sold_count"`
+}
+
+// MarketTrendFilter selects a single series of snapshots, unset dimensions
+// are matched against MarketDimensionAll
+type MarketTrendFilter struct {
+	Hood         string
+	City         string
+	Contract     string
+	PropertyType string
+	From         time.Time
+	To           time.Time
+}
+
+func dimensionOrAll(d string) string {
+	if d == "" {
+		return MarketDimensionAll
+	}
+	return d
+}
+
+// MonthStart returns the first day of the month of t
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ComputeMarketSnapshots aggregates the published and sold properties for
+// the month of period, for every combination of hood, city, contract and
+// property type. Snapshots already stored for the month are replaced, so
+// the current month can be recomputed as often as needed.
+func ComputeMarketSnapshots(ctx context.Context, period time.Time) (int64, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	tag, err := conn.Exec(
+		ctx,
+		`WITH bounds AS (
+			SELECT $1::date AS period_start, ($1::date + interval '1 month')::date AS period_end
+		), props AS (
+			SELECT
+				COALESCE(NULLIF(p.nb_hood, ''), $4) AS nb_hood,
+				COALESCE(NULLIF(p.city, ''), $4) AS city,
+				COALESCE(NULLIF(p.contract, ''), $4) AS contract,
+				COALESCE(NULLIF(p.property_type, ''), $4) AS property_type,
+				p.price,
+				p.price / NULLIF(p.square_mt, 0) AS sq_meter_price,
+				p.listing_date < b.period_end
+					AND (p.sold_at IS NULL OR p.sold_at >= b.period_end) AS is_active,
+				p.listing_date >= b.period_start AND p.listing_date < b.period_end AS is_new,
+				p.sold_at >= b.period_start AND p.sold_at < b.period_end AS is_sold,
+				p.sold_at::date - p.listing_date AS days_on_market
+			FROM properties p, bounds b
+			WHERE p.status IN ($2, $3)
+				AND p.listing_date IS NOT NULL
+		)
+		INSERT INTO market_snapshots (
+			id, period, hood, city, contract, property_type, active_count,
+			median_price, median_sq_meter_price, new_listing_count,
+			median_days_on_market, sold_count
+		)
+		SELECT
+			gen_random_uuid(),
+			$1::date,
+			CASE WHEN GROUPING(nb_hood) = 1 THEN '*' ELSE nb_hood END,
+			CASE WHEN GROUPING(city) = 1 THEN '*' ELSE city END,
+			CASE WHEN GROUPING(contract) = 1 THEN '*' ELSE contract END,
+			CASE WHEN GROUPING(property_type) = 1 THEN '*' ELSE property_type END,
+			count(*) FILTER (WHERE is_active),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY price) FILTER (WHERE is_active),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY sq_meter_price) FILTER (WHERE is_active),
+			count(*) FILTER (WHERE is_new),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY days_on_market) FILTER (WHERE is_sold),
+			count(*) FILTER (WHERE is_sold)
+		FROM props
+		GROUP BY CUBE (nb_hood, city, contract, property_type)
+		HAVING count(*) FILTER (WHERE is_active OR is_new OR is_sold) > 0
+		ON CONFLICT (period, hood, city, contract, property_type) DO UPDATE SET
+			active_count = EXCLUDED.active_count,
+			median_price = EXCLUDED.median_price,
+			median_sq_meter_price = EXCLUDED.median_sq_meter_price,
+			new_listing_count = EXCLUDED.new_listing_count,
+			median_days_on_market = EXCLUDED.median_days_on_market,
+			sold_count = EXCLUDED.sold_count,
+			created_at = NOW()`,
+		MonthStart(period),
+		PropertyStatusPublished,
+		PropertyStatusSold,
+		MarketDimensionNone,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// FindMarketTrends returns the monthly snapshots of a single series,
+// ordered from the oldest to the newest month
+func FindMarketTrends(ctx context.Context, filter *MarketTrendFilter) ([]*MarketSnapshot, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := filter.From
+	if from.IsZero() {
+		from = to.AddDate(-1, 0, 0)
+	}
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT
+			id, period, hood, city, contract, property_type, active_count,
+			median_price::float8, median_sq_meter_price::float8, new_listing_count,
+			median_days_on_market, sold_count
+		FROM market_snapshots
+		WHERE hood = $1 AND city = $2 AND contract = $3 AND property_type = $4
+			AND period >= $5 AND period <= $6
+		ORDER BY period ASC`,
+		dimensionOrAll(filter.Hood),
+		dimensionOrAll(filter.City),
+		dimensionOrAll(filter.Contract),
+		dimensionOrAll(filter.PropertyType),
+		MonthStart(from),
+		MonthStart(to),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []*MarketSnapshot{}
+	for rows.Next() {
+		var s MarketSnapshot
+		err = rows.Scan(
+			&s.Id,
+			&s.Period,
+			&s.Hood,
+			&s.City,
+			&s.Contract,
+			&s.PropertyType,
+			&s.ActiveCount,
+			&s.MedianPrice,
+			&s.MedianSqMeterPrice,
+			&s.NewListingCount,
+			&s.MedianDaysOnMarket,
+			&s.SoldCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, &s)
+	}
+
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// NotificationPreference is the channels a user gets the notifications of
+// an event by, the events and channels are the ones of the notify package
+type NotificationPreference struct {
+	Event    string   `db:"event"`
+	Channels []string `db:"channels"`
+}
+
+// FindNotificationPreferences returns the events the user chose the
+// channels of
+func FindNotificationPreferences(ctx context.Context, userId string) ([]*NotificationPreference, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT event, channels FROM notification_preferences WHERE user_id = $1", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[NotificationPreference])
+}
+
+// UpdateNotificationPreferences sets the channels of each event of prefs
+// for the user, the other events keep theirs
+func UpdateNotificationPreferences(ctx context.Context, userId string, prefs []*NotificationPreference) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, p := range prefs {
+		channels := p.Channels
+		if channels == nil {
+			channels = []string{}
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO notification_preferences (user_id, event, channels)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, event) DO UPDATE SET channels = EXCLUDED.channels
+		`, userId, p.Event, channels)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
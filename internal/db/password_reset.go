@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// PasswordResetValidity is how long the link to reset the password works
+const PasswordResetValidity = time.Hour
+
+// hashResetToken is the form the reset token is stored in, a leaked table
+// doesn't give working links
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset creates the reset token of the user with the email,
+// replacing the previous one. It returns pgx.ErrNoRows when no user has
+// the email.
+func CreatePasswordReset(ctx context.Context, email string, now time.Time) (*User, string, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	var user User
+	err = conn.QueryRow(ctx, `
+		UPDATE users SET reset_token = $2, reset_expires_at = $3
+		WHERE lower(email) = lower($1)
+		RETURNING id, name, lastname, username, email
+	`, email, hashResetToken(token), now.Add(PasswordResetValidity)).Scan(
+		&user.Id,
+		&user.Name,
+		&user.Lastname,
+		&user.Username,
+		&user.Email,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+// FindPasswordReset returns the user of the reset token, pgx.ErrNoRows
+// when the token is unknown or expired
+func FindPasswordReset(ctx context.Context, token string, now time.Time) (*User, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var user User
+	err = conn.QueryRow(ctx, `
+		SELECT id, name, lastname, username, email FROM users
+		WHERE reset_token = $1 AND reset_expires_at > $2
+	`, hashResetToken(token), now).Scan(
+		&user.Id,
+		&user.Name,
+		&user.Lastname,
+		&user.Username,
+		&user.Email,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ResetPassword sets the password of the user of the reset token and
+// clears the token, so the link works once. It returns pgx.ErrNoRows when
+// the token is unknown or expired.
+func ResetPassword(ctx context.Context, token, password string, now time.Time) (*User, error) {
+	var user User
+	if err := user.HashPass(password); err != nil {
+		return nil, err
+	}
+
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = conn.QueryRow(ctx, `
+		UPDATE users SET password = $3, reset_token = NULL, reset_expires_at = NULL
+		WHERE reset_token = $1 AND reset_expires_at > $2
+		RETURNING id, username
+	`, hashResetToken(token), now, user.Password).Scan(&user.Id, &user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
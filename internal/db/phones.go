@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StoredPhone is the phone of a request or user row as it is stored
+type StoredPhone struct {
+	Id      string `db:"id"`
+	Phone   string `db:"phone"`
+	Display string `db:"phone_display"`
+}
+
+// FindRequestPhones returns the phones of every request
+func FindRequestPhones(ctx context.Context) ([]*StoredPhone, error) {
+	return findPhones(ctx, "requests")
+}
+
+// FindUserPhones returns the phones of the users that have one
+func FindUserPhones(ctx context.Context) ([]*StoredPhone, error) {
+	return findPhones(ctx, "users")
+}
+
+// findPhones reads the phones of table, one of requests or users
+func findPhones(ctx context.Context, table string) ([]*StoredPhone, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id::text AS id, phone, COALESCE(phone_display, '') AS phone_display
+		FROM `+pgx.Identifier{table}.Sanitize()+`
+		WHERE phone IS NOT NULL AND phone <> ''
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[StoredPhone])
+}
+
+// SetRequestPhone stores the normalized phone of the request
+func SetRequestPhone(ctx context.Context, p *StoredPhone) error {
+	return setPhone(ctx, "requests", p)
+}
+
+// SetUserPhone stores the normalized phone of the user
+func SetUserPhone(ctx context.Context, p *StoredPhone) error {
+	return setPhone(ctx, "users", p)
+}
+
+func setPhone(ctx context.Context, table string, p *StoredPhone) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, `
+		UPDATE `+pgx.Identifier{table}.Sanitize()+`
+		SET phone = $2, phone_display = $3
+		WHERE id = $1
+	`, p.Id, p.Phone, p.Display)
+
+	return err
+}
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidPoiCategory  = errors.New("invalid poi category")
+	ErrInvalidPoiProximity = errors.New("invalid poi proximity")
+)
+
+type PoiCategory string
+
+const (
+	PoiCategorySchool      PoiCategory = "escuela"
+	PoiCategoryHospital    PoiCategory = "hospital"
+	PoiCategoryPark        PoiCategory = "parque"
+	PoiCategorySupermarket PoiCategory = "supermercado"
+	PoiCategoryUniversity  PoiCategory = "universidad"
+)
+
+// PoiCategories lists the categories in the order they are displayed
+var PoiCategories = []PoiCategory{
+	PoiCategorySchool,
+	PoiCategoryUniversity,
+	PoiCategoryHospital,
+	PoiCategorySupermarket,
+	PoiCategoryPark,
+}
+
+// poiCategoryAliases maps the values found on public datasets (OSM tags,
+// INEGI DENUE, etc.) to our categories
+var poiCategoryAliases = map[string]PoiCategory{
+	"escuela":      PoiCategorySchool,
+	"escuelas":     PoiCategorySchool,
+	"school":       PoiCategorySchool,
+	"kindergarten": PoiCategorySchool,
+	"primaria":     PoiCategorySchool,
+	"secundaria":   PoiCategorySchool,
+	"preparatoria": PoiCategorySchool,
+	"hospital":     PoiCategoryHospital,
+	"hospitales":   PoiCategoryHospital,
+	"clinic":       PoiCategoryHospital,
+	"clinica":      PoiCategoryHospital,
+	"clínica":      PoiCategoryHospital,
+	"parque":       PoiCategoryPark,
+	"parques":      PoiCategoryPark,
+	"park":         PoiCategoryPark,
+	"supermercado": PoiCategorySupermarket,
+	"supermarket":  PoiCategorySupermarket,
+	"universidad":  PoiCategoryUniversity,
+	"university":   PoiCategoryUniversity,
+	"college":      PoiCategoryUniversity,
+}
+
+// metersPerMile converts the statute miles returned by the earthdistance
+// <@> operator
+const metersPerMile = 1609.344
+
+// MaxPoiDistance is the farthest a POI can be to be shown as nearby
+const MaxPoiDistance = 5000
+
+// ParsePoiCategory returns the category matching s, which may be one of our
+// categories or one of the aliases used by public datasets
+func ParsePoiCategory(s string) (PoiCategory, error) {
+	if c, ok := poiCategoryAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return c, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrInvalidPoiCategory, s)
+}
+
+func (c PoiCategory) Label() string {
+	switch c {
+	case PoiCategorySchool:
+		return "Escuela"
+	case PoiCategoryHospital:
+		return "Hospital"
+	case PoiCategoryPark:
+		return "Parque"
+	case PoiCategorySupermarket:
+		return "Supermercado"
+	case PoiCategoryUniversity:
+		return "Universidad"
+	default:
+		return string(c)
+	}
+}
+
+type Poi struct {
+	Id       string      `json:"id" db:"id"`
+	Name     string      `json:"name" db:"name"`
+	Category PoiCategory `json:"category" db:"category"`
+	Lat      float64     `json:"lat" db:"lat"`
+	Lon      float64     `json:"lon" db:"lon"`
+	Source   string      `json:"source" db:"source"`
+	// Distance in meters, only set by proximity queries
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// FormatDistance returns the distance as "350 m" or "1.2 km"
+func (p *Poi) FormatDistance() string {
+	if p.Distance < 1000 {
+		return fmt.Sprintf("%.0f m", p.Distance)
+	}
+
+	return fmt.Sprintf("%.1f km", p.Distance/1000)
+}
+
+// PoiProximity filters properties within Meters of a POI of Category
+type PoiProximity struct {
+	Category PoiCategory `json:"category"`
+	Meters   int         `json:"meters"`
+}
+
+// ParsePoiProximity parses the "category:meters" format used on query
+// strings, e.g. "escuela:1000" for a school within 1 km
+func ParsePoiProximity(s string) (PoiProximity, error) {
+	catStr, metersStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return PoiProximity{}, fmt.Errorf("%w: %q", ErrInvalidPoiProximity, s)
+	}
+
+	category, err := ParsePoiCategory(catStr)
+	if err != nil {
+		return PoiProximity{}, err
+	}
+
+	meters, err := strconv.Atoi(metersStr)
+	if err != nil || meters <= 0 || meters > MaxPoiDistance {
+		return PoiProximity{}, fmt.Errorf("%w: distance must be between 1 and %d meters", ErrInvalidPoiProximity, MaxPoiDistance)
+	}
+
+	return PoiProximity{Category: category, Meters: meters}, nil
+}
+
+// ImportPois inserts the POIs, the ones already registered (same category,
+// name and coordinates) only get their source updated.
+//
+// When replace is set, the POIs previously imported from source are
+// deleted first, so a dataset can be replaced entirely.
+func ImportPois(ctx context.Context, source string, pois []*Poi, replace bool) (created, updated int, err error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if replace {
+		_, err = tx.Exec(ctx, `DELETE FROM pois WHERE source = $1`, source)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, poi := range pois {
+		if poi.Id == "" {
+			poi.Id = uuid.Must(uuid.NewV7()).String()
+		}
+		poi.Source = source
+
+		var inserted bool
+		err = tx.QueryRow(
+			ctx,
+			`INSERT INTO pois (id, name, category, lat, lon, earth_coords, source)
+			VALUES (@id, @name, @category, @lat, @lon, point(@lon, @lat), @source)
+			ON CONFLICT (category, name, lat, lon) DO UPDATE SET source = EXCLUDED.source
+			RETURNING id, (xmax = 0)`,
+			pgx.NamedArgs{
+				"id":       poi.Id,
+				"name":     poi.Name,
+				"category": poi.Category,
+				"lat":      poi.Lat,
+				"lon":      poi.Lon,
+				"source":   source,
+			},
+		).Scan(&poi.Id, &inserted)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if inserted {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return
+}
+
+// FindNearestPois returns the closest POI of every category within
+// maxMeters of the property, ordered as PoiCategories
+func FindNearestPois(ctx context.Context, propertyId string, maxMeters int) ([]*Poi, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT DISTINCT ON (poi.category)
+			poi.id, poi.name, poi.category, poi.lat, poi.lon, poi.source,
+			(poi.earth_coords <@> p.earth_coords) * $3 AS distance
+		FROM properties p
+		JOIN pois poi ON (poi.earth_coords <@> p.earth_coords) * $3 <= $2
+		WHERE p.id = $1
+			AND p.earth_coords IS NOT NULL
+		ORDER BY poi.category, distance`,
+		propertyId,
+		maxMeters,
+		metersPerMile,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byCategory := map[PoiCategory]*Poi{}
+	for rows.Next() {
+		var poi Poi
+		err = rows.Scan(
+			&poi.Id,
+			&poi.Name,
+			&poi.Category,
+			&poi.Lat,
+			&poi.Lon,
+			&poi.Source,
+			&poi.Distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		byCategory[poi.Category] = &poi
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pois := []*Poi{}
+	for _, c := range PoiCategories {
+		if poi, ok := byCategory[c]; ok {
+			pois = append(pois, poi)
+		}
+	}
+
+	return pois, nil
+}
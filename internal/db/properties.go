@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"log"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +16,8 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
 )
 
 var (
@@ -121,9 +122,15 @@ type Property struct {
 	FeaturedExpiresAt time.Time      `json:"featuredExpiresAt,omitzero" db:"featured_expires_at"`
 	MainImg           string         `json:"mainImg" db:"main_img"`
 	Images            []string       `json:"imgs" db:"imgs"`
-	Agent             string         `json:"agent" db:"agent"`
-	Slug              string         `json:"slug" db:"slug"`
-	AgentData         *AgentData     `json:"agentData" db:"agent_data"`
+	// Resized variants of MainImg and Images, keyed by the image name
+	ImgVariants map[string]imaging.Variants `json:"imgVariants" db:"img_variants"`
+	// Whether the logo is composited over the public pictures
+	Watermark bool `json:"watermark" db:"watermark"`
+	// Details of MainImg and Images, only loaded for a single property
+	Gallery   []*PropertyImage `json:"gallery,omitempty" db:"-"`
+	Agent     string           `json:"agent" db:"agent"`
+	Slug      string           `json:"slug" db:"slug"`
+	AgentData *AgentData       `json:"agentData" db:"agent_data"`
 }
 
 type AgentData struct {
@@ -132,6 +139,11 @@ type AgentData struct {
 	Img   string `json:"img" db:"img"`
 }
 
+// ImgURL returns the URL of the agent's profile picture
+func (a *AgentData) ImgURL() string {
+	return storage.URL(storage.Key("users", a.Img))
+}
+
 func (p *Property) SetSlug() {
 	p.Slug = internal.Slugify(p.Contract + " " + p.Address + " " + p.NbHood + " " + p.Zip + " " + p.City + " " + p.State + " " + fmt.Sprint(p.YearBuilt))
 }
@@ -150,17 +162,6 @@ func (p *Property) SyncLatLon() {
 	}
 }
 
-func (p *Property) CreateStaticDir() error {
-	staticDir := "web/static/properties/" + p.Id
-	err := os.Mkdir(staticDir, 0755)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (p *Property) ToMap() map[string]any {
 	var data map[string]any
 
@@ -202,6 +203,9 @@ type PropertyFilter struct {
 	NearLat      *float64 `json:"nearLat"`
 	NearLon      *float64 `json:"nearLon"`
 	WithinMeters *int     `json:"withinMeters"`
+	// Every entry must be satisfied, e.g. a school within 1km and a park
+	// within 500m
+	NearPois []PoiProximity `json:"nearPois"`
 }
 
 func NewPropertyFilter() *PropertyFilter {
@@ -261,6 +265,11 @@ func NewPropertyFilterFromQuery(query *url.Values) *PropertyFilter {
 		maxYearBuiltInt, _ := strconv.Atoi(maxYearBuilt)
 		filter.MaxYearBuilt = &maxYearBuiltInt
 	}
+	for _, poi := range (*query)["poi"] {
+		if proximity, err := ParsePoiProximity(poi); err == nil {
+			filter.NearPois = append(filter.NearPois, proximity)
+		}
+	}
 	return filter
 }
 
@@ -376,6 +385,7 @@ func UpdateProperty(property *Property) error {
 		"lon":          property.Lon,
 		"nb_hood":      property.NbHood,
 		"slug":         property.Slug,
+		"sold":         PropertyStatusSold,
 	}
 	_, err = conn.Exec(
 		ctx,
@@ -401,7 +411,12 @@ func UpdateProperty(property *Property) error {
             lat = @lat,
             lon = @lon,
             nb_hood = @nb_hood,
-            slug = @slug
+            slug = @slug,
+            sold_at = CASE
+                WHEN @status = @sold AND status <> @sold THEN NOW()
+                WHEN @status <> @sold THEN NULL
+                ELSE sold_at
+            END
         WHERE id = @id`,
 		args,
 	)
@@ -539,6 +554,16 @@ func buildFilterConditions(filter *PropertyFilter) ([]string, []any, int) {
 		nextParamIdx += 3
 	}
 
+	for _, proximity := range filter.NearPois {
+		queryConditions = append(queryConditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM pois
+			WHERE pois.category = $%d
+				AND (pois.earth_coords <@> properties.earth_coords) * %f <= $%d
+		)`, nextParamIdx, metersPerMile, nextParamIdx+1))
+		queryParams = append(queryParams, proximity.Category, proximity.Meters)
+		nextParamIdx += 2
+	}
+
 	// Full-text search
 	if filter.TextSearch != nil && *filter.TextSearch != "" {
 		queryConditions = append(queryConditions, fmt.Sprintf(`
@@ -608,7 +633,7 @@ func GetProperties(ctx context.Context, filter *PropertyFilter, limit, page int)
 			id, address, description, city, state, zip, country, price, property_type,
 			beds, baths, square_mt, lot_size, year_built, listing_date, status,
 			features, lat, lon, contract, nb_hood, main_img, imgs, agent, slug,
-			earth_coords
+			earth_coords, COALESCE(img_variants, '{}'), watermark
 		FROM properties WHERE 1=1
 	`
 
@@ -678,6 +703,8 @@ func GetProperties(ctx context.Context, filter *PropertyFilter, limit, page int)
 			&prop.Agent,
 			&prop.Slug,
 			&prop.Coords,
+			&prop.ImgVariants,
+			&prop.Watermark,
 		)
 
 		if err != nil {
@@ -729,7 +756,7 @@ func FindFeaturedProperties() ([]*Property, error) {
 		`SELECT
             id, address, city, state, zip, nb_hood, price, property_type, contract,
             beds, baths, square_mt, lot_size, listing_date, main_img, imgs, slug,
-            lat, lon
+            lat, lon, COALESCE(img_variants, '{}')
         FROM properties
         WHERE featured = true
         ORDER BY listing_date DESC
@@ -763,6 +790,7 @@ func FindFeaturedProperties() ([]*Property, error) {
 			&property.Slug,
 			&property.Lat,
 			&property.Lon,
+			&property.ImgVariants,
 		)
 		if err != nil {
 			return nil, err
@@ -802,6 +830,7 @@ func FindNearbyProperties(id string, nearbyDistance int) ([]*Property, error) {
 		`SELECT
 			p2.id, p2.address, p2.city, p2.state, p2.zip, p2.price,
 			p2.beds, p2.baths, p2.square_mt, p2.main_img, p2.contract, p2.nb_hood,
+			COALESCE(p2.img_variants, '{}'),
 			(p1.earth_coords <@> p2.earth_coords) as distance
 		FROM properties p1
 		CROSS JOIN properties p2
@@ -839,6 +868,7 @@ func FindNearbyProperties(id string, nearbyDistance int) ([]*Property, error) {
 			&prop.MainImg,
 			&prop.Contract,
 			&prop.NbHood,
+			&prop.ImgVariants,
 			&distance,
 		)
 
@@ -869,8 +899,9 @@ func FindPropertyById(ctx context.Context, propId string) (property *Property, e
             p.year_built, p.listing_date, p.status, p.earth_coords, p.features,
 			p.lat, p.lon, p.contract, p.featured, p.featured_expires_at,
             p.nb_hood, p.main_img, p.imgs, p.agent, p.slug,
+			COALESCE(p.img_variants, '{}'), p.watermark,
 			u.fullname AS agent_name,
-			u.phone AS agent_number,
+			COALESCE(NULLIF(u.phone_display, ''), u.phone) AS agent_number,
 			u.img AS agent_img
 		FROM properties p
 		LEFT JOIN users u ON p.agent = u.id
@@ -914,6 +945,8 @@ func FindPropertyById(ctx context.Context, propId string) (property *Property, e
 		&property.Images,
 		&property.Agent,
 		&property.Slug,
+		&property.ImgVariants,
+		&property.Watermark,
 		&property.AgentData.Name,
 		&phone,
 		&img,
@@ -944,6 +977,8 @@ func FindPropertyById(ctx context.Context, propId string) (property *Property, e
 	// Sync lat/lon from coords
 	property.SyncLatLon()
 
+	property.Gallery, err = FindPropertyImages(ctx, property.Id)
+
 	return
 }
 
@@ -962,8 +997,9 @@ func FindPropertyBySlug(ctx context.Context, slug string) (property *Property, e
 			p.id, p.address, p.description, p.city, p.state, p.zip, p.country, p.price, p.property_type,
 			p.beds, p.baths, p.square_mt, p.lot_size, p.year_built, p.listing_date, p.status, p.earth_coords, p.features,
 			p.lat, p.lon, p.contract, p.featured, p.featured_expires_at, p.nb_hood, p.main_img, p.imgs, p.agent, p.slug,
+			COALESCE(p.img_variants, '{}'), p.watermark,
 			u.fullname AS agent_name,
-			u.phone AS agent_number,
+			COALESCE(NULLIF(u.phone_display, ''), u.phone) AS agent_number,
 			u.img AS agent_img
 		FROM properties p
 		LEFT JOIN users u ON p.agent = u.id
@@ -1007,6 +1043,8 @@ func FindPropertyBySlug(ctx context.Context, slug string) (property *Property, e
 		&property.Images,
 		&property.Agent,
 		&property.Slug,
+		&property.ImgVariants,
+		&property.Watermark,
 		&property.AgentData.Name,
 		&phone,
 		&img,
@@ -1034,6 +1072,8 @@ func FindPropertyBySlug(ctx context.Context, slug string) (property *Property, e
 	// Sync lat/lon from coords
 	property.SyncLatLon()
 
+	property.Gallery, err = FindPropertyImages(ctx, property.Id)
+
 	return
 }
 
@@ -1052,59 +1092,42 @@ func UpdatePropertyImages(property *Property) error {
 	defer tx.Rollback(ctx)
 
 	args := pgx.NamedArgs{
-		"images":   property.Images,
-		"main_img": property.MainImg,
-		"id":       property.Id,
+		"images":       property.Images,
+		"main_img":     property.MainImg,
+		"img_variants": property.ImgVariants,
+		"id":           property.Id,
 	}
 
 	_, err = tx.Exec(
 		ctx,
-		"UPDATE properties SET imgs = @images, main_img = @main_img WHERE id = @id",
+		"UPDATE properties SET imgs = @images, main_img = @main_img, img_variants = @img_variants WHERE id = @id",
 		args,
 	)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit(ctx)
-}
-
-func UpdatePropertyImgs(id string, images []string) error {
-	conn, err := GetPool()
-	if err != nil {
-		return err
-	}
-	defer conn.Release()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	_, err = conn.Exec(ctx, "UPDATE properties SET imgs = $1 WHERE id = $2", images, id)
-
+	err = syncPropertyImages(ctx, tx, property)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit(ctx)
 }
 
-func UpdatePropertyMainImg(id, img string) error {
-	conn, err := GetPool()
+func UpdatePropertyImgVariants(ctx context.Context, id string, variants map[string]imaging.Variants) error {
+	conn, err := GetPoolWithCtx(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	_, err = conn.Exec(ctx, "UPDATE properties SET main_img = $1 WHERE id = $2", img, id)
+	_, err = conn.Exec(ctx, "UPDATE properties SET img_variants = $1 WHERE id = $2", variants, id)
 
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
 func DeletePropertyById(id string) error {
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+// MaxDocumentSize is the largest attachment accepted
+const MaxDocumentSize = 20 << 20
+
+var ErrInvalidDocumentKind = errors.New("invalid document kind")
+
+// DocumentKind is the type of a property attachment
+type DocumentKind string
+
+const (
+	DocumentPlano     DocumentKind = "plano"
+	DocumentEscritura DocumentKind = "escritura"
+	DocumentUsoSuelo  DocumentKind = "uso_suelo"
+	DocumentFolleto   DocumentKind = "folleto"
+	DocumentOtro      DocumentKind = "otro"
+)
+
+// DocumentKinds are the document kinds in the order they are listed
+var DocumentKinds = []DocumentKind{
+	DocumentPlano,
+	DocumentEscritura,
+	DocumentUsoSuelo,
+	DocumentFolleto,
+	DocumentOtro,
+}
+
+var documentKindLabels = map[DocumentKind]string{
+	DocumentPlano:     "Plano",
+	DocumentEscritura: "Escritura",
+	DocumentUsoSuelo:  "Constancia de uso de suelo",
+	DocumentFolleto:   "Folleto",
+	DocumentOtro:      "Otro",
+}
+
+func ParseDocumentKind(s string) (DocumentKind, error) {
+	kind := DocumentKind(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := documentKindLabels[kind]; !ok {
+		return "", ErrInvalidDocumentKind
+	}
+
+	return kind, nil
+}
+
+func (k DocumentKind) Label() string {
+	return documentKindLabels[k]
+}
+
+// PropertyDocument is a file attached to a property. Only the public
+// documents are shown on the property page, the rest can only be
+// downloaded by the agents.
+type PropertyDocument struct {
+	Id          string       `json:"id" db:"id"`
+	PropertyId  string       `json:"propertyId" db:"property_id"`
+	Kind        DocumentKind `json:"kind" db:"kind"`
+	Title       string       `json:"title" db:"title"`
+	FileName    string       `json:"fileName" db:"file_name"`
+	ContentType string       `json:"contentType" db:"content_type"`
+	Size        int64        `json:"size" db:"size"`
+	Public      bool         `json:"public" db:"public"`
+	UploadedBy  *string      `json:"uploadedBy" db:"uploaded_by"`
+	CreatedAt   time.Time    `json:"createdAt" db:"created_at"`
+}
+
+// DocumentsPrefix is the prefix of the property documents in the Private
+// store
+func (p *Property) DocumentsPrefix() string {
+	return storage.Key("documents", p.MediaPrefix())
+}
+
+// Key is the key of the file in the Private store
+func (d *PropertyDocument) Key() string {
+	return storage.Key((&Property{Id: d.PropertyId}).DocumentsPrefix(), d.Id+path.Ext(d.FileName))
+}
+
+// URL is the download link, private documents are served under /admin
+// so only the agents can download them
+func (d *PropertyDocument) URL() string {
+	if d.Public {
+		return "/documentos/" + d.Id
+	}
+	return "/admin/documentos/" + d.Id
+}
+
+func (d *PropertyDocument) IsImage() bool {
+	return strings.HasPrefix(d.ContentType, "image/")
+}
+
+// DisplayTitle returns the title, or the kind when it has none
+func (d *PropertyDocument) DisplayTitle() string {
+	if d.Title != "" {
+		return d.Title
+	}
+	return d.Kind.Label()
+}
+
+func (d *PropertyDocument) FormatSize() string {
+	if d.Size < 1<<20 {
+		return fmt.Sprintf("%d KB", max(1, d.Size>>10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(d.Size)/(1<<20))
+}
+
+const propertyDocumentColumns = `
+	id, property_id, kind, title, file_name, content_type, size, public, uploaded_by, created_at
+`
+
+func CreatePropertyDocument(ctx context.Context, doc *PropertyDocument) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO property_documents (`+propertyDocumentColumns+`)
+		VALUES (@id, @property_id, @kind, @title, @file_name, @content_type, @size, @public, @uploaded_by, NOW())
+	`, pgx.NamedArgs{
+		"id":           doc.Id,
+		"property_id":  doc.PropertyId,
+		"kind":         doc.Kind,
+		"title":        doc.Title,
+		"file_name":    doc.FileName,
+		"content_type": doc.ContentType,
+		"size":         doc.Size,
+		"public":       doc.Public,
+		"uploaded_by":  doc.UploadedBy,
+	})
+
+	return err
+}
+
+// FindPropertyDocuments returns the documents of the property, only the
+// public ones when publicOnly is set
+func FindPropertyDocuments(ctx context.Context, propertyId string, publicOnly bool) ([]*PropertyDocument, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+propertyDocumentColumns+`
+		FROM property_documents
+		WHERE property_id = $1 AND (public OR NOT $2)
+		ORDER BY kind, created_at
+	`, propertyId, publicOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[PropertyDocument])
+}
+
+func FindPropertyDocumentById(ctx context.Context, id string) (*PropertyDocument, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+propertyDocumentColumns+" FROM property_documents WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[PropertyDocument])
+}
+
+func DeletePropertyDocument(ctx context.Context, id string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM property_documents WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,564 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+// MaxPropertyImages is the most gallery pictures a property can have, the
+// main picture is not counted
+const MaxPropertyImages = 12
+
+// DuplicateMaxDistance is the most bits two hashes can differ for the
+// photos to be reported as a possible duplicate
+const DuplicateMaxDistance = 10
+
+var (
+	ErrInvalidRoomTag        = errors.New("invalid room tag")
+	ErrInvalidImageOrder     = errors.New("the order must contain every gallery image once")
+	ErrPropertyImageNotFound = errors.New("the image does not belong to the property")
+)
+
+// RoomTag is the part of the property shown in a picture
+type RoomTag string
+
+const (
+	RoomFachada    RoomTag = "fachada"
+	RoomSala       RoomTag = "sala"
+	RoomComedor    RoomTag = "comedor"
+	RoomCocina     RoomTag = "cocina"
+	RoomRecamara   RoomTag = "recamara"
+	RoomBano       RoomTag = "bano"
+	RoomEstudio    RoomTag = "estudio"
+	RoomLavanderia RoomTag = "lavanderia"
+	RoomCochera    RoomTag = "cochera"
+	RoomJardin     RoomTag = "jardin"
+	RoomTerraza    RoomTag = "terraza"
+	RoomAlberca    RoomTag = "alberca"
+	RoomAmenidades RoomTag = "amenidades"
+	RoomVista      RoomTag = "vista"
+)
+
+// RoomTags are the room tags in the order they are listed
+var RoomTags = []RoomTag{
+	RoomFachada,
+	RoomSala,
+	RoomComedor,
+	RoomCocina,
+	RoomRecamara,
+	RoomBano,
+	RoomEstudio,
+	RoomLavanderia,
+	RoomCochera,
+	RoomJardin,
+	RoomTerraza,
+	RoomAlberca,
+	RoomAmenidades,
+	RoomVista,
+}
+
+var roomTagLabels = map[RoomTag]string{
+	RoomFachada:    "Fachada",
+	RoomSala:       "Sala",
+	RoomComedor:    "Comedor",
+	RoomCocina:     "Cocina",
+	RoomRecamara:   "Recámara",
+	RoomBano:       "Baño",
+	RoomEstudio:    "Estudio",
+	RoomLavanderia: "Lavandería",
+	RoomCochera:    "Cochera",
+	RoomJardin:     "Jardín",
+	RoomTerraza:    "Terraza",
+	RoomAlberca:    "Alberca",
+	RoomAmenidades: "Amenidades",
+	RoomVista:      "Vista",
+}
+
+// ParseRoomTag validates a room tag, an empty string means no tag
+func ParseRoomTag(s string) (RoomTag, error) {
+	tag := RoomTag(strings.ToLower(strings.TrimSpace(s)))
+	if tag == "" {
+		return "", nil
+	}
+	if _, ok := roomTagLabels[tag]; !ok {
+		return "", ErrInvalidRoomTag
+	}
+
+	return tag, nil
+}
+
+func (t RoomTag) Label() string {
+	return roomTagLabels[t]
+}
+
+// PropertyImage holds the details of a picture of the gallery. Position
+// orders the gallery, the main picture is not part of it.
+type PropertyImage struct {
+	Id         string  `json:"id" db:"id"`
+	PropertyId string  `json:"propertyId" db:"property_id"`
+	FileName   string  `json:"fileName" db:"file_name"`
+	Position   int     `json:"position" db:"position"`
+	IsMain     bool    `json:"isMain" db:"is_main"`
+	Caption    string  `json:"caption" db:"caption"`
+	AltText    string  `json:"altText" db:"alt_text"`
+	Room       RoomTag `json:"room" db:"room"`
+	// The dHash of the original, see imaging.DHash. The uint64 is stored
+	// with its bits as they are in a bigint.
+	PHash     *int64    `json:"-" db:"phash"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// MediaPrefix is the storage prefix of the property pictures
+func (p *Property) MediaPrefix() string {
+	return storage.Key("properties", p.Id)
+}
+
+// OriginalKey is the key of the unwatermarked original of img in the
+// Private store
+func (p *Property) OriginalKey(img string) string {
+	return storage.Key("originals", p.MediaPrefix(), img)
+}
+
+// ImgURL returns the URL of the original upload
+func (p *Property) ImgURL(img string) string {
+	return storage.URL(storage.Key(p.MediaPrefix(), img))
+}
+
+// ImgSrc returns the URL used as the src fallback of img, the largest JPEG
+// variant or the original when the picture has not been processed
+func (p *Property) ImgSrc(img string) string {
+	v, ok := p.ImgVariants[img]
+	if !ok || !v.Has(imaging.FormatJPEG) || v.Largest() == 0 {
+		return p.ImgURL(img)
+	}
+
+	return storage.URL(storage.Key(p.MediaPrefix(), imaging.VariantName(img, v.Largest(), imaging.FormatJPEG)))
+}
+
+// ImgSrcset returns the srcset attribute of img in format, or an empty
+// string when there are no variants in that format
+func (p *Property) ImgSrcset(img string, format string) string {
+	v, ok := p.ImgVariants[img]
+	if !ok || !v.Has(format) {
+		return ""
+	}
+
+	candidates := make([]string, 0, len(v.Widths))
+	for _, w := range v.Widths {
+		candidates = append(candidates, fmt.Sprintf(
+			"%s %dw",
+			storage.URL(storage.Key(p.MediaPrefix(), imaging.VariantName(img, w, format))),
+			w,
+		))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// HasWebP reports whether img has WebP variants
+func (p *Property) HasWebP(img string) bool {
+	v, ok := p.ImgVariants[img]
+	return ok && v.Has(imaging.FormatWebP)
+}
+
+// ImageDetails returns the details of img, nil if it has none
+func (p *Property) ImageDetails(img string) *PropertyImage {
+	for _, details := range p.Gallery {
+		if details.FileName == img {
+			return details
+		}
+	}
+
+	return nil
+}
+
+// ImgAlt returns the alt text of img, falling back to its caption and then
+// to a description built from the address
+func (p *Property) ImgAlt(img string) string {
+	if details := p.ImageDetails(img); details != nil {
+		if details.AltText != "" {
+			return details.AltText
+		}
+		if details.Caption != "" {
+			return details.Caption
+		}
+		if details.Room != "" {
+			return details.Room.Label() + " de la propiedad en " + p.Address
+		}
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("Fotografia de la propiedad en %s, %s %s", p.Address, p.NbHood, p.Zip))
+}
+
+// SetMainImg promotes img from the gallery to main picture, the previous
+// main picture takes its place in the gallery
+func (p *Property) SetMainImg(img string) error {
+	idx := slices.Index(p.Images, img)
+	if idx < 0 {
+		return ErrPropertyImageNotFound
+	}
+
+	if p.MainImg != "" {
+		p.Images[idx] = p.MainImg
+	} else {
+		p.Images = slices.Delete(p.Images, idx, idx+1)
+	}
+	p.MainImg = img
+
+	return nil
+}
+
+// ReorderImages sets the gallery order, order must contain the same images
+func (p *Property) ReorderImages(order []string) error {
+	if len(order) != len(p.Images) {
+		return ErrInvalidImageOrder
+	}
+	for _, img := range order {
+		if !slices.Contains(p.Images, img) {
+			return ErrInvalidImageOrder
+		}
+	}
+	sorted := slices.Clone(order)
+	slices.Sort(sorted)
+	if len(slices.Compact(sorted)) != len(order) {
+		return ErrInvalidImageOrder
+	}
+
+	p.Images = slices.Clone(order)
+	return nil
+}
+
+// AllImages returns the main picture followed by the gallery
+func (p *Property) AllImages() []string {
+	images := make([]string, 0, len(p.Images)+1)
+	if p.MainImg != "" {
+		images = append(images, p.MainImg)
+	}
+	for _, img := range p.Images {
+		if img != "" {
+			images = append(images, img)
+		}
+	}
+
+	return images
+}
+
+// CoverImg returns the main picture, or the first of the gallery when no
+// main picture was uploaded
+func (p *Property) CoverImg() string {
+	if p.MainImg != "" {
+		return p.MainImg
+	}
+	if len(p.Images) > 0 {
+		return p.Images[0]
+	}
+
+	return ""
+}
+
+// MarshalJSON adds the URL of the cover picture, used by the scripts that
+// render properties on the client (e.g. the map cards)
+func (p Property) MarshalJSON() ([]byte, error) {
+	type property Property
+
+	coverURL := ""
+	if cover := p.CoverImg(); cover != "" {
+		coverURL = p.ImgSrc(cover)
+	}
+
+	return json.Marshal(struct {
+		property
+		CoverURL string `json:"coverUrl"`
+	}{property(p), coverURL})
+}
+
+func FindPropertyImages(ctx context.Context, propertyId string) ([]*PropertyImage, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, property_id, file_name, position, is_main, caption, alt_text, room, phash, created_at
+		FROM property_images
+		WHERE property_id = $1
+		ORDER BY is_main DESC, position
+	`, propertyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[PropertyImage])
+}
+
+// syncPropertyImages makes the rows of property_images match the gallery
+// order and main picture of property. The details of the images that
+// remain are kept.
+func syncPropertyImages(ctx context.Context, tx pgx.Tx, property *Property) error {
+	files := slices.Clone(property.Images)
+	if property.MainImg != "" {
+		files = append(files, property.MainImg)
+	}
+
+	_, err := tx.Exec(
+		ctx,
+		"DELETE FROM property_images WHERE property_id = $1 AND NOT (file_name = ANY($2))",
+		property.Id,
+		files,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Clear the main flag first so the unique index holds while swapping
+	_, err = tx.Exec(ctx, "UPDATE property_images SET is_main = false WHERE property_id = $1 AND is_main", property.Id)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	upsert := `
+		INSERT INTO property_images (id, property_id, file_name, position, is_main)
+		VALUES (@id, @property_id, @file_name, @position, @is_main)
+		ON CONFLICT (property_id, file_name) DO UPDATE
+		SET position = EXCLUDED.position, is_main = EXCLUDED.is_main
+	`
+	for i, img := range property.Images {
+		batch.Queue(upsert, pgx.NamedArgs{
+			"id":          uuid.Must(uuid.NewV7()).String(),
+			"property_id": property.Id,
+			"file_name":   img,
+			"position":    i,
+			"is_main":     false,
+		})
+	}
+	if property.MainImg != "" {
+		batch.Queue(upsert, pgx.NamedArgs{
+			"id":          uuid.Must(uuid.NewV7()).String(),
+			"property_id": property.Id,
+			"file_name":   property.MainImg,
+			"position":    0,
+			"is_main":     true,
+		})
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+func UpdatePropertyImageDetails(ctx context.Context, details *PropertyImage) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, `
+		UPDATE property_images
+		SET caption = @caption, alt_text = @alt_text, room = @room
+		WHERE property_id = @property_id AND file_name = @file_name
+	`, pgx.NamedArgs{
+		"caption":     details.Caption,
+		"alt_text":    details.AltText,
+		"room":        details.Room,
+		"property_id": details.PropertyId,
+		"file_name":   details.FileName,
+	})
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrPropertyImageNotFound
+	}
+
+	return nil
+}
+
+func UpdatePropertyWatermark(ctx context.Context, id string, watermark bool) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "UPDATE properties SET watermark = $1 WHERE id = $2", watermark, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindPropertiesMedia returns every property with only the fields that
+// reference stored files, used to reconcile the storage with the database
+func FindPropertiesMedia(ctx context.Context) ([]*Property, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, COALESCE(main_img, ''), COALESCE(imgs, '{}'), COALESCE(img_variants, '{}')
+		FROM properties
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []*Property{}
+	for rows.Next() {
+		prop := &Property{}
+		err = rows.Scan(&prop.Id, &prop.MainImg, &prop.Images, &prop.ImgVariants)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, prop)
+	}
+
+	return properties, rows.Err()
+}
+
+// UpdatePropertyImageHashes stores the perceptual hashes of the pictures,
+// keyed by file name
+func UpdatePropertyImageHashes(ctx context.Context, propertyId string, hashes map[string]uint64) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	batch := &pgx.Batch{}
+	for fileName, hash := range hashes {
+		batch.Queue(
+			"UPDATE property_images SET phash = $1 WHERE property_id = $2 AND file_name = $3",
+			int64(hash),
+			propertyId,
+			fileName,
+		)
+	}
+
+	return conn.SendBatch(ctx, batch).Close()
+}
+
+// ImageMatch is a pair of similar photos of different properties
+type ImageMatch struct {
+	PropertyId      string `json:"propertyId"`
+	PropertyAddress string `json:"propertyAddress"`
+	FileName        string `json:"fileName"`
+	OtherPropertyId string `json:"otherPropertyId"`
+	OtherAddress    string `json:"otherAddress"`
+	OtherFileName   string `json:"otherFileName"`
+	OtherAgentName  string `json:"otherAgentName"`
+	// Number of different bits of the hashes, 0 is the same photo
+	Distance int `json:"distance"`
+}
+
+func (m *ImageMatch) ImgURL() string {
+	return (&Property{Id: m.PropertyId}).ImgURL(m.FileName)
+}
+
+func (m *ImageMatch) OtherImgURL() string {
+	return (&Property{Id: m.OtherPropertyId}).ImgURL(m.OtherFileName)
+}
+
+// FindDuplicateImages returns the photos of propertyId that look like the
+// photos of another property. With an empty propertyId every pair in the
+// site is returned once, for the admin report.
+func FindDuplicateImages(ctx context.Context, propertyId string, maxDistance int) ([]*ImageMatch, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The hamming distance is the count of 1s of the xor, bit_count needs
+	// Postgres 14 so they are counted on the text representation.
+	// Hashes of solid images (all 0s or all 1s) are ignored.
+	rows, err := conn.Query(ctx, `
+		SELECT
+			a.property_id, pa.address, a.file_name,
+			b.property_id, pb.address, b.file_name,
+			COALESCE(u.fullname, ''),
+			d.distance
+		FROM property_images a
+		JOIN property_images b ON b.property_id <> a.property_id AND b.phash IS NOT NULL
+		JOIN properties pa ON pa.id = a.property_id
+		JOIN properties pb ON pb.id = b.property_id
+		LEFT JOIN users u ON u.id = pb.agent
+		CROSS JOIN LATERAL (
+			SELECT length(replace((a.phash # b.phash)::bit(64)::text, '0', '')) AS distance
+		) d
+		WHERE a.phash IS NOT NULL
+			AND a.phash NOT IN (0, -1)
+			AND d.distance <= @max_distance
+			AND (
+				(@property_id <> '' AND a.property_id::text = @property_id)
+				OR (@property_id = '' AND a.property_id < b.property_id)
+			)
+		ORDER BY d.distance, pa.address, a.file_name
+	`, pgx.NamedArgs{
+		"property_id":  propertyId,
+		"max_distance": maxDistance,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []*ImageMatch{}
+	for rows.Next() {
+		m := &ImageMatch{}
+		err = rows.Scan(
+			&m.PropertyId, &m.PropertyAddress, &m.FileName,
+			&m.OtherPropertyId, &m.OtherAddress, &m.OtherFileName,
+			&m.OtherAgentName,
+			&m.Distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// HitRateLimit records a hit of the key at now and returns the hits of its
+// window. The window starts with the first hit and lasts window, the next
+// hit after it starts a new one.
+func HitRateLimit(ctx context.Context, key string, window time.Duration, now time.Time) (int, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var count int
+	err = conn.QueryRow(ctx, `
+		INSERT INTO rate_limits (key, count, expires_at)
+		VALUES ($1, 1, $2::timestamptz + $3::interval)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limits.expires_at <= $2 THEN 1 ELSE rate_limits.count + 1 END,
+			expires_at = CASE WHEN rate_limits.expires_at <= $2 THEN EXCLUDED.expires_at ELSE rate_limits.expires_at END
+		RETURNING count
+	`, key, now, window).Scan(&count)
+
+	return count, err
+}
+
+// DeleteExpiredRateLimits removes the keys whose window ended before
+// before, it returns how many were removed
+func DeleteExpiredRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM rate_limits WHERE expires_at <= $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
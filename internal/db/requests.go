@@ -3,7 +3,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,6 +20,8 @@ const (
 	RequestTypeQuote RequestType = "cita"
 )
 
+var RequestTypes = []RequestType{RequestTypeInfo, RequestTypeQuote}
+
 type RequestStatus string
 
 const (
@@ -25,18 +29,113 @@ const (
 	RequestStatusConfirmed RequestStatus = "confirmada"
 	RequestStatusDone      RequestStatus = "atendida"
 	RequestStatusRepeat    RequestStatus = "volver a atender"
+	// Requests from the site that look automated, they are left out of
+	// the inbox and no agent is notified of them until they are released
+	RequestStatusSpam RequestStatus = "spam"
+)
+
+var (
+	ErrInvalidRequestStatus = errors.New("invalid request status")
+	ErrInvalidRequestType   = errors.New("invalid request type")
+	ErrRequestChanged       = errors.New("the request changed since it was read")
 )
 
+// RequestStatuses are the request statuses in the order they are listed
+var RequestStatuses = []RequestStatus{
+	RequestStatusPending,
+	RequestStatusConfirmed,
+	RequestStatusDone,
+	RequestStatusRepeat,
+}
+
+// requestTransitions are the statuses a request can be moved to from
+// each status. A request that must be attended again goes back to being
+// confirmed or is marked as attended.
+var requestTransitions = map[RequestStatus][]RequestStatus{
+	RequestStatusPending:   {RequestStatusConfirmed},
+	RequestStatusConfirmed: {RequestStatusDone, RequestStatusRepeat},
+	RequestStatusDone:      {RequestStatusRepeat},
+	RequestStatusRepeat:    {RequestStatusConfirmed, RequestStatusDone},
+}
+
+func ParseRequestStatus(s string) (RequestStatus, error) {
+	status := RequestStatus(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := requestTransitions[status]; !ok {
+		return "", ErrInvalidRequestStatus
+	}
+
+	return status, nil
+}
+
+func (s RequestStatus) Label() string {
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(string(s[:1])) + string(s[1:])
+}
+
+// Next returns the statuses the request can be moved to
+func (s RequestStatus) Next() []RequestStatus {
+	return requestTransitions[s]
+}
+
+func (s RequestStatus) CanMoveTo(next RequestStatus) bool {
+	return slices.Contains(requestTransitions[s], next)
+}
+
+func ParseRequestType(s string) (RequestType, error) {
+	switch t := RequestType(strings.ToLower(strings.TrimSpace(s))); t {
+	case RequestTypeInfo, RequestTypeQuote:
+		return t, nil
+	}
+
+	return "", ErrInvalidRequestType
+}
+
+func (t RequestType) Label() string {
+	if t == RequestTypeQuote {
+		return "Visita"
+	}
+	return "Información"
+}
+
 type Request struct {
 	Id            string        `json:"id" db:"id"`
 	Type          RequestType   `json:"type" db:"type"`
-	Phone         string        `json:"phone" db:"phone"`
+	Phone         string        `json:"phone" db:"phone"` // E.164
+	PhoneDisplay  string        `json:"phoneDisplay" db:"phone_display"`
 	Name          string        `json:"name" db:"name"`
+	Email         string        `json:"email,omitempty" db:"email"` // Optional
 	ScheduledDate time.Time     `json:"scheduledDate" db:"scheduled_date"`
 	Status        RequestStatus `json:"status" db:"status"`
 	Agent         string        `json:"agent" db:"agent"`
 	Property      string        `json:"property,omitempty" db:"property"`
-	WspSent       bool          `json:"wspSent" db:"wsp_sent"`
+	// Contact is linked by the phone or email when the request is created,
+	// or when it is released from the quarantine
+	Contact string `json:"contact,omitempty" db:"contact"`
+	WspSent bool   `json:"wspSent" db:"wsp_sent"`
+	// Why the request was quarantined, only set while its status is spam
+	SpamReason string `json:"spamReason,omitempty" db:"spam_reason"`
+	// First visit of the client to the site, from the attribution cookie
+	Attribution Attribution `json:"attribution"`
+
+	// When Agent was assigned and every agent the request was assigned to,
+	// Agent included
+	AssignedAt  time.Time `json:"assignedAt" db:"assigned_at"`
+	TriedAgents []string  `json:"triedAgents" db:"tried_agents"`
+
+	// When the request was first answered and when the SLA reminder and
+	// escalation were sent, zero until then
+	FirstResponseAt time.Time `json:"firstResponseAt" db:"first_response_at"`
+	SLARemindedAt   time.Time `json:"slaRemindedAt" db:"sla_reminded_at"`
+	SLAEscalatedAt  time.Time `json:"slaEscalatedAt" db:"sla_escalated_at"`
+
+	// Only set when the request is read, from the agent and property rows
+	AgentName        string  `json:"agentName,omitempty" db:"agent_name"`
+	PropertyAddress  string  `json:"propertyAddress,omitempty" db:"property_address"`
+	PropertyContract string  `json:"propertyContract,omitempty" db:"property_contract"`
+	PropertyLat      float64 `json:"propertyLat,omitempty" db:"property_lat"`
+	PropertyLon      float64 `json:"propertyLon,omitempty" db:"property_lon"`
 
 	CreatedAt time.Time `json:"date" db:"date"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
@@ -51,27 +150,18 @@ func NewRequest(reqType RequestType) *Request {
 	}
 }
 
-type QuoteSchedule string
-
-const (
-	QuoteScheduleWeekend QuoteSchedule = "fin de semana"
-	QuoteScheduleMidWeek QuoteSchedule = "entre semana"
-	QuoteScheduleOther   QuoteSchedule = "otro"
-)
-
-type ConqsRequest struct {
-	Request
-
-	Schedule QuoteSchedule `json:"quoteSchedule" db:"quote_schedule"`
-}
-
 type RequestFilter struct {
 	Type          *RequestType
 	ScheduledDate *time.Time
 	Status        *RequestStatus
 	Agent         *string
 	Property      *string
+	Contact       *string
 	CreatedAt     *time.Time
+	// Range of the creation date, the start of the first and last days
+	// included
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
 }
 
 func CreateRequest(ctx context.Context, req *Request) error {
@@ -85,13 +175,39 @@ func CreateRequest(ctx context.Context, req *Request) error {
 	defer cancel()
 
 	req.Id = uuid.Must(uuid.NewV7()).String()
+	err = conn.QueryRow(ctx, insertRequestQuery, insertRequestArgs(req)).Scan(&req.Contact)
 
-	args := pgx.NamedArgs{
+	return err
+}
+
+// insertRequestQuery creates the request linked to the contact with its
+// phone or email, the contact is created when there is none. The
+// quarantined requests are not linked until they are released. It returns
+// the id of the contact, empty when it was not linked.
+const insertRequestQuery = `
+	INSERT INTO
+		requests (id, type, phone, phone_display, name, email, status, agent, scheduled_date, property, assigned_at, tried_agents, spam_reason,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content, referrer, landing_page, first_touch_at, contact)
+	VALUES (@id, @type, @phone, @phone_display, @name, NULLIF(@email, ''), @status, @agent, @scheduled_date, @property, @assigned_at,
+		COALESCE(@tried_agents::text[], '{}')::uuid[], @spam_reason,
+		NULLIF(@utm_source, ''), NULLIF(@utm_medium, ''), NULLIF(@utm_campaign, ''), NULLIF(@utm_term, ''),
+		NULLIF(@utm_content, ''), NULLIF(@referrer, ''), NULLIF(@landing_page, ''), @first_touch_at,
+		CASE WHEN @status <> @spam_status THEN request_contact(@contact_id, @phone, @email, @name) END)
+	RETURNING COALESCE(contact::text, '')
+`
+
+func insertRequestArgs(req *Request) pgx.NamedArgs {
+	return pgx.NamedArgs{
 		"id":     req.Id,
 		"type":   req.Type,
 		"phone":  req.Phone,
 		"name":   req.Name,
+		"email":  req.Email,
 		"status": req.Status,
+		"phone_display": sql.NullString{
+			String: req.PhoneDisplay,
+			Valid:  req.PhoneDisplay != "",
+		},
 		"scheduled_date": sql.NullTime{
 			Time:  req.ScheduledDate,
 			Valid: !req.ScheduledDate.IsZero(),
@@ -104,246 +220,292 @@ func CreateRequest(ctx context.Context, req *Request) error {
 			String: req.Property,
 			Valid:  req.Property != "",
 		},
+		"assigned_at": sql.NullTime{
+			Time:  req.AssignedAt,
+			Valid: !req.AssignedAt.IsZero(),
+		},
+		"tried_agents": req.TriedAgents,
+		"spam_reason": sql.NullString{
+			String: req.SpamReason,
+			Valid:  req.SpamReason != "",
+		},
+		"utm_source":   req.Attribution.Source,
+		"utm_medium":   req.Attribution.Medium,
+		"utm_campaign": req.Attribution.Campaign,
+		"utm_term":     req.Attribution.Term,
+		"utm_content":  req.Attribution.Content,
+		"referrer":     req.Attribution.Referrer,
+		"landing_page": req.Attribution.LandingPage,
+		"first_touch_at": sql.NullTime{
+			Time:  req.Attribution.FirstTouchAt,
+			Valid: !req.Attribution.FirstTouchAt.IsZero(),
+		},
+		"contact_id":  uuid.Must(uuid.NewV7()).String(),
+		"spam_status": RequestStatusSpam,
 	}
-	_, err = conn.Exec(
-		ctx,
-		`INSERT INTO
-            requests (id, type, phone, name, status, agent, scheduled_date, property)
-        VALUES (@id, @type, @phone, @name, @status, @agent, @scheduled_date, @property)`,
-		args,
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
-func GetRequestsPagination(filter *RequestFilter, limit, page int) (paginationData *Pagination, err error) {
-	conn, err := GetPool()
-	if err != nil {
-		return
-	}
-	defer conn.Release()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	var queryParams []any
+func buildRequestFilterConditions(filter *RequestFilter) ([]string, []any, int) {
 	var queryConditions []string
+	var queryParams []any
+	nextParamIdx := 1
 
 	if filter.Type != nil {
-		queryConditions = append(queryConditions, `r.type = $1`)
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.type = $%d`, nextParamIdx))
 		queryParams = append(queryParams, *filter.Type)
+		nextParamIdx++
 	}
 
+	// The spam is only listed when it is asked for
 	if filter.Status != nil {
-		queryConditions = append(queryConditions, `r.status = $2`)
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.status = $%d`, nextParamIdx))
 		queryParams = append(queryParams, *filter.Status)
+		nextParamIdx++
+	} else {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.status <> $%d`, nextParamIdx))
+		queryParams = append(queryParams, RequestStatusSpam)
+		nextParamIdx++
 	}
 
 	if filter.Property != nil {
-		queryConditions = append(queryConditions, `r.property = $3`)
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.property = $%d`, nextParamIdx))
 		queryParams = append(queryParams, *filter.Property)
-	}
-
-	if filter.CreatedAt != nil {
-		queryConditions = append(queryConditions, `r.date = $4`)
-		queryParams = append(queryParams, *filter.CreatedAt)
+		nextParamIdx++
 	}
 
 	if filter.Agent != nil {
-		queryConditions = append(queryConditions, `r.agent = $5`)
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.agent = $%d`, nextParamIdx))
 		queryParams = append(queryParams, *filter.Agent)
+		nextParamIdx++
 	}
 
-	if filter.ScheduledDate != nil {
-		queryConditions = append(queryConditions, `r.scheduled_date = $6`)
-		queryParams = append(queryParams, *filter.ScheduledDate)
+	if filter.Contact != nil {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.contact = $%d`, nextParamIdx))
+		queryParams = append(queryParams, *filter.Contact)
+		nextParamIdx++
 	}
 
-	baseQuery := `
-		SELECT r.id, r.type, r.phone, r.name, r.date, r.status, r.scheduled_date
-			u.name || ' ' || u.lastname AS agent,
-			p.address || ', ' || p.nb_hood || ' ' || p.zip_code AS property
-		FROM requests r
-		LEFT JOIN users u ON r.agent = u.id
-		LEFT JOIN properties p ON r.property = p.id
-		WHERE 1 = 1
-	`
-
-	if len(queryParams) > 0 {
-		baseQuery = baseQuery + " AND " + strings.Join(queryConditions, " AND ")
+	if filter.CreatedAt != nil {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.date::date = $%d::date`, nextParamIdx))
+		queryParams = append(queryParams, *filter.CreatedAt)
+		nextParamIdx++
 	}
 
-	rows, err := conn.Query(
-		ctx,
-		baseQuery,
-		queryParams...,
-	)
-	if err != nil {
-		return
+	if filter.CreatedFrom != nil {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.date >= $%d`, nextParamIdx))
+		queryParams = append(queryParams, *filter.CreatedFrom)
+		nextParamIdx++
 	}
-	defer rows.Close()
 
-	var reqCount int
-	for rows.Next() {
-		err = rows.Scan(&reqCount)
-
-		if err != nil {
-			return
-		}
+	if filter.CreatedTo != nil {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.date < $%d::timestamptz + interval '1 day'`, nextParamIdx))
+		queryParams = append(queryParams, *filter.CreatedTo)
+		nextParamIdx++
 	}
 
-	paginationData = NewPagination(reqCount, limit, page)
+	if filter.ScheduledDate != nil {
+		queryConditions = append(queryConditions, fmt.Sprintf(`r.scheduled_date::date = $%d::date`, nextParamIdx))
+		queryParams = append(queryParams, *filter.ScheduledDate)
+		nextParamIdx++
+	}
 
-	return
+	return queryConditions, queryParams, nextParamIdx
 }
 
-func FindRequests(filter *RequestFilter, limit, page int) (requests []*Request, err error) {
-	conn, err := GetPool()
+func GetRequestsPagination(ctx context.Context, filter *RequestFilter, limit, page int) (paginationData *Pagination, err error) {
+	conn, err := GetPoolWithCtx(ctx)
 	if err != nil {
-		return nil, err
+		return
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	requests = []*Request{}
-	var paginateOpts string
-
-	if limit > 0 {
-		paginateOpts = fmt.Sprintf(" LIMIT %v", limit)
+	query := "SELECT count(*) FROM requests r WHERE 1=1"
+	queryConditions, queryParams, _ := buildRequestFilterConditions(filter)
+	if len(queryConditions) > 0 {
+		query = query + " AND " + strings.Join(queryConditions, " AND ")
 	}
-	if page > 0 {
-		paginateOpts = fmt.Sprintf(" OFFSET %v", limit*(page-1))
+
+	var reqCount int
+	err = conn.QueryRow(ctx, query, queryParams...).Scan(&reqCount)
+	if err != nil {
+		return
 	}
 
-	var queryParams []any
-	var queryConditions []string
+	paginationData = NewPagination(reqCount, limit, page)
 
-	if filter.Type != nil {
-		queryConditions = append(queryConditions, `r.type = $1`)
-		queryParams = append(queryParams, *filter.Type)
-	}
+	return
+}
 
-	if filter.Status != nil {
-		queryConditions = append(queryConditions, `r.status = $2`)
-		queryParams = append(queryParams, *filter.Status)
+const requestColumns = `
+	r.id, r.type, r.phone, COALESCE(r.phone_display, r.phone) AS phone_display,
+	r.name, COALESCE(r.email, '') AS email, r.date, r.updated_at, r.status, r.scheduled_date, r.wsp_sent,
+	r.assigned_at, r.tried_agents::text[] AS tried_agents,
+	r.first_response_at, r.sla_reminded_at, r.sla_escalated_at,
+	COALESCE(r.agent::text, '') AS agent,
+	COALESCE(r.property::text, '') AS property,
+	COALESCE(r.contact::text, '') AS contact,
+	COALESCE(r.spam_reason, '') AS spam_reason,
+	COALESCE(r.utm_source, '') AS utm_source, COALESCE(r.utm_medium, '') AS utm_medium,
+	COALESCE(r.utm_campaign, '') AS utm_campaign, COALESCE(r.utm_term, '') AS utm_term,
+	COALESCE(r.utm_content, '') AS utm_content, COALESCE(r.referrer, '') AS referrer,
+	COALESCE(r.landing_page, '') AS landing_page, r.first_touch_at,
+	COALESCE(u.name || ' ' || u.lastname, '') AS agent_name,
+	COALESCE(p.address || ', ' || p.nb_hood || ' ' || p.zip, '') AS property_address,
+	COALESCE(p.contract, '') AS property_contract,
+	COALESCE(p.lat, 0) AS property_lat,
+	COALESCE(p.lon, 0) AS property_lon
+`
+
+const requestJoins = `
+	FROM requests r
+	LEFT JOIN users u ON r.agent = u.id
+	LEFT JOIN properties p ON r.property = p.id
+`
+
+func scanRequest(row pgx.Row) (*Request, error) {
+	var req Request
+	var scheduledDate, assignedAt, updatedAt sql.NullTime
+	var firstResponseAt, slaRemindedAt, slaEscalatedAt, firstTouchAt sql.NullTime
+	err := row.Scan(
+		&req.Id,
+		&req.Type,
+		&req.Phone,
+		&req.PhoneDisplay,
+		&req.Name,
+		&req.Email,
+		&req.CreatedAt,
+		&updatedAt,
+		&req.Status,
+		&scheduledDate,
+		&req.WspSent,
+		&assignedAt,
+		&req.TriedAgents,
+		&firstResponseAt,
+		&slaRemindedAt,
+		&slaEscalatedAt,
+		&req.Agent,
+		&req.Property,
+		&req.Contact,
+		&req.SpamReason,
+		&req.Attribution.Source,
+		&req.Attribution.Medium,
+		&req.Attribution.Campaign,
+		&req.Attribution.Term,
+		&req.Attribution.Content,
+		&req.Attribution.Referrer,
+		&req.Attribution.LandingPage,
+		&firstTouchAt,
+		&req.AgentName,
+		&req.PropertyAddress,
+		&req.PropertyContract,
+		&req.PropertyLat,
+		&req.PropertyLon,
+	)
+	if err != nil {
+		return nil, err
 	}
+	req.UpdatedAt = updatedAt.Time
+	req.ScheduledDate = scheduledDate.Time
+	req.AssignedAt = assignedAt.Time
+	req.FirstResponseAt = firstResponseAt.Time
+	req.SLARemindedAt = slaRemindedAt.Time
+	req.SLAEscalatedAt = slaEscalatedAt.Time
+	req.Attribution.FirstTouchAt = firstTouchAt.Time
 
-	if filter.Property != nil {
-		queryConditions = append(queryConditions, `r.property = $3`)
-		queryParams = append(queryParams, *filter.Property)
-	}
+	return &req, nil
+}
 
-	if filter.CreatedAt != nil {
-		queryConditions = append(queryConditions, `r.date = $4`)
-		queryParams = append(queryParams, *filter.CreatedAt)
+// FindRequests returns the requests matching filter, newest first
+func FindRequests(ctx context.Context, filter *RequestFilter, limit, page int) (requests []*Request, err error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer conn.Release()
 
-	if filter.Agent != nil {
-		queryConditions = append(queryConditions, `r.agent = $5`)
-		queryParams = append(queryParams, *filter.Agent)
-	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-	if filter.ScheduledDate != nil {
-		queryConditions = append(queryConditions, `r.scheduled_date = $6`)
-		queryParams = append(queryParams, *filter.ScheduledDate)
+	queryConditions, queryParams, _ := buildRequestFilterConditions(filter)
+	query := "SELECT " + requestColumns + requestJoins + " WHERE 1=1"
+	if len(queryConditions) > 0 {
+		query = query + " AND " + strings.Join(queryConditions, " AND ")
 	}
+	query += " ORDER BY r.date DESC"
 
-	baseQuery := `
-		SELECT r.id, r.type, r.phone, r.name, r.date, r.status, r.scheduled_date
-			u.name || ' ' || u.lastname AS agent,
-			p.address || ', ' || p.nb_hood || ' ' || p.zip_code AS property
-		FROM requests r
-		LEFT JOIN users u ON r.agent = u.id
-		LEFT JOIN properties p ON r.property = p.id
-		WHERE 1 = 1
-	`
-	var query string
-	if len(queryParams) > 0 {
-		query = baseQuery + " AND " + strings.Join(queryConditions, " AND ") + paginateOpts
-	} else {
-		query = baseQuery + paginateOpts
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+		if page > 1 {
+			query += fmt.Sprintf(" OFFSET %d", limit*(page-1))
+		}
 	}
 
-	rows, err := conn.Query(
-		ctx,
-		query,
-		queryParams...,
-	)
+	rows, err := conn.Query(ctx, query, queryParams...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
+	requests = []*Request{}
 	for rows.Next() {
-		var r Request
-		err = rows.Scan(
-			r.Id,
-			r.Type,
-			r.Phone,
-			r.Name,
-			r.CreatedAt,
-			r.Status,
-			r.ScheduledDate,
-			r.Agent,
-			r.Property,
-		)
-
+		var req *Request
+		req, err = scanRequest(rows)
 		if err != nil {
 			return
 		}
 
-		requests = append(requests, &r)
+		requests = append(requests, req)
 	}
 
-	return
+	return requests, rows.Err()
 }
 
-func FindRequestById(id string) (*Request, error) {
-	conn, err := GetPool()
+func FindRequestById(ctx context.Context, id string) (*Request, error) {
+	conn, err := GetPoolWithCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	var req Request
-	row := conn.QueryRow(
-		ctx,
-		`SELECT r.id, r.phone, r.name, r.date, r.scheduled_date, r.status, r.type,
-			u.name || ' ' || u.lastname AS agent,
-			p.address || ', ' || p.nb_hood || ' ' || p.zip_code AS property
-		FROM requests r
-		LEFT JOIN users u ON r.agent = u.id
-		LEFT JOIN properties p ON r.property = p.id
-		WHERE r.id = $1`,
-		id,
-	)
+	row := conn.QueryRow(ctx, "SELECT "+requestColumns+requestJoins+" WHERE r.id = $1", id)
 
-	err = row.Scan(
-		&req.Id,
-		&req.Phone,
-		&req.Name,
-		&req.CreatedAt,
-		&req.ScheduledDate,
-		&req.Status,
-		&req.Type,
-		&req.Agent,
-		&req.Property,
-	)
+	return scanRequest(row)
+}
+
+// RequestProperty is a property that has received requests, used to
+// filter the inbox
+type RequestProperty struct {
+	Id      string `db:"id"`
+	Address string `db:"address"`
+}
 
+func FindRequestProperties(ctx context.Context) ([]*RequestProperty, error) {
+	conn, err := GetPoolWithCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
 
-	return &req, nil
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT p.id::text AS id, p.address
+		FROM properties p
+		WHERE EXISTS (SELECT 1 FROM requests r WHERE r.property = p.id)
+		ORDER BY p.address
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RequestProperty])
 }
 
 func UpdateRequest(ctx context.Context, req *Request) error {
@@ -354,18 +516,29 @@ func UpdateRequest(ctx context.Context, req *Request) error {
 	defer conn.Release()
 
 	args := pgx.NamedArgs{
-		"id":             req.Id,
-		"type":           req.Type,
-		"phone":          req.Phone,
-		"name":           req.Name,
-		"scheduled_date": req.ScheduledDate,
-		"status":         req.Status,
-		"agent":          req.Agent,
-		"property":       req.Property,
-		"wsp_sent":       req.WspSent,
-	}
-
-	_, err = conn.Exec(
+		"id":    req.Id,
+		"type":  req.Type,
+		"phone": req.Phone,
+		"name":  req.Name,
+		"scheduled_date": sql.NullTime{
+			Time:  req.ScheduledDate,
+			Valid: !req.ScheduledDate.IsZero(),
+		},
+		"status": req.Status,
+		"agent": sql.NullString{
+			String: req.Agent,
+			Valid:  req.Agent != "",
+		},
+		"property": sql.NullString{
+			String: req.Property,
+			Valid:  req.Property != "",
+		},
+		"wsp_sent": req.WspSent,
+		"pending":  RequestStatusPending,
+	}
+
+	var firstResponseAt sql.NullTime
+	err = conn.QueryRow(
 		ctx,
 		`UPDATE requests SET
 			type = @type,
@@ -373,14 +546,76 @@ func UpdateRequest(ctx context.Context, req *Request) error {
 			status = @status,
 			agent = @agent,
 			property = @property,
-			wsp_sent = @wsp_sent
-        WHERE id = @id`,
+			wsp_sent = @wsp_sent,
+			first_response_at = CASE
+				WHEN @status <> @pending THEN COALESCE(first_response_at, NOW())
+				ELSE first_response_at
+			END,
+			updated_at = NOW()
+        WHERE id = @id
+		RETURNING updated_at, first_response_at`,
 		args,
-	)
+	).Scan(&req.UpdatedAt, &firstResponseAt)
 
 	if err != nil {
 		return err
 	}
+	req.FirstResponseAt = firstResponseAt.Time
 
 	return nil
 }
+
+// UpdateRequestStatus moves the request to status when it still has the
+// status it was read with, so it doesn't overwrite the changes made since
+// then. It fails with ErrRequestChanged when the status is not the same.
+func UpdateRequestStatus(ctx context.Context, req *Request, status RequestStatus) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var firstResponseAt sql.NullTime
+	err = conn.QueryRow(
+		ctx,
+		`UPDATE requests SET
+			status = @status,
+			first_response_at = CASE
+				WHEN @status <> @pending THEN COALESCE(first_response_at, NOW())
+				ELSE first_response_at
+			END,
+			updated_at = NOW()
+		WHERE id = @id AND status = @current
+		RETURNING updated_at, first_response_at`,
+		pgx.NamedArgs{
+			"id":      req.Id,
+			"status":  status,
+			"current": req.Status,
+			"pending": RequestStatusPending,
+		},
+	).Scan(&req.UpdatedAt, &firstResponseAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRequestChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	req.Status = status
+	req.FirstResponseAt = firstResponseAt.Time
+
+	return nil
+}
+
+// MarkRequestWspSent records that the agent got the WhatsApp message of
+// the request
+func MarkRequestWspSent(ctx context.Context, id string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `UPDATE requests SET wsp_sent = true WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FindSLAPending returns the pending requests that still miss the SLA
+// reminder or escalation, oldest first
+func FindSLAPending(ctx context.Context) ([]*Request, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+requestColumns+requestJoins+`
+		WHERE r.status = $1 AND (r.sla_reminded_at IS NULL OR r.sla_escalated_at IS NULL)
+		ORDER BY r.date
+	`, RequestStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*Request{}
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// ClaimSLAReminder marks the reminder of the request as sent while it is
+// still pending with the same agent and nobody reminded it yet. It reports
+// whether this call got it, the check runs on every instance and only the
+// one that claims the reminder sends it.
+func ClaimSLAReminder(ctx context.Context, req *Request) (bool, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = conn.QueryRow(ctx, `
+		UPDATE requests SET sla_reminded_at = NOW()
+		WHERE id = $1 AND sla_reminded_at IS NULL AND status = $2 AND agent = $3
+		RETURNING sla_reminded_at
+	`, req.Id, RequestStatusPending, req.Agent).Scan(&req.SLARemindedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ClaimSLAEscalation marks the escalation of the request as sent while it
+// is still pending and nobody escalated it yet, like ClaimSLAReminder
+func ClaimSLAEscalation(ctx context.Context, req *Request) (bool, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = conn.QueryRow(ctx, `
+		UPDATE requests SET sla_escalated_at = NOW()
+		WHERE id = $1 AND sla_escalated_at IS NULL AND status = $2
+		RETURNING sla_escalated_at
+	`, req.Id, RequestStatusPending).Scan(&req.SLAEscalatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
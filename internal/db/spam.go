@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ReleaseSpamRequest moves a quarantined request to pending, links it to
+// its contact and assigns it to the agent set on req, if any. Visits are
+// released like they are created, with the agent row locked, and fail
+// with ErrSlotTaken when the agent got another visit less than duration
+// apart while the request was quarantined.
+func ReleaseSpamRequest(ctx context.Context, req *Request, duration time.Duration) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if req.Type == RequestTypeQuote && req.Agent != "" {
+		_, err = tx.Exec(ctx, "SELECT 1 FROM users WHERE id = $1 FOR UPDATE", req.Agent)
+		if err != nil {
+			return err
+		}
+
+		var taken bool
+		err = tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM requests
+				WHERE agent = $1 AND type = $2 AND id <> $3 AND status <> $4
+					AND scheduled_date > $5 AND scheduled_date < $6
+			)
+		`, req.Agent, RequestTypeQuote, req.Id, RequestStatusSpam,
+			req.ScheduledDate.Add(-duration), req.ScheduledDate.Add(duration)).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrSlotTaken
+		}
+	}
+
+	var assignedAt pgtype.Timestamptz
+	err = tx.QueryRow(ctx, `
+		UPDATE requests SET
+			status = @pending,
+			spam_reason = NULL,
+			agent = NULLIF(@agent, '')::uuid,
+			assigned_at = CASE WHEN @agent = '' THEN NULL ELSE NOW() END,
+			tried_agents = CASE WHEN @agent = '' THEN tried_agents
+				ELSE array_append(array_remove(tried_agents, NULLIF(@agent, '')::uuid), NULLIF(@agent, '')::uuid) END,
+			contact = COALESCE(contact, request_contact(@contact_id, phone, email, name)),
+			updated_at = NOW()
+		WHERE id = @id AND status = @spam
+		RETURNING updated_at, assigned_at, COALESCE(contact::text, '')
+	`, pgx.NamedArgs{
+		"id":         req.Id,
+		"agent":      req.Agent,
+		"pending":    RequestStatusPending,
+		"spam":       RequestStatusSpam,
+		"contact_id": uuid.Must(uuid.NewV7()).String(),
+	}).Scan(&req.UpdatedAt, &assignedAt, &req.Contact)
+	if err != nil {
+		return err
+	}
+
+	req.Status = RequestStatusPending
+	req.SpamReason = ""
+	req.AssignedAt = assignedAt.Time
+
+	return tx.Commit(ctx)
+}
+
+// DeleteSpamRequest deletes the request only if it is quarantined
+func DeleteSpamRequest(ctx context.Context, id string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM requests WHERE id = $1 AND status = $2", id, RequestStatusSpam)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
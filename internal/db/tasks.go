@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Task is a follow-up of a request that an agent must do by DueDate
+type Task struct {
+	Id        string       `json:"id" db:"id"`
+	Request   string       `json:"request" db:"request"`
+	Agent     string       `json:"agent" db:"agent"`
+	Title     string       `json:"title" db:"title"`
+	DueDate   time.Time    `json:"dueDate" db:"due_date"`
+	DoneAt    sql.NullTime `json:"doneAt" db:"done_at"`
+	CreatedAt time.Time    `json:"createdAt" db:"created_at"`
+
+	// Only set when the task is read, from the request and agent rows
+	RequestName  string `json:"requestName" db:"request_name"`
+	RequestPhone string `json:"requestPhone" db:"request_phone"`
+	AgentName    string `json:"agentName" db:"agent_name"`
+}
+
+func (t *Task) Done() bool {
+	return t.DoneAt.Valid
+}
+
+// Overdue tells if the task is not done and its due date has passed
+func (t *Task) Overdue(now time.Time) bool {
+	return !t.Done() && t.DueDate.Before(now)
+}
+
+const taskColumns = `
+	t.id, t.request, t.agent, t.title, t.due_date,
+	t.done_at, t.created_at,
+	r.name AS request_name, COALESCE(r.phone_display, r.phone) AS request_phone,
+	u.name || ' ' || u.lastname AS agent_name
+`
+
+const taskJoins = `
+	FROM tasks t
+	JOIN requests r ON t.request = r.id
+	JOIN users u ON t.agent = u.id
+`
+
+func CreateTask(ctx context.Context, task *Task) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	task.Id = uuid.Must(uuid.NewV7()).String()
+	err = conn.QueryRow(ctx, `
+		INSERT INTO tasks (id, request, agent, title, due_date)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, task.Id, task.Request, task.Agent, task.Title, task.DueDate).Scan(&task.CreatedAt)
+
+	return err
+}
+
+func FindTaskById(ctx context.Context, id string) (*Task, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+taskColumns+taskJoins+" WHERE t.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Task])
+}
+
+// FindRequestTasks returns the tasks of the request, the pending ones
+// first by due date
+func FindRequestTasks(ctx context.Context, requestId string) ([]*Task, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+taskColumns+taskJoins+`
+		WHERE t.request = $1
+		ORDER BY t.done_at IS NOT NULL, t.due_date
+	`, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Task])
+}
+
+// FindAgentTasks returns the pending tasks of the agent by due date, so
+// the overdue ones come first. The tasks done since doneSince follow them.
+func FindAgentTasks(ctx context.Context, agentId string, doneSince time.Time) ([]*Task, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT "+taskColumns+taskJoins+`
+		WHERE t.agent = $1 AND (t.done_at IS NULL OR t.done_at >= $2)
+		ORDER BY t.done_at IS NOT NULL, t.due_date, t.done_at DESC
+	`, agentId, doneSince)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Task])
+}
+
+// SetTaskDone marks the task as done now, or as pending when done is
+// false
+func SetTaskDone(ctx context.Context, task *Task, done bool) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return conn.QueryRow(ctx, `
+		UPDATE tasks SET done_at = CASE WHEN $2::bool THEN NOW() END
+		WHERE id = $1
+		RETURNING done_at
+	`, task.Id, done).Scan(&task.DoneAt)
+}
+
+func DeleteTask(ctx context.Context, id string) error {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM tasks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// tileExtent is the size of the tile grid, the default used by ST_AsMVT
+const tileExtent = 4096
+
+// FindPropertiesTile builds a Mapbox Vector Tile with a point for each
+// published property inside the tile z/x/y
+func FindPropertiesTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var tile []byte
+	err = conn.QueryRow(
+		ctx,
+		`WITH bounds AS (
+			SELECT ST_TileEnvelope($1, $2, $3) AS geom
+		), props AS (
+			SELECT
+				p.id::text AS id, p.slug, p.price::float8 AS price, p.property_type, p.contract,
+				p.beds, p.baths, p.square_mt, p.nb_hood, p.main_img,
+				ST_Transform(ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326), 3857) AS geom
+			FROM properties p
+			CROSS JOIN bounds
+			WHERE p.status = $4
+				AND NOT (p.lat = 0 AND p.lon = 0)
+				AND ST_SetSRID(ST_MakePoint(p.lon, p.lat), 4326) && ST_Transform(bounds.geom, 4326)
+		)
+		SELECT ST_AsMVT(mvt, 'properties', $5, 'geom')
+		FROM (
+			SELECT
+				props.id, props.slug, props.price, props.property_type, props.contract,
+				props.beds, props.baths, props.square_mt, props.nb_hood, props.main_img,
+				ST_AsMVTGeom(props.geom, bounds.geom, $5, 64, true) AS geom
+			FROM props, bounds
+		) mvt`,
+		z,
+		x,
+		y,
+		PropertyStatusPublished,
+		tileExtent,
+	).Scan(&tile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tile, nil
+}
+
+// FindHoodsTile builds a Mapbox Vector Tile with the boundaries of the
+// hoods inside the tile z/x/y and the price in effect of each hood, the
+// same one the hood pages show
+func FindHoodsTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var tile []byte
+	err = conn.QueryRow(
+		ctx,
+		`WITH bounds AS (
+			SELECT ST_TileEnvelope($1, $2, $3) AS geom
+		), hood_geoms AS (
+			SELECT
+				h.id::text AS id, h.name,
+				COALESCE(hp.sq_meter_price, h.sq_meter_price, 0)::float8 AS sq_meter_price,
+				ST_Transform(COALESCE(h.display_boundaries, h.boundaries), 3857) AS geom
+			FROM hoods h
+			CROSS JOIN bounds
+			LEFT JOIN LATERAL (
+				SELECT sq_meter_price
+				FROM hood_prices
+				WHERE hood = h.id AND effective_date <= CURRENT_DATE
+				ORDER BY effective_date DESC
+				LIMIT 1
+			) hp ON true
+			WHERE h.boundaries && ST_Transform(bounds.geom, 4326)
+		)
+		SELECT ST_AsMVT(mvt, 'hoods', $4, 'geom')
+		FROM (
+			SELECT
+				hg.id, hg.name, hg.sq_meter_price,
+				ST_AsMVTGeom(hg.geom, bounds.geom, $4, 64, true) AS geom
+			FROM hood_geoms hg, bounds
+		) mvt`,
+		z,
+		x,
+		y,
+		tileExtent,
+	).Scan(&tile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tile, nil
+}
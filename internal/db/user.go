@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,11 +20,19 @@ type User struct {
 	Username      string         `db:"username" json:"username"`
 	Role          string         `db:"role" json:"role"`
 	Email         string         `db:"email" json:"email"`
-	Phone         sql.NullString `json:"phone" db:"phone"`
+	Phone         sql.NullString `json:"phone" db:"phone"` // E.164
+	PhoneDisplay  string         `json:"phoneDisplay" db:"phone_display"`
 	EmailVerified bool           `json:"emailVerified" db:"email_verified"`
 	PhoneVerified bool           `json:"phoneVerified" db:"phone_verified"`
 	GoldenBoy     bool           `db:"golden_boy"`
 	Img           string         `json:"img" db:"img"`
+	// Inactive users are not assigned new leads
+	Active bool `json:"active" db:"active"`
+}
+
+// ImgURL returns the URL of the profile picture
+func (u *User) ImgURL() string {
+	return storage.URL(storage.Key("users", u.Img))
 }
 
 func (u *User) ValidatePass(pw string) error {
@@ -83,7 +92,7 @@ func CreateUser(user *User) (string, error) {
 
 	tag, err := conn.Exec(
 		ctx,
-		"INSERT INTO users (id, name, lastname, password, username, role, email, phone, img) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		"INSERT INTO users (id, name, lastname, password, username, role, email, phone, phone_display, img) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
 		user.Id,
 		user.Name,
 		user.Lastname,
@@ -92,6 +101,7 @@ func CreateUser(user *User) (string, error) {
 		user.Role,
 		user.Email,
 		user.Phone,
+		user.PhoneDisplay,
 		user.Img,
 	)
 
@@ -129,10 +139,12 @@ func GetUserById(id string) (*User, error) {
 			role,
 			email,
 			phone,
+			COALESCE(NULLIF(phone_display, ''), phone, ''),
 			email_verified,
 			phone_verified,
 			golden_boy,
-			img
+			img,
+			active
 		FROM users WHERE id = $1`,
 		id,
 	).Scan(
@@ -144,10 +156,12 @@ func GetUserById(id string) (*User, error) {
 		&user.Role,
 		&user.Email,
 		&user.Phone,
+		&user.PhoneDisplay,
 		&user.EmailVerified,
 		&user.PhoneVerified,
 		&user.GoldenBoy,
 		&user.Img,
+		&user.Active,
 	)
 
 	if err != nil {
@@ -204,6 +218,55 @@ func GetUserByUsername(username string) (*User, error) {
 	return &user, nil
 }
 
+// FindAgents returns every user that can be assigned requests and
+// properties, without their passwords
+func FindAgents(ctx context.Context) ([]*User, error) {
+	conn, err := GetPoolWithCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, name, lastname, username, role, email, phone, COALESCE(NULLIF(phone_display, ''), phone, ''),
+			golden_boy, img, active
+		FROM users
+		ORDER BY name, lastname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		err = rows.Scan(
+			&user.Id,
+			&user.Name,
+			&user.Lastname,
+			&user.Username,
+			&user.Role,
+			&user.Email,
+			&user.Phone,
+			&user.PhoneDisplay,
+			&user.GoldenBoy,
+			&user.Img,
+			&user.Active,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
 func UpdateUser(user *User) error {
 	conn, err := GetPool()
 	if err != nil {
@@ -216,7 +279,7 @@ func UpdateUser(user *User) error {
 
 	_, err = conn.Exec(
 		ctx,
-		"UPDATE users SET name = $1, lastname = $2, password = $3, username = $4, role = $5, email = $6, phone = $7, img = $8 WHERE id = $9",
+		"UPDATE users SET name = $1, lastname = $2, password = $3, username = $4, role = $5, email = $6, phone = $7, phone_display = $8, img = $9 WHERE id = $10",
 		user.Name,
 		user.Lastname,
 		user.Password,
@@ -224,6 +287,7 @@ func UpdateUser(user *User) error {
 		user.Role,
 		user.Email,
 		user.Phone,
+		user.PhoneDisplay,
 		user.Img,
 		user.Id,
 	)
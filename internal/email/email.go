@@ -0,0 +1,191 @@
+// Package email sends the transactional emails of the site over SMTP, with
+// an HTML and a text body rendered from templ components and attachments
+// such as the invites to the visits.
+//
+// Any SMTP server works, a local sink (e.g. Mailpit on localhost:1025 with
+// SMTP_TLS=none) catches the emails while developing.
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+const (
+	EnvVarHost     = "SMTP_HOST"
+	EnvVarPort     = "SMTP_PORT"
+	EnvVarUsername = "SMTP_USERNAME"
+	EnvVarPassword = "SMTP_PASSWORD"
+	// How the connection is encrypted: starttls (default), tls for the
+	// servers that only take TLS connections (port 465) or none for the
+	// local sinks
+	EnvVarTLS = "SMTP_TLS"
+	// Sender of the emails, e.g. Sibra Durango <avisos@sibradurango.com>
+	EnvVarFrom = "EMAIL_FROM"
+	// Address the leads without an agent are sent to, like
+	// WSP_NOTIFICATION_PHONE
+	EnvVarNotificationAddress = "EMAIL_NOTIFICATION_ADDRESS"
+
+	DefaultPort = "587"
+
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+
+	// sendTimeout is how long a message can take when the context has no
+	// deadline
+	sendTimeout = 30 * time.Second
+)
+
+var (
+	ErrNotConfigured    = errors.New("the smtp server is not set in environment variables")
+	ErrInvalidFrom      = errors.New("the sender of the emails is invalid")
+	ErrInvalidAddress   = errors.New("email address is invalid")
+	ErrNoRecipients     = errors.New("the message has no recipients")
+	ErrStartTLSRequired = errors.New("the smtp server does not support STARTTLS")
+)
+
+type config struct {
+	host     string
+	port     string
+	username string
+	password string
+	tls      string
+	from     *mail.Address
+}
+
+func loadConfig() (*config, error) {
+	cfg := &config{
+		host:     os.Getenv(EnvVarHost),
+		port:     os.Getenv(EnvVarPort),
+		username: os.Getenv(EnvVarUsername),
+		password: os.Getenv(EnvVarPassword),
+		tls:      os.Getenv(EnvVarTLS),
+	}
+	if cfg.host == "" || os.Getenv(EnvVarFrom) == "" {
+		return nil, ErrNotConfigured
+	}
+	if cfg.port == "" {
+		cfg.port = DefaultPort
+	}
+	switch cfg.tls {
+	case "":
+		cfg.tls = TLSStartTLS
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("invalid %s %q", EnvVarTLS, cfg.tls)
+	}
+
+	from, err := mail.ParseAddress(os.Getenv(EnvVarFrom))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFrom, err)
+	}
+	cfg.from = from
+
+	return cfg, nil
+}
+
+// Enabled tells if the SMTP server is set, the emails are skipped when it
+// is not
+func Enabled() bool {
+	return os.Getenv(EnvVarHost) != "" && os.Getenv(EnvVarFrom) != ""
+}
+
+// ValidAddress tells if s is a single email address, without a name
+func ValidAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// Send delivers the message through the SMTP server of the environment
+func Send(ctx context.Context, msg *Message) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	recipients, err := msg.recipients()
+	if err != nil {
+		return err
+	}
+
+	data, err := msg.Bytes(cfg.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+
+	conn, err := dial(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", cfg.host, err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, cfg.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if cfg.tls == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return ErrStartTLSRequired
+		}
+		if err = client.StartTLS(&tls.Config{ServerName: cfg.host}); err != nil {
+			return err
+		}
+	}
+
+	if cfg.username != "" {
+		if err = client.Auth(smtp.PlainAuth("", cfg.username, cfg.password, cfg.host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(cfg.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err = client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func dial(ctx context.Context, cfg *config) (net.Conn, error) {
+	addr := net.JoinHostPort(cfg.host, cfg.port)
+	if cfg.tls == TLSImplicit {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: cfg.host}}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,268 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// received is what the fake SMTP server got in a session
+type received struct {
+	from       string
+	recipients []string
+	// data has the lines ending in \n, as the dot reader leaves them
+	data []byte
+}
+
+// serveSMTP accepts a single SMTP session on a local port, enough of the
+// protocol for net/smtp, and sets the env to send to it. The session is
+// sent on the channel when the client quits.
+func serveSMTP(t *testing.T) <-chan *received {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	t.Setenv(EnvVarHost, host)
+	t.Setenv(EnvVarPort, port)
+	t.Setenv(EnvVarTLS, TLSNone)
+	t.Setenv(EnvVarUsername, "")
+	t.Setenv(EnvVarFrom, "Sibra Durango <avisos@sibra.test>")
+
+	sessions := make(chan *received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		r := &received{}
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 8BITMIME")
+			case "MAIL":
+				r.from = arg
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				r.recipients = append(r.recipients, arg)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				r.data, err = tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				sessions <- r
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return sessions
+}
+
+// readParts returns the parts of the multipart body, their transfer
+// encoding is left as it was sent
+func readParts(t *testing.T, contentType string, body io.Reader) []*multipart.Part {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("Content-Type = %q, want a multipart", contentType)
+	}
+
+	parts := []*multipart.Part{}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart err: %v", err)
+		}
+
+		// The part is read before the next one is opened
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Header.Set("X-Test-Content", string(content))
+		parts = append(parts, part)
+	}
+
+	return parts
+}
+
+func partContent(p *multipart.Part) string {
+	return p.Header.Get("X-Test-Content")
+}
+
+func TestSendAlternative(t *testing.T) {
+	sessions := serveSMTP(t)
+
+	subject := "Tu cita en Sibra: visita confirmada para el miércoles, señor Núñez"
+	err := Send(context.Background(), &Message{
+		To:      []string{"Cliente <cliente@ejemplo.test>"},
+		Subject: subject,
+		Text:    "Hola Núñez,\nTu visita está confirmada.",
+		HTML:    "<p>Hola Núñez,</p>\n<p>Tu visita está confirmada.</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send err: %v", err)
+	}
+
+	r := <-sessions
+	if !strings.Contains(r.from, "<avisos@sibra.test>") {
+		t.Errorf("MAIL = %q, want the address of %s", r.from, EnvVarFrom)
+	}
+	if len(r.recipients) != 1 || !strings.Contains(r.recipients[0], "<cliente@ejemplo.test>") {
+		t.Errorf("RCPT = %v, want the address of the recipient only", r.recipients)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(r.data))
+	if err != nil {
+		t.Fatalf("ReadMessage err: %v", err)
+	}
+
+	// The subject is Q-encoded, in encoded words of at most 75 octets
+	raw := msg.Header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?utf-8?q?") {
+		t.Errorf("Subject = %q, want it Q-encoded", raw)
+	}
+	for _, word := range strings.Fields(raw) {
+		if len(word) > 75 {
+			t.Errorf("encoded word %q is longer than 75 octets", word)
+		}
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil || decoded != subject {
+		t.Errorf("decoded Subject = %q (err %v), want %q", decoded, err, subject)
+	}
+
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") || len(parts) != 2 {
+		t.Fatalf("got %d parts of %q, want the text and HTML alternatives", len(parts), msg.Header.Get("Content-Type"))
+	}
+
+	want := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", "Hola Núñez,\nTu visita está confirmada."},
+		{"text/html; charset=utf-8", "<p>Hola Núñez,</p>\n<p>Tu visita está confirmada.</p>"},
+	}
+	for i, p := range parts {
+		if got := p.Header.Get("Content-Type"); got != want[i].contentType {
+			t.Errorf("part %d Content-Type = %q, want %q", i, got, want[i].contentType)
+		}
+		if got := p.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("part %d Content-Transfer-Encoding = %q, want quoted-printable", i, got)
+		}
+		for _, line := range strings.Split(partContent(p), "\n") {
+			if len(line) > 76 {
+				t.Errorf("part %d has a line of %d octets", i, len(line))
+			}
+		}
+
+		content, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(partContent(p))))
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if string(content) != want[i].content {
+			t.Errorf("part %d = %q, want %q", i, content, want[i].content)
+		}
+	}
+}
+
+func TestSendAttachment(t *testing.T) {
+	sessions := serveSMTP(t)
+
+	// Long enough for several lines, with a partial last line
+	invite := bytes.Repeat([]byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"), 20)
+	err := Send(context.Background(), &Message{
+		To:      []string{"agente@sibra.test"},
+		Subject: "Nueva visita",
+		Text:    "Tienes una visita nueva",
+		HTML:    "<p>Tienes una visita nueva</p>",
+		Attachments: []Attachment{{
+			Filename:    "visita.ics",
+			ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+			Data:        invite,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Send err: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader((<-sessions).data))
+	if err != nil {
+		t.Fatalf("ReadMessage err: %v", err)
+	}
+
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/mixed") || len(parts) != 2 {
+		t.Fatalf("got %d parts of %q, want the bodies and the attachment", len(parts), msg.Header.Get("Content-Type"))
+	}
+
+	// The bodies keep their alternatives inside the mixed message
+	bodies := readParts(t, parts[0].Header.Get("Content-Type"), strings.NewReader(partContent(parts[0])))
+	if len(bodies) != 2 {
+		t.Errorf("got %d alternatives, want 2", len(bodies))
+	}
+
+	attachment := parts[1]
+	if got := attachment.FileName(); got != "visita.ics" {
+		t.Errorf("attachment filename = %q, want visita.ics", got)
+	}
+	mediaType, params, _ := mime.ParseMediaType(attachment.Header.Get("Content-Type"))
+	if mediaType != "text/calendar" || params["method"] != "PUBLISH" || params["name"] != "visita.ics" {
+		t.Errorf("attachment Content-Type = %q, want text/calendar with its method and name", attachment.Header.Get("Content-Type"))
+	}
+	if got := attachment.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("attachment Content-Transfer-Encoding = %q, want base64", got)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(partContent(attachment), "\n"), "\n")
+	for i, line := range lines {
+		if i < len(lines)-1 && len(line) != base64LineLength {
+			t.Errorf("base64 line %d has %d octets, want %d", i, len(line), base64LineLength)
+		}
+		if len(line) == 0 || len(line) > base64LineLength {
+			t.Errorf("base64 line %d has %d octets", i, len(line))
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(lines, ""))
+	if err != nil {
+		t.Fatalf("decode attachment err: %v", err)
+	}
+	if !bytes.Equal(data, invite) {
+		t.Error("the attachment is not the data sent")
+	}
+}
@@ -0,0 +1,253 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// base64LineLength is the length the base64 attachments are wrapped at
+const base64LineLength = 76
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email, with the HTML and text bodies sent as alternatives
+// of each other. Either body can be empty.
+type Message struct {
+	To      []string
+	ReplyTo string
+	Subject string
+
+	HTML string
+	Text string
+
+	Attachments []Attachment
+}
+
+func (m *Message) recipients() ([]string, error) {
+	if len(m.To) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	recipients := make([]string, 0, len(m.To))
+	for _, to := range m.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, to)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+
+	return recipients, nil
+}
+
+// Bytes returns the message in the MIME format, ready to be sent
+func (m *Message) Bytes(from *mail.Address, date time.Time) ([]byte, error) {
+	recipients, err := m.recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", strings.Join(recipients, ", "))
+	if m.ReplyTo != "" {
+		replyTo, err := mail.ParseAddress(m.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, m.ReplyTo)
+		}
+		header.Set("Reply-To", replyTo.String())
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", date.Format(time.RFC1123Z))
+	header.Set("Message-ID", messageId(from.Address))
+	header.Set("MIME-Version", "1.0")
+
+	body, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Attachments) == 0 {
+		writeHeader(&buf, header, body.header)
+		buf.Write(body.content)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader(&buf, header, partHeader("multipart/mixed; boundary="+mixed.Boundary()))
+
+	part, err := mixed.CreatePart(body.header)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(body.content)
+
+	for _, a := range m.Attachments {
+		if err = writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = mixed.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type mimePart struct {
+	header  textproto.MIMEHeader
+	content []byte
+}
+
+// body is the content of the message without the attachments. Both
+// bodies go in a multipart/alternative, the text first so the clients
+// show the HTML.
+func (m *Message) body() (*mimePart, error) {
+	if m.HTML == "" || m.Text == "" {
+		header := partHeader("text/plain; charset=utf-8")
+		content := m.Text
+		if m.HTML != "" {
+			header = partHeader("text/html; charset=utf-8")
+			content = m.HTML
+		}
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		var buf bytes.Buffer
+		if err := writeQuotedPrintable(&buf, content); err != nil {
+			return nil, err
+		}
+		return &mimePart{header, buf.Bytes()}, nil
+	}
+
+	var buf bytes.Buffer
+	alternative := multipart.NewWriter(&buf)
+	for _, b := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		header := partHeader(b.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := alternative.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(w, b.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	return &mimePart{partHeader("multipart/alternative; boundary=" + alternative.Boundary()), buf.Bytes()}, nil
+}
+
+// writeHeader writes the header of the message followed by the one of
+// its content
+func writeHeader(buf *bytes.Buffer, header, content textproto.MIMEHeader) {
+	for _, key := range []string{"From", "To", "Reply-To", "Subject", "Date", "Message-ID", "MIME-Version"} {
+		if value := header.Get(key); value != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if value := content.Get(key); value != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+// partHeader is the header of a part of the content type
+func partHeader(contentType string) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	// The bodies are rendered with LF, the messages use CRLF
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if _, err := qp.Write([]byte(strings.ReplaceAll(content, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(w *multipart.Writer, a Attachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(mediaType(contentType), mediaParams(contentType, a.Filename)))
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > base64LineLength {
+		if _, err = io.WriteString(part, encoded[:base64LineLength]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[base64LineLength:]
+	}
+	_, err = io.WriteString(part, encoded+"\r\n")
+
+	return err
+}
+
+func mediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// mediaParams are the params of the content type with the name of the
+// file, the older clients name the attachments by it
+func mediaParams(contentType, filename string) map[string]string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params == nil {
+		params = map[string]string{}
+	}
+	if filename != "" {
+		params["name"] = filename
+	}
+	return params
+}
+
+func messageId(from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = d
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
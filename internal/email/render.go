@@ -0,0 +1,62 @@
+package email
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+var (
+	// Elements whose content is not shown
+	hiddenElements = regexp.MustCompile(`(?is)<(head|style|script)[^>]*>.*?</(head|style|script)>`)
+	// Tags that end a line of the text body
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table|ul|ol)>`)
+	listItemTags  = regexp.MustCompile(`(?i)<li[^>]*>`)
+	anyTag        = regexp.MustCompile(`<[^>]*>`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// PlainText turns the markup of a text template into plain text. templ
+// joins the lines of the templates, the text templates end their lines
+// with <br/> or wrap them in <p> and the list items in <li>.
+func PlainText(markup string) string {
+	text := hiddenElements.ReplaceAllString(markup, "")
+	text = lineBreakTags.ReplaceAllString(text, "\n")
+	text = listItemTags.ReplaceAllString(text, "- ")
+	text = anyTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")) + "\n"
+}
+
+// Render renders the bodies of the message, the text one from the html
+// component when text is nil
+func (m *Message) Render(ctx context.Context, htmlBody, text templ.Component) error {
+	var buf strings.Builder
+	if err := htmlBody.Render(ctx, &buf); err != nil {
+		return err
+	}
+	m.HTML = buf.String()
+
+	if text == nil {
+		m.Text = PlainText(m.HTML)
+		return nil
+	}
+
+	buf.Reset()
+	if err := text.Render(ctx, &buf); err != nil {
+		return err
+	}
+	m.Text = PlainText(buf.String())
+
+	return nil
+}
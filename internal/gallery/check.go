@@ -0,0 +1,250 @@
+package gallery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+const (
+	// DefaultMinAge skips the objects written recently, they may belong to
+	// an upload still in progress
+	DefaultMinAge = time.Hour
+	// DefaultPurgeAfter is how long the orphans stay in quarantine
+	DefaultPurgeAfter = 30 * 24 * time.Hour
+
+	// QuarantinePrefix is where the orphans are moved in the Private store
+	QuarantinePrefix = "quarantine"
+
+	StoreMedia   = "media"
+	StorePrivate = "private"
+
+	// EnvVarQuarantine enables moving the orphans in the daily check of
+	// the server, otherwise they are only reported
+	EnvVarQuarantine = "MEDIA_GC_QUARANTINE"
+)
+
+var ErrNoProperties = errors.New("no properties found, refusing to quarantine every file")
+
+type CheckOptions struct {
+	// Move the orphans to the quarantine instead of only reporting them
+	Quarantine bool
+	// Delete the quarantined objects older than PurgeAfter, zero keeps them
+	PurgeAfter time.Duration
+	// Objects newer than MinAge are never reported as orphans
+	MinAge time.Duration
+}
+
+// Orphan is a stored object no property references
+type Orphan struct {
+	Store string
+	Key   string
+	Size  int64
+}
+
+// Report is the result of Check
+type Report struct {
+	Properties int
+	// Ids of the deleted properties that still have files
+	OrphanDirs  []string
+	OrphanFiles []Orphan
+	// Keys of the pictures and variants referenced but not stored
+	Missing     []string
+	Recent      int
+	Quarantined int
+	Purged      int
+}
+
+func (r *Report) OrphanSize() int64 {
+	var size int64
+	for _, o := range r.OrphanFiles {
+		size += o.Size
+	}
+	return size
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf(
+		"%d propiedades, %d directorios huérfanos, %d archivos huérfanos (%.1f MB), %d faltantes, %d recientes omitidos, %d en cuarentena, %d purgados",
+		r.Properties,
+		len(r.OrphanDirs),
+		len(r.OrphanFiles),
+		float64(r.OrphanSize())/(1<<20),
+		len(r.Missing),
+		r.Recent,
+		r.Quarantined,
+		r.Purged,
+	)
+}
+
+// Check reconciles the stored property pictures with the database. It
+// reports the files of deleted properties, the files no property
+// references (failed uploads, removed pictures) and the pictures that are
+// referenced but missing. Orphans are only moved when opts.Quarantine is
+// set.
+func Check(ctx context.Context, opts CheckOptions) (*Report, error) {
+	properties, err := db.FindPropertiesMedia(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Properties: len(properties)}
+	known := map[string]bool{}
+	expectedMedia := map[string]bool{}
+	expectedPrivate := map[string]bool{}
+	for _, prop := range properties {
+		known[prop.Id] = true
+		for _, img := range prop.AllImages() {
+			expectedMedia[storage.Key(prop.MediaPrefix(), img)] = true
+			expectedPrivate[prop.OriginalKey(img)] = true
+
+			v := prop.ImgVariants[img]
+			for _, w := range v.Widths {
+				for _, f := range v.Formats {
+					expectedMedia[storage.Key(prop.MediaPrefix(), imaging.VariantName(img, w, f))] = true
+				}
+			}
+		}
+	}
+
+	mediaPrefix := (&db.Property{}).MediaPrefix() + "/"
+	privatePrefix := storage.Key("originals", mediaPrefix) + "/"
+	stores := []struct {
+		name     string
+		store    storage.Store
+		prefix   string
+		expected map[string]bool
+	}{
+		{StoreMedia, storage.Media, mediaPrefix, expectedMedia},
+		{StorePrivate, storage.Private, privatePrefix, expectedPrivate},
+	}
+
+	orphanDirs := map[string]bool{}
+	stored := map[string]bool{}
+	for _, s := range stores {
+		objects, err := s.store.List(ctx, s.prefix)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", s.name, err)
+		}
+
+		for _, obj := range objects {
+			if s.name == StoreMedia {
+				stored[obj.Key] = true
+			}
+			if s.expected[obj.Key] {
+				continue
+			}
+
+			// Only the directories of the properties are checked, the
+			// files at the root and the dotfiles (e.g. .keep) are not
+			// pictures
+			id, _, inDir := strings.Cut(strings.TrimPrefix(obj.Key, s.prefix), "/")
+			if !inDir || strings.HasPrefix(id, ".") || strings.HasPrefix(path.Base(obj.Key), ".") {
+				continue
+			}
+			if time.Since(obj.ModTime) < opts.MinAge {
+				report.Recent++
+				continue
+			}
+
+			if !known[id] {
+				orphanDirs[id] = true
+			}
+			report.OrphanFiles = append(report.OrphanFiles, Orphan{Store: s.name, Key: obj.Key, Size: obj.Size})
+		}
+	}
+
+	for id := range orphanDirs {
+		report.OrphanDirs = append(report.OrphanDirs, id)
+	}
+	// Originals are only kept since the watermark was added, so only the
+	// public files are expected to exist
+	for key := range expectedMedia {
+		if !stored[key] {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+
+	if opts.Quarantine && len(report.OrphanFiles) > 0 {
+		if len(properties) == 0 {
+			return report, ErrNoProperties
+		}
+
+		for _, orphan := range report.OrphanFiles {
+			if err = quarantine(ctx, orphan); err != nil {
+				return report, fmt.Errorf("quarantine %s: %w", orphan.Key, err)
+			}
+			report.Quarantined++
+		}
+	}
+
+	if opts.PurgeAfter > 0 {
+		report.Purged, err = purgeQuarantine(ctx, opts.PurgeAfter)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// quarantine moves the orphan to the quarantine of the Private store,
+// under the date it was moved
+func quarantine(ctx context.Context, orphan Orphan) error {
+	store := storage.Media
+	if orphan.Store == StorePrivate {
+		store = storage.Private
+	}
+
+	data, err := storage.ReadAll(ctx, store, orphan.Key)
+	if err != nil {
+		return err
+	}
+
+	key := storage.Key(QuarantinePrefix, time.Now().Format("20060102"), orphan.Store, orphan.Key)
+	err = storage.Private.Put(ctx, key, bytes.NewReader(data), storage.ContentType(key))
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(ctx, orphan.Key)
+}
+
+func purgeQuarantine(ctx context.Context, after time.Duration) (int, error) {
+	objects, err := storage.Private.List(ctx, QuarantinePrefix+"/")
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, obj := range objects {
+		if time.Since(obj.ModTime) < after {
+			continue
+		}
+		if err = storage.Private.Delete(ctx, obj.Key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RemoveAll deletes every file of the property, once it was deleted
+func RemoveAll(ctx context.Context, propertyId string) error {
+	prop := &db.Property{Id: propertyId}
+
+	return errors.Join(
+		storage.DeletePrefix(ctx, storage.Media, prop.MediaPrefix()+"/"),
+		storage.DeletePrefix(ctx, storage.Private, prop.OriginalKey("")+"/"),
+		storage.DeletePrefix(ctx, storage.Private, prop.DocumentsPrefix()+"/"),
+	)
+}
@@ -0,0 +1,109 @@
+// Package gallery stores the property pictures. The original of every
+// picture is kept without watermark in the Private store, for print and
+// the portal feeds that forbid watermarks. The public copy and its
+// variants are rendered from it, with the logo when the property has the
+// watermark enabled.
+package gallery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+// Save stores a validated upload as baseName with the extension of its
+// format and returns the file name and the variants generated
+func Save(ctx context.Context, property *db.Property, upload *imaging.Upload, baseName string) (string, *imaging.Variants, error) {
+	data, err := upload.EncodeOriginal()
+	if err != nil {
+		return "", nil, err
+	}
+
+	fileName := baseName + imaging.Extension(upload.Format)
+	key := property.OriginalKey(fileName)
+	err = storage.Private.Put(ctx, key, bytes.NewReader(data), storage.ContentType(key))
+	if err != nil {
+		return "", nil, err
+	}
+
+	variants, err := publish(ctx, property, fileName, upload.Image)
+	return fileName, variants, err
+}
+
+// Render renders the public copy of img again from its original, after
+// the watermark of the property is toggled or the variants change
+func Render(ctx context.Context, property *db.Property, img string) (*imaging.Variants, error) {
+	decoded, err := LoadOriginal(ctx, property, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return publish(ctx, property, img, decoded)
+}
+
+// LoadOriginal decodes the unwatermarked original of img
+func LoadOriginal(ctx context.Context, property *db.Property, img string) (image.Image, error) {
+	key := property.OriginalKey(img)
+	data, err := storage.ReadAll(ctx, storage.Private, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Pictures uploaded before the originals were kept privately, their
+		// public copy has never been watermarked
+		data, err = storage.ReadAll(ctx, storage.Media, storage.Key(property.MediaPrefix(), img))
+		if err == nil {
+			err = storage.Private.Put(ctx, key, bytes.NewReader(data), storage.ContentType(key))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.Decode(data)
+}
+
+// Remove deletes img, its original and its variants
+func Remove(ctx context.Context, property *db.Property, img string) error {
+	prefix := property.MediaPrefix()
+	var errs []error
+	if v, ok := property.ImgVariants[img]; ok {
+		errs = append(errs, imaging.RemoveVariants(ctx, storage.Media, prefix, img, v))
+		delete(property.ImgVariants, img)
+	}
+
+	errs = append(
+		errs,
+		storage.Media.Delete(ctx, storage.Key(prefix, img)),
+		storage.Private.Delete(ctx, property.OriginalKey(img)),
+	)
+
+	return errors.Join(errs...)
+}
+
+// publish stores the public copy of img and its variants
+func publish(ctx context.Context, property *db.Property, img string, decoded image.Image) (*imaging.Variants, error) {
+	if property.Watermark {
+		wm, err := imaging.DefaultWatermark()
+		if err != nil {
+			return nil, err
+		}
+		decoded = wm.Apply(decoded)
+	}
+
+	data, err := imaging.EncodeOriginal(decoded, imaging.FormatOf(img))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := property.MediaPrefix()
+	key := storage.Key(prefix, img)
+	err = storage.Media.Put(ctx, key, bytes.NewReader(data), storage.ContentType(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.WriteVariants(ctx, decoded, storage.Media, prefix, img)
+}
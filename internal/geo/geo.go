@@ -0,0 +1,70 @@
+// Package geo parses and validates the geographic boundaries used by the
+// site (neighborhood polygons, points of interest, etc.)
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrNoFeatures        = errors.New("file does not contain any polygon")
+	ErrInvalidPolygon    = errors.New("invalid polygon")
+)
+
+// DefaultSimplifyTolerance is the tolerance (in degrees) used to simplify
+// boundaries for display. ~0.00005° is roughly 5m around Durango.
+const DefaultSimplifyTolerance = 0.00005
+
+// Feature is a named polygon read from a GeoJSON or KML file
+type Feature struct {
+	Name       string
+	Boundaries orb.MultiPolygon
+	Properties map[string]any
+}
+
+// ReadFeatures parses the polygons contained in r, choosing the parser
+// by the extension of fileName (.geojson, .json or .kml).
+//
+// Every polygon is validated before being returned.
+func ReadFeatures(fileName string, r io.Reader) ([]Feature, error) {
+	var (
+		features []Feature
+		err      error
+	)
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".geojson", ".json":
+		features, err = ParseGeoJSON(r)
+	case ".kml":
+		features, err = ParseKML(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(fileName))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(features) == 0 {
+		return nil, ErrNoFeatures
+	}
+
+	for i, f := range features {
+		if err := ValidateMultiPolygon(f.Boundaries); err != nil {
+			name := f.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return features, nil
+}
@@ -0,0 +1,162 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/paulmach/orb"
+)
+
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+type geoJSONFeature struct {
+	Type       string           `json:"type"`
+	Geometry   *geoJSONGeometry `json:"geometry"`
+	Properties map[string]any   `json:"properties"`
+}
+
+// ParseGeoJSON reads a FeatureCollection, a Feature or a bare geometry and
+// returns its Polygon and MultiPolygon features. Other geometry types are
+// ignored.
+//
+// The feature name is taken from the "name" or "nombre" properties.
+func ParseGeoJSON(r io.Reader) ([]Feature, error) {
+	rawFeatures, err := decodeGeoJSONFeatures(r)
+	if err != nil {
+		return nil, err
+	}
+
+	features := []Feature{}
+	for _, rf := range rawFeatures {
+		if rf.Geometry == nil {
+			continue
+		}
+
+		mp, err := geoJSONToMultiPolygon(rf.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		if len(mp) == 0 {
+			continue
+		}
+
+		features = append(features, Feature{
+			Name:       featureName(rf.Properties),
+			Boundaries: mp,
+			Properties: rf.Properties,
+		})
+	}
+
+	return features, nil
+}
+
+// decodeGeoJSONFeatures reads a FeatureCollection, a Feature or a bare
+// geometry as a list of features
+func decodeGeoJSONFeatures(r io.Reader) ([]geoJSONFeature, error) {
+	var raw map[string]json.RawMessage
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid geojson: %w", err)
+	}
+
+	var docType string
+	_ = json.Unmarshal(raw["type"], &docType)
+
+	switch docType {
+	case "FeatureCollection":
+		var fc struct {
+			Features []geoJSONFeature `json:"features"`
+		}
+		if err = json.Unmarshal(data, &fc); err != nil {
+			return nil, fmt.Errorf("invalid geojson: %w", err)
+		}
+		return fc.Features, nil
+	case "Feature":
+		var f geoJSONFeature
+		if err = json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("invalid geojson: %w", err)
+		}
+		return []geoJSONFeature{f}, nil
+	default:
+		var g geoJSONGeometry
+		if err = json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("invalid geojson: %w", err)
+		}
+		return []geoJSONFeature{{Type: "Feature", Geometry: &g}}, nil
+	}
+}
+
+func geoJSONToMultiPolygon(g *geoJSONGeometry) (orb.MultiPolygon, error) {
+	switch g.Type {
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		poly, err := toPolygon(coords)
+		if err != nil {
+			return nil, err
+		}
+		return orb.MultiPolygon{poly}, nil
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid multipolygon coordinates: %w", err)
+		}
+		mp := orb.MultiPolygon{}
+		for _, c := range coords {
+			poly, err := toPolygon(c)
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, poly)
+		}
+		return mp, nil
+	case "GeometryCollection":
+		mp := orb.MultiPolygon{}
+		for i := range g.Geometries {
+			sub, err := geoJSONToMultiPolygon(&g.Geometries[i])
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, sub...)
+		}
+		return mp, nil
+	default:
+		return nil, nil
+	}
+}
+
+func toPolygon(coords [][][]float64) (orb.Polygon, error) {
+	poly := orb.Polygon{}
+	for _, rc := range coords {
+		ring := orb.Ring{}
+		for _, c := range rc {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("%w: position with less than 2 values", ErrInvalidPolygon)
+			}
+			ring = append(ring, orb.Point{c[0], c[1]})
+		}
+		poly = append(poly, ring)
+	}
+
+	return poly, nil
+}
+
+func featureName(props map[string]any) string {
+	for _, k := range []string{"name", "nombre", "NOMBRE", "Name", "NOM_ASEN"} {
+		if v, ok := props[k].(string); ok && v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,151 @@
+package geo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+type kmlLinearRing struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlBoundary struct {
+	LinearRing kmlLinearRing `xml:"LinearRing"`
+}
+
+type kmlPolygon struct {
+	Outer kmlBoundary   `xml:"outerBoundaryIs"`
+	Inner []kmlBoundary `xml:"innerBoundaryIs"`
+}
+
+type kmlMultiGeometry struct {
+	Polygons []kmlPolygon       `xml:"Polygon"`
+	Multi    []kmlMultiGeometry `xml:"MultiGeometry"`
+}
+
+type kmlPlacemark struct {
+	Name          string            `xml:"name"`
+	Polygon       *kmlPolygon       `xml:"Polygon"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry"`
+}
+
+// ParseKML reads every Placemark with a Polygon or MultiGeometry in the
+// document, regardless of how deep it is nested inside Folders.
+func ParseKML(r io.Reader) ([]Feature, error) {
+	decoder := xml.NewDecoder(r)
+	features := []Feature{}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid kml: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+
+		var pm kmlPlacemark
+		if err = decoder.DecodeElement(&pm, &start); err != nil {
+			return nil, fmt.Errorf("invalid kml placemark: %w", err)
+		}
+
+		mp := orb.MultiPolygon{}
+		if pm.Polygon != nil {
+			poly, err := kmlToPolygon(pm.Polygon)
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, poly)
+		}
+		if pm.MultiGeometry != nil {
+			polys, err := kmlMultiToPolygons(pm.MultiGeometry)
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, polys...)
+		}
+
+		if len(mp) == 0 {
+			continue
+		}
+
+		features = append(features, Feature{
+			Name:       strings.TrimSpace(pm.Name),
+			Boundaries: mp,
+		})
+	}
+
+	return features, nil
+}
+
+func kmlMultiToPolygons(mg *kmlMultiGeometry) (orb.MultiPolygon, error) {
+	mp := orb.MultiPolygon{}
+	for i := range mg.Polygons {
+		poly, err := kmlToPolygon(&mg.Polygons[i])
+		if err != nil {
+			return nil, err
+		}
+		mp = append(mp, poly)
+	}
+	for i := range mg.Multi {
+		polys, err := kmlMultiToPolygons(&mg.Multi[i])
+		if err != nil {
+			return nil, err
+		}
+		mp = append(mp, polys...)
+	}
+
+	return mp, nil
+}
+
+func kmlToPolygon(p *kmlPolygon) (orb.Polygon, error) {
+	outer, err := parseKMLCoordinates(p.Outer.LinearRing.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	poly := orb.Polygon{outer}
+	for _, in := range p.Inner {
+		ring, err := parseKMLCoordinates(in.LinearRing.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+		poly = append(poly, ring)
+	}
+
+	return poly, nil
+}
+
+// parseKMLCoordinates parses the "lon,lat[,alt] lon,lat[,alt] ..." format
+func parseKMLCoordinates(coords string) (orb.Ring, error) {
+	ring := orb.Ring{}
+	for _, tuple := range strings.Fields(coords) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%w: invalid coordinate %q", ErrInvalidPolygon, tuple)
+		}
+
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid longitude %q", ErrInvalidPolygon, parts[0])
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid latitude %q", ErrInvalidPolygon, parts[1])
+		}
+
+		ring = append(ring, orb.Point{lon, lat})
+	}
+
+	return ring, nil
+}
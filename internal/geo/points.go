@@ -0,0 +1,211 @@
+package geo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+var (
+	ErrNoPoints     = errors.New("file does not contain any point")
+	ErrInvalidPoint = errors.New("invalid point")
+)
+
+// PointFeature is a named location read from a GeoJSON or CSV file
+type PointFeature struct {
+	Name       string
+	Category   string
+	Point      orb.Point
+	Properties map[string]any
+}
+
+var (
+	csvNameColumns     = []string{"name", "nombre"}
+	csvCategoryColumns = []string{"category", "categoria", "categoría", "tipo", "amenity"}
+	csvLatColumns      = []string{"lat", "latitude", "latitud"}
+	csvLonColumns      = []string{"lon", "lng", "longitude", "longitud"}
+)
+
+// ReadPoints parses the points contained in r, choosing the parser by the
+// extension of fileName (.geojson, .json or .csv).
+//
+// Every point is checked to be within the lon/lat range.
+func ReadPoints(fileName string, r io.Reader) ([]PointFeature, error) {
+	var (
+		points []PointFeature
+		err    error
+	)
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".geojson", ".json":
+		points, err = ParseGeoJSONPoints(r)
+	case ".csv":
+		points, err = ParseCSVPoints(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(fileName))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(points) == 0 {
+		return nil, ErrNoPoints
+	}
+
+	for i, p := range points {
+		if p.Point.Lon() < -180 || p.Point.Lon() > 180 || p.Point.Lat() < -90 || p.Point.Lat() > 90 {
+			name := p.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("%s: %w: coordinates out of range", name, ErrInvalidPoint)
+		}
+	}
+
+	return points, nil
+}
+
+// ParseGeoJSONPoints returns the Point features of a GeoJSON document,
+// MultiPoint features produce one point per position. Other geometry types
+// are ignored.
+//
+// The category is taken from the "category", "categoria" or "amenity"
+// properties.
+func ParseGeoJSONPoints(r io.Reader) ([]PointFeature, error) {
+	rawFeatures, err := decodeGeoJSONFeatures(r)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []PointFeature{}
+	for _, rf := range rawFeatures {
+		if rf.Geometry == nil {
+			continue
+		}
+
+		var positions [][]float64
+		switch rf.Geometry.Type {
+		case "Point":
+			var c []float64
+			if err := json.Unmarshal(rf.Geometry.Coordinates, &c); err != nil {
+				return nil, fmt.Errorf("invalid point coordinates: %w", err)
+			}
+			positions = [][]float64{c}
+		case "MultiPoint":
+			if err := json.Unmarshal(rf.Geometry.Coordinates, &positions); err != nil {
+				return nil, fmt.Errorf("invalid multipoint coordinates: %w", err)
+			}
+		default:
+			continue
+		}
+
+		for _, c := range positions {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("%w: position with less than 2 values", ErrInvalidPoint)
+			}
+			points = append(points, PointFeature{
+				Name:       featureName(rf.Properties),
+				Category:   featureCategory(rf.Properties),
+				Point:      orb.Point{c[0], c[1]},
+				Properties: rf.Properties,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+// ParseCSVPoints reads a CSV file with a header row. The lat and lon
+// columns are required, name and category are optional. Columns are
+// matched case-insensitively by their english or spanish name.
+func ParseCSVPoints(r io.Reader) ([]PointFeature, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoPoints
+		}
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	nameIdx := csvColumn(header, csvNameColumns)
+	categoryIdx := csvColumn(header, csvCategoryColumns)
+	latIdx := csvColumn(header, csvLatColumns)
+	lonIdx := csvColumn(header, csvLonColumns)
+	if latIdx < 0 || lonIdx < 0 {
+		return nil, errors.New("invalid csv: missing lat or lon column")
+	}
+
+	points := []PointFeature{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		lat, latErr := strconv.ParseFloat(csvField(record, latIdx), 64)
+		lon, lonErr := strconv.ParseFloat(csvField(record, lonIdx), 64)
+		if latErr != nil || lonErr != nil {
+			return nil, fmt.Errorf("line %d: %w: lat and lon must be numbers", line, ErrInvalidPoint)
+		}
+
+		props := make(map[string]any, len(header))
+		for i, col := range header {
+			props[col] = csvField(record, i)
+		}
+
+		points = append(points, PointFeature{
+			Name:       csvField(record, nameIdx),
+			Category:   csvField(record, categoryIdx),
+			Point:      orb.Point{lon, lat},
+			Properties: props,
+		})
+	}
+
+	return points, nil
+}
+
+func csvColumn(header []string, names []string) int {
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		for _, n := range names {
+			if col == n {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func csvField(record []string, idx int) string {
+	if idx < 0 || idx >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[idx])
+}
+
+func featureCategory(props map[string]any) string {
+	for _, k := range []string{"category", "categoria", "amenity", "leisure", "shop"} {
+		if v, ok := props[k].(string); ok && v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// SimplifyMultiPolygon reduces the vertices of every ring in mp using the
+// Douglas-Peucker algorithm. Rings that would collapse below 4 positions
+// are kept as they are.
+func SimplifyMultiPolygon(mp orb.MultiPolygon, tolerance float64) orb.MultiPolygon {
+	simplified := make(orb.MultiPolygon, 0, len(mp))
+	for _, poly := range mp {
+		sPoly := make(orb.Polygon, 0, len(poly))
+		for _, ring := range poly {
+			sRing := simplifyRing(ring, tolerance)
+			if len(sRing) < 4 || validateRing(sRing) != nil {
+				sRing = ring.Clone()
+			}
+			sPoly = append(sPoly, sRing)
+		}
+		simplified = append(simplified, sPoly)
+	}
+
+	return simplified
+}
+
+func simplifyRing(ring orb.Ring, tolerance float64) orb.Ring {
+	if len(ring) <= 4 {
+		return ring.Clone()
+	}
+
+	keep := make([]bool, len(ring))
+	keep[0] = true
+	keep[len(ring)-1] = true
+	douglasPeucker(ring, 0, len(ring)-1, tolerance, keep)
+
+	// A closed ring starts and ends on the same point, so the farthest point
+	// from it is always kept to avoid collapsing the ring into a line
+	far, farDist := 0, 0.0
+	for i := 1; i < len(ring)-1; i++ {
+		if d := pointDistance(ring[0], ring[i]); d > farDist {
+			far, farDist = i, d
+		}
+	}
+	if far > 0 && !keep[far] {
+		keep[far] = true
+		douglasPeucker(ring, 0, far, tolerance, keep)
+		douglasPeucker(ring, far, len(ring)-1, tolerance, keep)
+	}
+
+	simplified := orb.Ring{}
+	for i, p := range ring {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+
+	return simplified
+}
+
+func douglasPeucker(points orb.Ring, first, last int, tolerance float64, keep []bool) {
+	if last-first < 2 {
+		return
+	}
+
+	idx, maxDist := 0, 0.0
+	for i := first + 1; i < last; i++ {
+		d := perpendicularDistance(points[i], points[first], points[last])
+		if d > maxDist {
+			idx, maxDist = i, d
+		}
+	}
+
+	if maxDist > tolerance {
+		keep[idx] = true
+		douglasPeucker(points, first, idx, tolerance, keep)
+		douglasPeucker(points, idx, last, tolerance, keep)
+	}
+}
+
+func pointDistance(a, b orb.Point) float64 {
+	return math.Hypot(b[0]-a[0], b[1]-a[1])
+}
+
+func perpendicularDistance(p, a, b orb.Point) float64 {
+	if a == b {
+		return pointDistance(p, a)
+	}
+
+	num := math.Abs((b[1]-a[1])*p[0] - (b[0]-a[0])*p[1] + b[0]*a[1] - b[1]*a[0])
+	return num / pointDistance(a, b)
+}
@@ -0,0 +1,170 @@
+package geo
+
+import (
+	"fmt"
+
+	"github.com/paulmach/orb"
+)
+
+// ValidateMultiPolygon checks every polygon of mp with ValidatePolygon
+func ValidateMultiPolygon(mp orb.MultiPolygon) error {
+	if len(mp) == 0 {
+		return fmt.Errorf("%w: empty geometry", ErrInvalidPolygon)
+	}
+
+	for i, poly := range mp {
+		if err := ValidatePolygon(poly); err != nil {
+			if len(mp) > 1 {
+				return fmt.Errorf("polygon %d: %w", i+1, err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidatePolygon checks that every ring of the polygon is closed, has at
+// least 3 distinct vertices, uses valid lon/lat values and does not
+// intersect itself, and that the holes are inside the outer ring and
+// apart from each other
+func ValidatePolygon(poly orb.Polygon) error {
+	if len(poly) == 0 {
+		return fmt.Errorf("%w: polygon has no rings", ErrInvalidPolygon)
+	}
+
+	for i, ring := range poly {
+		if err := validateRing(ring); err != nil {
+			if i == 0 {
+				return fmt.Errorf("outer ring: %w", err)
+			}
+			return fmt.Errorf("inner ring %d: %w", i, err)
+		}
+	}
+
+	return validateHoles(poly)
+}
+
+// validateHoles checks that no inner ring crosses or touches the outer
+// ring or another inner ring, that they are inside the outer ring and that
+// none is inside another one
+func validateHoles(poly orb.Polygon) error {
+	outer := poly[0]
+	for i := 1; i < len(poly); i++ {
+		hole := poly[i]
+		if ringsIntersect(outer, hole) || !ringContains(outer, hole[0]) {
+			return fmt.Errorf("%w: inner ring %d is not inside the outer ring", ErrInvalidPolygon, i)
+		}
+
+		for j := i + 1; j < len(poly); j++ {
+			other := poly[j]
+			if ringsIntersect(hole, other) || ringContains(hole, other[0]) || ringContains(other, hole[0]) {
+				return fmt.Errorf("%w: inner rings %d and %d overlap", ErrInvalidPolygon, i, j)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ringsIntersect tells if any segment of a crosses or touches one of b
+func ringsIntersect(a, b orb.Ring) bool {
+	for i := 0; i < len(a)-1; i++ {
+		for j := 0; j < len(b)-1; j++ {
+			if segmentsIntersect(a[i], a[i+1], b[j], b[j+1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ringContains tells if p is inside the closed ring by counting the edges
+// a ray from p crosses. The points on the edges are left to
+// ringsIntersect.
+func ringContains(ring orb.Ring, p orb.Point) bool {
+	inside := false
+	for i := 0; i < len(ring)-1; i++ {
+		a, b := ring[i], ring[i+1]
+		if (a[1] > p[1]) != (b[1] > p[1]) &&
+			p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func validateRing(ring orb.Ring) error {
+	if len(ring) < 4 {
+		return fmt.Errorf("%w: ring must have at least 4 positions", ErrInvalidPolygon)
+	}
+
+	if !ring.Closed() {
+		return fmt.Errorf("%w: ring is not closed", ErrInvalidPolygon)
+	}
+
+	for _, p := range ring {
+		if p.Lon() < -180 || p.Lon() > 180 || p.Lat() < -90 || p.Lat() > 90 {
+			return fmt.Errorf("%w: coordinate out of range (%f, %f)", ErrInvalidPolygon, p.Lon(), p.Lat())
+		}
+	}
+
+	// The ring is closed, so the last segment ends on the first point
+	segCount := len(ring) - 1
+	for i := 0; i < segCount; i++ {
+		a1, a2 := ring[i], ring[i+1]
+		for j := i + 1; j < segCount; j++ {
+			// Adjacent segments always share a vertex
+			if j == i+1 || (i == 0 && j == segCount-1) {
+				continue
+			}
+
+			b1, b2 := ring[j], ring[j+1]
+			if segmentsIntersect(a1, a2, b1, b2) {
+				return fmt.Errorf(
+					"%w: ring intersects itself near (%f, %f)",
+					ErrInvalidPolygon,
+					a1.Lon(),
+					a1.Lat(),
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+func orientation(p, q, r orb.Point) int {
+	val := (q[1]-p[1])*(r[0]-q[0]) - (q[0]-p[0])*(r[1]-q[1])
+	switch {
+	case val > 0:
+		return 1
+	case val < 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func onSegment(p, q, r orb.Point) bool {
+	return q[0] <= max(p[0], r[0]) && q[0] >= min(p[0], r[0]) &&
+		q[1] <= max(p[1], r[1]) && q[1] >= min(p[1], r[1])
+}
+
+func segmentsIntersect(p1, q1, p2, q2 orb.Point) bool {
+	o1 := orientation(p1, q1, p2)
+	o2 := orientation(p1, q1, q2)
+	o3 := orientation(p2, q2, p1)
+	o4 := orientation(p2, q2, q1)
+
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+
+	return (o1 == 0 && onSegment(p1, p2, q1)) ||
+		(o2 == 0 && onSegment(p1, q2, q1)) ||
+		(o3 == 0 && onSegment(p2, p1, q2)) ||
+		(o4 == 0 && onSegment(p2, q1, q2))
+}
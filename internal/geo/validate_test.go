@@ -0,0 +1,42 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// square is the closed ring of the square from (x, y) with side size
+func square(x, y, size float64) orb.Ring {
+	return orb.Ring{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+}
+
+func TestValidatePolygonHoles(t *testing.T) {
+	outer := square(-104.7, 24.0, 0.1)
+
+	tests := []struct {
+		name  string
+		poly  orb.Polygon
+		valid bool
+	}{
+		{"no holes", orb.Polygon{outer}, true},
+		{"hole inside", orb.Polygon{outer, square(-104.68, 24.02, 0.02)}, true},
+		{"holes apart", orb.Polygon{outer, square(-104.68, 24.02, 0.02), square(-104.64, 24.06, 0.02)}, true},
+		{"hole outside", orb.Polygon{outer, square(-104.5, 24.02, 0.02)}, false},
+		{"hole crossing the outer ring", orb.Polygon{outer, square(-104.62, 24.02, 0.05)}, false},
+		{"hole around the outer ring", orb.Polygon{outer, square(-104.8, 23.9, 0.3)}, false},
+		{"hole touching the outer ring", orb.Polygon{outer, square(-104.7, 24.02, 0.02)}, false},
+		{"holes crossing", orb.Polygon{outer, square(-104.68, 24.02, 0.03), square(-104.66, 24.04, 0.03)}, false},
+		{"hole inside a hole", orb.Polygon{outer, square(-104.68, 24.02, 0.06), square(-104.66, 24.04, 0.01)}, false},
+	}
+	for _, tt := range tests {
+		err := ValidatePolygon(tt.poly)
+		if tt.valid && err != nil {
+			t.Errorf("%s: ValidatePolygon err: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPolygon) {
+			t.Errorf("%s: ValidatePolygon err = %v, want %v", tt.name, err, ErrInvalidPolygon)
+		}
+	}
+}
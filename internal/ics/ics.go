@@ -0,0 +1,169 @@
+// Package ics writes iCalendar (RFC 5545) files, the feeds and invites
+// that calendar apps subscribe to or import
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	// The calendar is published, not sent as a meeting request, so apps
+	// add the events without asking for a reply
+	MethodPublish = "PUBLISH"
+
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+
+	// maxLineLength is the length in octets lines are folded at
+	maxLineLength = 75
+	timeLayout    = "20060102T150405Z"
+)
+
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Status      string
+	// Coordinates of Location, only written when both are set
+	Lat, Lon float64
+	// Updated is when the event last changed, used as its DTSTAMP
+	Updated time.Time
+}
+
+type Calendar struct {
+	Name   string
+	Method string
+	Events []*Event
+	// RefreshInterval is how often subscribed apps should reload the feed
+	RefreshInterval time.Duration
+}
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line folded at maxLineLength octets without
+// splitting UTF-8 sequences
+func (w *writer) line(name, value string) {
+	if w.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+	limit := maxLineLength
+	for len(l) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(l[cut]) {
+			cut--
+		}
+		w.w.WriteString(l[:cut])
+		w.w.WriteString("\r\n ")
+		l = l[cut:]
+		// Continuation lines start with a space
+		limit = maxLineLength - 1
+	}
+	_, w.err = w.w.WriteString(l + "\r\n")
+}
+
+// WriteTo writes the calendar, lines end with CRLF as the RFC requires
+func (c *Calendar) WriteTo(out io.Writer) (int64, error) {
+	counter := &countingWriter{w: out}
+	w := &writer{w: bufio.NewWriter(counter)}
+
+	method := c.Method
+	if method == "" {
+		method = MethodPublish
+	}
+
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//Sibra Durango//Visitas//ES")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", method)
+	if c.Name != "" {
+		w.line("X-WR-CALNAME", escape(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		minutes := int(c.RefreshInterval / time.Minute)
+		w.line("REFRESH-INTERVAL;VALUE=DURATION", fmt.Sprintf("PT%dM", minutes))
+		w.line("X-PUBLISHED-TTL", fmt.Sprintf("PT%dM", minutes))
+	}
+
+	for _, e := range c.Events {
+		updated := e.Updated
+		if updated.IsZero() {
+			updated = time.Now()
+		}
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", e.UID)
+		w.line("DTSTAMP", formatTime(updated))
+		w.line("LAST-MODIFIED", formatTime(updated))
+		w.line("SEQUENCE", fmt.Sprint(updated.Unix()))
+		w.line("DTSTART", formatTime(e.Start))
+		w.line("DTEND", formatTime(e.End))
+		w.line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			w.line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Location != "" {
+			w.line("LOCATION", escape(e.Location))
+		}
+		if e.Lat != 0 && e.Lon != 0 {
+			w.line("GEO", fmt.Sprintf("%f;%f", e.Lat, e.Lon))
+		}
+		if e.URL != "" {
+			w.line("URL", e.URL)
+		}
+		if e.Status != "" {
+			w.line("STATUS", e.Status)
+		}
+		w.line("END", "VEVENT")
+	}
+
+	w.line("END", "VCALENDAR")
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+
+	return counter.n, w.err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,89 @@
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Visita", "Visita"},
+		{"Casa; 3 recámaras, 2 baños", `Casa\; 3 recámaras\, 2 baños`},
+		{`C:\ruta`, `C:\\ruta`},
+		{"Línea 1\nLínea 2", `Línea 1\nLínea 2`},
+		{"Línea 1\r\nLínea 2", `Línea 1\nLínea 2`},
+		// A stray CR would end the content line in some readers
+		{"Línea 1\rLínea 2", `Línea 1Línea 2`},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// unfold joins the continuation lines back into their content lines
+func unfold(s string) string {
+	return strings.ReplaceAll(s, "\r\n ", "")
+}
+
+func TestWriteToFolds(t *testing.T) {
+	start := time.Date(2026, time.October, 20, 10, 0, 0, 0, time.UTC)
+	description := strings.Repeat("Visita a la casa en el fraccionamiento Jardines de Durango, ", 4) +
+		strings.Repeat("ñ", 100) + strings.Repeat("a", 37) + strings.Repeat("€", 40)
+
+	cal := &Calendar{
+		Name: "Visitas",
+		Events: []*Event{{
+			UID:         "visita-1@sibra",
+			Start:       start,
+			End:         start.Add(time.Hour),
+			Summary:     "Visita",
+			Description: description,
+			Updated:     start,
+		}},
+	}
+
+	var buf bytes.Buffer
+	n, err := cal.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo err: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo = %d, wrote %d", n, buf.Len())
+	}
+
+	out := buf.String()
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Error("the calendar does not end with CRLF")
+	}
+
+	folded := 0
+	for i, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line %d has %d octets: %q", i, len(line), line)
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("line %d has a bare line break: %q", i, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a character: %q", i, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded++
+		}
+	}
+	if folded == 0 {
+		t.Error("the description was not folded")
+	}
+
+	want := "DESCRIPTION:" + escape(description) + "\r\n"
+	if !strings.Contains(unfold(out), want) {
+		t.Errorf("the unfolded calendar does not have %q", want)
+	}
+}
@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// headerSize returns the dimensions declared in the header of the image,
+// without decoding it. WebP and HEIC are read here since the Go decoders
+// don't cover them, so their size is known before the conversion tools
+// allocate the bitmap.
+func headerSize(format string, data []byte) (width, height int, err error) {
+	switch format {
+	case FormatWebP:
+		return webpSize(data)
+	case FormatHEIC:
+		return heifSize(data)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, ErrUnsupportedImage
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// webpSize reads the size from the first chunk of the RIFF container, one
+// of VP8 (lossy), VP8L (lossless) or VP8X (extended, the canvas size)
+func webpSize(data []byte) (int, int, error) {
+	if len(data) < 30 {
+		return 0, 0, ErrUnsupportedImage
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ":
+		// 3 bytes of frame tag, the start code, then 14 bits per side
+		if !bytes.Equal(chunk[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, ErrUnsupportedImage
+		}
+		w := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		return w, h, nil
+	case "VP8L":
+		// The signature, then 14 bits per side minus one
+		if chunk[0] != 0x2f {
+			return 0, 0, ErrUnsupportedImage
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		// 4 bytes of flags, then 24 bits per side minus one
+		w := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		h := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return w + 1, h + 1, nil
+	}
+
+	return 0, 0, ErrUnsupportedImage
+}
+
+// heifSize reads the image spatial extents (ispe) of the item properties
+// in meta/iprp/ipco. A file has one per image, tile and thumbnail, the
+// largest is returned so none of them goes past the limits.
+func heifSize(data []byte) (int, int, error) {
+	meta := findBox(data, "meta")
+	if len(meta) < 4 {
+		return 0, 0, ErrUnsupportedImage
+	}
+	// meta is a full box, version and flags come first
+	ipco := findBox(findBox(meta[4:], "iprp"), "ipco")
+
+	var width, height int
+	for rest := ipco; len(rest) > 0; {
+		typ, body, next := nextBox(rest)
+		if typ == "" {
+			break
+		}
+		if typ == "ispe" && len(body) >= 12 {
+			w := int(binary.BigEndian.Uint32(body[4:8]))
+			h := int(binary.BigEndian.Uint32(body[8:12]))
+			if w*h > width*height {
+				width, height = w, h
+			}
+		}
+		rest = next
+	}
+
+	if width == 0 || height == 0 {
+		return 0, 0, ErrUnsupportedImage
+	}
+	return width, height, nil
+}
+
+// findBox returns the body of the first box of type typ in data
+func findBox(data []byte, typ string) []byte {
+	for len(data) > 0 {
+		t, body, next := nextBox(data)
+		if t == "" {
+			return nil
+		}
+		if t == typ {
+			return body
+		}
+		data = next
+	}
+	return nil
+}
+
+// nextBox splits the first ISO BMFF box of data into its type and body,
+// the type is empty when the box is malformed
+func nextBox(data []byte) (typ string, body, rest []byte) {
+	if len(data) < 8 {
+		return "", nil, nil
+	}
+
+	size := uint64(binary.BigEndian.Uint32(data[:4]))
+	header := uint64(8)
+	switch size {
+	case 0:
+		// The box runs to the end of the data
+		size = uint64(len(data))
+	case 1:
+		if len(data) < 16 {
+			return "", nil, nil
+		}
+		size = binary.BigEndian.Uint64(data[8:16])
+		header = 16
+	}
+	if size < header || size > uint64(len(data)) {
+		return "", nil, nil
+	}
+
+	return string(data[4:8]), data[header:size], data[size:]
+}
@@ -0,0 +1,157 @@
+// Package imaging decodes uploaded pictures and generates the resized
+// variants served to the browsers
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+
+	"github.com/vladwithcode/sibra-site/internal/storage"
+
+	// Register the decoders accepted on upload
+	_ "image/png"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+
+	JPEGQuality = 82
+	WebPQuality = 80
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+// Widths are the variant widths generated for every picture. Images
+// narrower than a width are not upscaled.
+var Widths = []int{320, 640, 1280, 1920}
+
+// Variants describes the files generated for a picture
+type Variants struct {
+	Widths  []int    `json:"widths"`
+	Formats []string `json:"formats"`
+}
+
+// Has reports whether v contains the format
+func (v Variants) Has(format string) bool {
+	for _, f := range v.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Largest returns the widest variant, or 0 if there are none
+func (v Variants) Largest() int {
+	largest := 0
+	for _, w := range v.Widths {
+		largest = max(largest, w)
+	}
+	return largest
+}
+
+// VariantName returns the file name of the variant of the picture name,
+// e.g. "foto.png" at 640 in webp is "foto-640.webp"
+func VariantName(name string, width int, format string) string {
+	ext := ".jpg"
+	if format == FormatWebP {
+		ext = ".webp"
+	}
+
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, filepath.Ext(name)), width, ext)
+}
+
+// Decode decodes a JPEG or PNG image, rotating it according to its
+// EXIF orientation
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedImage
+		}
+		return nil, err
+	}
+
+	return applyOrientation(img, readOrientation(data)), nil
+}
+
+// variantWidths returns the widths to generate for an image srcWidth wide.
+// If the image is narrower than the largest width its own width is added
+// so the sharpest version is still available.
+func variantWidths(srcWidth int) []int {
+	widths := []int{}
+	for _, w := range Widths {
+		if w <= srcWidth {
+			widths = append(widths, w)
+		}
+	}
+
+	if len(widths) == 0 || (widths[len(widths)-1] != srcWidth && srcWidth < Widths[len(Widths)-1]) {
+		widths = append(widths, srcWidth)
+	}
+
+	return widths
+}
+
+// WriteVariants stores the variants of a decoded image under prefix,
+// named after name with VariantName. WebP variants are only generated
+// when cwebp is installed, see WebPAvailable.
+func WriteVariants(ctx context.Context, img image.Image, store storage.Store, prefix, name string) (*Variants, error) {
+	variants := &Variants{
+		Formats: []string{FormatJPEG},
+	}
+	withWebP := WebPAvailable()
+	if withWebP {
+		variants.Formats = append(variants.Formats, FormatWebP)
+	}
+
+	for _, width := range variantWidths(img.Bounds().Dx()) {
+		resized := Resize(img, width)
+
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: JPEGQuality})
+		if err != nil {
+			return nil, err
+		}
+		err = store.Put(ctx, storage.Key(prefix, VariantName(name, width, FormatJPEG)), &buf, "image/jpeg")
+		if err != nil {
+			return nil, err
+		}
+
+		if withWebP {
+			data, err := encodeWebP(resized)
+			if err != nil {
+				return nil, err
+			}
+			err = store.Put(ctx, storage.Key(prefix, VariantName(name, width, FormatWebP)), bytes.NewReader(data), "image/webp")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		variants.Widths = append(variants.Widths, width)
+	}
+
+	return variants, nil
+}
+
+// RemoveVariants deletes the variants of the picture name stored under
+// prefix
+func RemoveVariants(ctx context.Context, store storage.Store, prefix, name string, variants Variants) error {
+	for _, w := range variants.Widths {
+		for _, f := range variants.Formats {
+			if err := store.Delete(ctx, storage.Key(prefix, VariantName(name, w, f))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// DHash returns the difference hash of img: it is shrunk to 9x8 gray
+// pixels and every bit tells whether a pixel is brighter than its right
+// neighbor. Resized, recompressed or watermarked copies of a photo keep a
+// hash within a few bits of the original.
+func DHash(img image.Image) uint64 {
+	small := resample(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luma(small, x, y) > luma(small, x+1, y) {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+
+	return hash
+}
+
+func luma(img *image.RGBA, x, y int) uint8 {
+	return color.GrayModel.Convert(img.RGBAAt(x, y)).(color.Gray).Y
+}
+
+// HammingDistance is the number of different bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
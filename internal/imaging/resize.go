@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions computes the triangle filter weights to resample a line of
+// srcSize pixels into dstSize pixels. When downscaling the filter is
+// widened so every source pixel is accounted for.
+func contributions(srcSize, dstSize int) []contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	support := math.Max(scale, 1)
+
+	contribs := make([]contribution, dstSize)
+	for i := range contribs {
+		center := (float64(i)+0.5)*scale - 0.5
+		start := int(math.Ceil(center - support))
+		end := int(math.Floor(center + support))
+
+		var total float32
+		weights := make([]float32, 0, end-start+1)
+		for j := start; j <= end; j++ {
+			w := float32(1 - math.Abs(float64(j)-center)/support)
+			if w < 0 {
+				w = 0
+			}
+			weights = append(weights, w)
+			total += w
+		}
+
+		if total > 0 {
+			for j := range weights {
+				weights[j] /= total
+			}
+		}
+
+		contribs[i] = contribution{start: start, weights: weights}
+	}
+
+	return contribs
+}
+
+func clampIndex(i, size int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= size {
+		return size - 1
+	}
+	return i
+}
+
+func clampByte(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// Resize scales img to width keeping its aspect ratio. Images already
+// narrower than width are returned as they are.
+func Resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if width >= sw || sw == 0 || sh == 0 {
+		return img
+	}
+	height := max(1, int(math.Round(float64(sh)*float64(width)/float64(sw))))
+
+	return resample(img, width, height)
+}
+
+// resample scales img to width x height, up or down
+func resample(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	// Horizontal pass into a float buffer, sw x sh -> width x sh
+	tmp := make([]float32, width*sh*4)
+	hContribs := contributions(sw, width)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, c := range hContribs {
+			var r, g, bl, a float32
+			for k, w := range c.weights {
+				si := clampIndex(c.start+k, sw) * 4
+				r += w * float32(row[si])
+				g += w * float32(row[si+1])
+				bl += w * float32(row[si+2])
+				a += w * float32(row[si+3])
+			}
+			ti := (y*width + x) * 4
+			tmp[ti], tmp[ti+1], tmp[ti+2], tmp[ti+3] = r, g, bl, a
+		}
+	}
+
+	// Vertical pass, width x sh -> width x height
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	vContribs := contributions(sh, height)
+	for y, c := range vContribs {
+		for x := 0; x < width; x++ {
+			var r, g, bl, a float32
+			for k, w := range c.weights {
+				ti := (clampIndex(c.start+k, sh)*width + x) * 4
+				r += w * tmp[ti]
+				g += w * tmp[ti+1]
+				bl += w * tmp[ti+2]
+				a += w * tmp[ti+3]
+			}
+			di := dst.PixOffset(x, y)
+			dst.Pix[di] = clampByte(r)
+			dst.Pix[di+1] = clampByte(g)
+			dst.Pix[di+2] = clampByte(bl)
+			dst.Pix[di+3] = clampByte(a)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,233 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatPNG  = "png"
+	FormatHEIC = "heic"
+
+	// MaxFileSize is the largest upload accepted per picture
+	MaxFileSize = 20 << 20
+	// MaxPixels guards against decompression bombs, small files that
+	// decode into huge bitmaps. 50MP covers any phone camera.
+	MaxPixels = 50_000_000
+	// MaxDimension is the longest side accepted
+	MaxDimension = 12_000
+
+	// The stored original is re-encoded at this quality, which removes
+	// every metadata segment (EXIF, GPS, XMP, etc.)
+	OriginalJPEGQuality = 90
+
+	// ToolTimeout is the longest the conversion and encoding tools may
+	// run on one image
+	ToolTimeout = time.Minute
+)
+
+var (
+	ErrFileTooLarge    = fmt.Errorf("image is larger than %d MB", MaxFileSize>>20)
+	ErrTooManyPixels   = fmt.Errorf("image is larger than %d megapixels or %dpx per side", MaxPixels/1_000_000, MaxDimension)
+	ErrDecoderNotFound = errors.New("no decoder installed for the image format")
+	ErrEmptyImage      = errors.New("image is empty")
+)
+
+// heifBrands are the ftyp brands of HEIC/HEIF files
+var heifBrands = []string{"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"}
+
+// Sniff returns the format of the image by its content, the extension of
+// the uploaded file is never trusted. Only JPEG, PNG, WebP and HEIC are
+// accepted.
+func Sniff(data []byte) (string, error) {
+	switch {
+	case len(data) == 0:
+		return "", ErrEmptyImage
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, nil
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		brand := string(data[8:12])
+		for _, b := range heifBrands {
+			if brand == b {
+				return FormatHEIC, nil
+			}
+		}
+	}
+
+	return "", ErrUnsupportedImage
+}
+
+// Extension returns the file extension used to store an original of format
+func Extension(format string) string {
+	if format == FormatPNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// checkDimensions rejects images whose header declares too many pixels,
+// before the bitmap is allocated
+func checkDimensions(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return ErrUnsupportedImage
+	}
+	if width > MaxDimension || height > MaxDimension || width*height > MaxPixels {
+		return ErrTooManyPixels
+	}
+	return nil
+}
+
+// Upload is a validated picture, decoded and auto-oriented
+type Upload struct {
+	Image  image.Image
+	Format string
+}
+
+// CheckUpload validates an uploaded picture by its header only: its size,
+// its format sniffed from the content and its dimensions. Nothing is
+// decoded, so every picture of a form can be checked before any of them
+// takes the memory of its bitmap. It returns the sniffed format.
+func CheckUpload(data []byte) (string, error) {
+	if len(data) > MaxFileSize {
+		return "", ErrFileTooLarge
+	}
+
+	format, err := Sniff(data)
+	if err != nil {
+		return "", err
+	}
+
+	width, height, err := headerSize(format, data)
+	if err != nil {
+		return "", err
+	}
+	if err = checkDimensions(width, height); err != nil {
+		return "", err
+	}
+
+	return format, nil
+}
+
+// ReadUpload validates an uploaded picture like CheckUpload and decodes
+// it. JPEG and PNG are decoded natively, WebP and HEIC need the dwebp and
+// heif-convert tools installed.
+func ReadUpload(data []byte) (*Upload, error) {
+	// The size is checked on the header, before anything is decoded or
+	// handed to the conversion tools
+	format, err := CheckUpload(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatWebP || format == FormatHEIC {
+		data, err = convertToPNG(format, data)
+		if err != nil {
+			return nil, err
+		}
+		// The converted image must be the one the header declared
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedImage
+		}
+		if err = checkDimensions(cfg.Width, cfg.Height); err != nil {
+			return nil, err
+		}
+	}
+
+	img, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// WebP and HEIC are stored as JPEG, the converted PNG would be huge
+	if format != FormatPNG {
+		format = FormatJPEG
+	}
+
+	return &Upload{Image: img, Format: format}, nil
+}
+
+// EncodeOriginal encodes the upload without any of its metadata, this is
+// the version kept as the original so GPS coordinates and camera data
+// never reach the storage
+func (u *Upload) EncodeOriginal() ([]byte, error) {
+	return EncodeOriginal(u.Image, u.Format)
+}
+
+// EncodeOriginal encodes a full size picture as PNG or JPEG
+func EncodeOriginal(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == FormatPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: OriginalJPEGQuality})
+	}
+
+	return buf.Bytes(), err
+}
+
+// FormatOf returns the format of a stored original by its file name
+func FormatOf(name string) string {
+	if strings.EqualFold(filepath.Ext(name), ".png") {
+		return FormatPNG
+	}
+	return FormatJPEG
+}
+
+// convertToPNG decodes the formats without a Go decoder with their
+// reference tools, dwebp (libwebp) and heif-convert (libheif)
+func convertToPNG(format string, data []byte) ([]byte, error) {
+	tool, ext := "dwebp", ".webp"
+	if format == FormatHEIC {
+		tool, ext = "heif-convert", ".heic"
+	}
+
+	toolPath, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s requires %s", ErrDecoderNotFound, format, tool)
+	}
+
+	dir, err := os.MkdirTemp("", "sibra-convert-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in"+ext)
+	out := filepath.Join(dir, "out.png")
+	if err = os.WriteFile(in, data, 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ToolTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	var cmd *exec.Cmd
+	if format == FormatWebP {
+		cmd = exec.CommandContext(ctx, toolPath, "-quiet", in, "-o", out)
+	} else {
+		cmd = exec.CommandContext(ctx, toolPath, in, out)
+	}
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", tool, ErrUnsupportedImage, stderr.String())
+	}
+
+	return os.ReadFile(out)
+}
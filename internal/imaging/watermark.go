@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	EnvVarWatermarkLogo     = "WATERMARK_LOGO"
+	EnvVarWatermarkPosition = "WATERMARK_POSITION"
+	EnvVarWatermarkOpacity  = "WATERMARK_OPACITY"
+	EnvVarWatermarkScale    = "WATERMARK_SCALE"
+
+	DefaultWatermarkLogo    = "web/static/img/sibra_logo_white_256.webp"
+	DefaultWatermarkOpacity = 0.6
+	// Width of the logo relative to the width of the picture
+	DefaultWatermarkScale = 0.18
+
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+// Distance of the logo from the edges, relative to the shorter side
+const watermarkMargin = 0.03
+
+// Watermark composites a logo over the listing photos
+type Watermark struct {
+	Logo     image.Image
+	Position string
+	Opacity  float64
+	Scale    float64
+}
+
+var defaultWatermark struct {
+	once sync.Once
+	wm   *Watermark
+	err  error
+}
+
+// DefaultWatermark returns the watermark configured by the WATERMARK_* env
+// variables, loaded the first time it is used
+func DefaultWatermark() (*Watermark, error) {
+	defaultWatermark.once.Do(func() {
+		defaultWatermark.wm, defaultWatermark.err = WatermarkFromEnv()
+	})
+
+	return defaultWatermark.wm, defaultWatermark.err
+}
+
+func WatermarkFromEnv() (*Watermark, error) {
+	wm := &Watermark{
+		Position: PositionBottomRight,
+		Opacity:  DefaultWatermarkOpacity,
+		Scale:    DefaultWatermarkScale,
+	}
+
+	logoPath := os.Getenv(EnvVarWatermarkLogo)
+	if logoPath == "" {
+		logoPath = DefaultWatermarkLogo
+	}
+	logo, err := LoadLogo(logoPath)
+	if err != nil {
+		return nil, fmt.Errorf("load watermark logo: %w", err)
+	}
+	wm.Logo = logo
+
+	if position := os.Getenv(EnvVarWatermarkPosition); position != "" {
+		switch position {
+		case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+			wm.Position = position
+		default:
+			return nil, fmt.Errorf("invalid %s %q", EnvVarWatermarkPosition, position)
+		}
+	}
+	if opacity := os.Getenv(EnvVarWatermarkOpacity); opacity != "" {
+		wm.Opacity, err = strconv.ParseFloat(opacity, 64)
+		if err != nil || wm.Opacity <= 0 || wm.Opacity > 1 {
+			return nil, fmt.Errorf("invalid %s %q, expected a number in (0, 1]", EnvVarWatermarkOpacity, opacity)
+		}
+	}
+	if scale := os.Getenv(EnvVarWatermarkScale); scale != "" {
+		wm.Scale, err = strconv.ParseFloat(scale, 64)
+		if err != nil || wm.Scale <= 0 || wm.Scale > 1 {
+			return nil, fmt.Errorf("invalid %s %q, expected a number in (0, 1]", EnvVarWatermarkScale, scale)
+		}
+	}
+
+	return wm, nil
+}
+
+// LoadLogo decodes a PNG, JPEG or WebP logo, keeping its transparency
+func LoadLogo(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatWebP || format == FormatHEIC {
+		data, err = convertToPNG(format, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return Decode(data)
+}
+
+// Apply returns a copy of img with the logo composited over it
+func (wm *Watermark) Apply(img image.Image) image.Image {
+	b := img.Bounds()
+	lb := wm.Logo.Bounds()
+	if b.Empty() || lb.Empty() {
+		return img
+	}
+
+	width := max(1, int(math.Round(float64(b.Dx())*wm.Scale)))
+	height := max(1, int(math.Round(float64(lb.Dy())*float64(width)/float64(lb.Dx()))))
+	logo := resample(wm.Logo, width, height)
+
+	margin := int(math.Round(float64(min(b.Dx(), b.Dy())) * watermarkMargin))
+	var at image.Point
+	switch wm.Position {
+	case PositionTopLeft:
+		at = image.Pt(b.Min.X+margin, b.Min.Y+margin)
+	case PositionTopRight:
+		at = image.Pt(b.Max.X-margin-width, b.Min.Y+margin)
+	case PositionBottomLeft:
+		at = image.Pt(b.Min.X+margin, b.Max.Y-margin-height)
+	case PositionCenter:
+		at = image.Pt(b.Min.X+(b.Dx()-width)/2, b.Min.Y+(b.Dy()-height)/2)
+	default:
+		at = image.Pt(b.Max.X-margin-width, b.Max.Y-margin-height)
+	}
+
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(wm.Opacity * 255))})
+	draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(image.Pt(width, height))}, logo, image.Point{}, mask, image.Point{}, draw.Over)
+
+	return dst
+}
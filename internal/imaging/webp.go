@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// The standard library has no WebP encoder, so the variants are encoded by
+// the cwebp tool (libwebp) when it is installed
+var (
+	cwebpPath string
+	cwebpOnce sync.Once
+)
+
+// WebPAvailable reports whether the cwebp tool can be found in PATH or in
+// the CWEBP_PATH env variable
+func WebPAvailable() bool {
+	cwebpOnce.Do(func() {
+		if p := os.Getenv("CWEBP_PATH"); p != "" {
+			cwebpPath = p
+			return
+		}
+		cwebpPath, _ = exec.LookPath("cwebp")
+	})
+
+	return cwebpPath != ""
+}
+
+func encodeWebP(img image.Image) ([]byte, error) {
+	if !WebPAvailable() {
+		return nil, fmt.Errorf("cwebp not found")
+	}
+
+	// cwebp works with files, a lossless PNG as input avoids compressing
+	// the picture twice
+	dir, err := os.MkdirTemp("", "sibra-webp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out.webp")
+
+	file, err := os.Create(in)
+	if err != nil {
+		return nil, err
+	}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	err = enc.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ToolTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cwebpPath, "-quiet", "-q", strconv.Itoa(WebPQuality), in, "-o", out)
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cwebp: %w: %s", err, stderr.String())
+	}
+
+	return os.ReadFile(out)
+}
@@ -0,0 +1,48 @@
+// Package jobs runs the periodic background tasks of the server
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type JobFunc func(ctx context.Context) error
+
+// Every runs fn right away and then once every interval until ctx is
+// cancelled. Errors are logged and do not stop the job.
+//
+// Must be called on its own goroutine.
+func Every(ctx context.Context, name string, interval time.Duration, fn JobFunc) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx, name, interval, fn)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func run(ctx context.Context, name string, timeout time.Duration, fn JobFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job %s panicked: %v\n", name, r)
+		}
+	}()
+
+	start := time.Now()
+	if err := fn(ctx); err != nil {
+		log.Printf("job %s failed: %v\n", name, err)
+		return
+	}
+
+	log.Printf("job %s finished in %v\n", name, time.Since(start).Round(time.Millisecond))
+}
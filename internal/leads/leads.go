@@ -0,0 +1,197 @@
+// Package leads assigns the requests sent from the site to the agents
+package leads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/notify"
+	"github.com/vladwithcode/sibra-site/internal/sla"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+const (
+	// Business time an agent has to attend a lead before it goes to another
+	// agent, as a duration (e.g. 90m). 0 disables the reassignment.
+	EnvVarReassignAfter = "LEAD_REASSIGN_AFTER"
+	// How many leads a golden boy gets for each lead of the other agents
+	EnvVarGoldenBoyWeight = "LEAD_GOLDEN_BOY_WEIGHT"
+
+	DefaultReassignAfter   = 2 * time.Hour
+	DefaultGoldenBoyWeight = 2.0
+
+	// ReassignInterval is how often the unanswered leads are looked for
+	ReassignInterval = 10 * time.Minute
+)
+
+var (
+	ErrNoAgents = errors.New("no active agents to assign the lead to")
+)
+
+// ReassignAfter returns the configured reassignment window, 0 when it is
+// disabled
+func ReassignAfter() time.Duration {
+	value := os.Getenv(EnvVarReassignAfter)
+	if value == "" {
+		return DefaultReassignAfter
+	}
+
+	after, err := time.ParseDuration(value)
+	if err != nil || after < 0 {
+		log.Printf("invalid %s %q, using %v\n", EnvVarReassignAfter, value, DefaultReassignAfter)
+		return DefaultReassignAfter
+	}
+
+	return after
+}
+
+func GoldenBoyWeight() float64 {
+	value := os.Getenv(EnvVarGoldenBoyWeight)
+	if value == "" {
+		return DefaultGoldenBoyWeight
+	}
+
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight <= 0 {
+		log.Printf("invalid %s %q, using %v\n", EnvVarGoldenBoyWeight, value, DefaultGoldenBoyWeight)
+		return DefaultGoldenBoyWeight
+	}
+
+	return weight
+}
+
+// Route picks the agent of a new request and sets it on req, before the
+// request is created. Leads for a property go to the agent of the
+// property while they are active, the rest go to the next editor in turn.
+func Route(ctx context.Context, req *db.Request) (*db.User, error) {
+	if req.Property != "" {
+		agent, err := PropertyAgent(ctx, req.Property)
+		if err != nil {
+			fmt.Printf("Find property agent err: %v\n", err)
+		}
+		if agent != nil {
+			Assign(req, agent)
+			return agent, nil
+		}
+	}
+
+	agent, err := db.FindNextAgent(ctx, req.TriedAgents, GoldenBoyWeight(), time.Time{}, 0)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoAgents
+		}
+		return nil, err
+	}
+
+	Assign(req, agent)
+	return agent, nil
+}
+
+// PropertyAgent returns the agent of the property, nil when the agent is
+// not active
+func PropertyAgent(ctx context.Context, propertyId string) (*db.User, error) {
+	property, err := db.FindPropertyById(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := db.GetUserById(property.Agent)
+	if err != nil || !agent.Active {
+		return nil, err
+	}
+
+	return agent, nil
+}
+
+// Assign sets the agent of a request that has not been created yet
+func Assign(req *db.Request, agent *db.User) {
+	req.Agent = agent.Id
+	req.AssignedAt = time.Now()
+	req.TriedAgents = append(req.TriedAgents, agent.Id)
+}
+
+// Reassign gives the pending requests whose agent has had them for after
+// of business time to an agent that has not had them yet and notifies the
+// new agent, the requests that could not be assigned when they were
+// created are given right away. The requests every agent has had stay
+// with their last agent, visits only go to agents with the slot open. The
+// requests attended or reassigned by another instance in the meantime are
+// left as they are.
+func Reassign(ctx context.Context, after time.Duration, now time.Time) (int, error) {
+	// The business time since the assignment is never longer than the
+	// wall time, the newer requests can't be due yet
+	requests, err := db.FindUnansweredRequests(ctx, now.Add(-after))
+	if err != nil {
+		return 0, err
+	}
+
+	cal := sla.NewCalendar()
+	weight := GoldenBoyWeight()
+	reassigned := 0
+	var errs []error
+	for _, req := range requests {
+		if !req.AssignedAt.IsZero() && cal.Add(req.AssignedAt, after).After(now) {
+			continue
+		}
+
+		agent, err := nextAgent(ctx, req, weight)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				errs = append(errs, fmt.Errorf("find agent for %s: %w", req.Id, err))
+			}
+			continue
+		}
+
+		err = db.AssignRequest(ctx, req, agent.Id)
+		if errors.Is(err, db.ErrRequestChanged) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("assign %s: %w", req.Id, err))
+			continue
+		}
+		reassigned++
+
+		_, err = notify.Send(ctx, &notify.Notification{Event: notify.EventNewLead, Request: req, User: agent})
+		if err != nil {
+			log.Printf("Could not notify agent %s of request %s: %v\n", agent.Id, req.Id, err)
+		}
+	}
+
+	return reassigned, errors.Join(errs...)
+}
+
+// nextAgent returns the next agent in turn that has not had the request,
+// for visits the next one that has the slot in their availability and
+// is free at that time
+func nextAgent(ctx context.Context, req *db.Request, weight float64) (*db.User, error) {
+	if req.Type != db.RequestTypeQuote {
+		return db.FindNextAgent(ctx, req.TriedAgents, weight, time.Time{}, 0)
+	}
+
+	exclude := slices.Clone(req.TriedAgents)
+	for {
+		agent, err := db.FindNextAgent(ctx, exclude, weight, req.ScheduledDate, visits.SlotDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		err = visits.CheckAgentSlot(ctx, agent.Id, req.ScheduledDate)
+		if err == nil {
+			return agent, nil
+		}
+		if !errors.Is(err, visits.ErrSlotNotOpen) {
+			return nil, err
+		}
+
+		exclude = append(exclude, agent.Id)
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/templates/emails"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+// The clients are not users, they only get emails and only when they left
+// their email in the request and the SMTP server is set
+
+// SendReceipt lets the client know their request was received
+func SendReceipt(ctx context.Context, req *db.Request) error {
+	if req.Email == "" || !email.Enabled() {
+		return nil
+	}
+
+	req = withDetails(ctx, req)
+	msg := &email.Message{
+		To:      []string{req.Email},
+		Subject: "Recibimos tu solicitud | Sibra Durango",
+	}
+	err := msg.Render(ctx, emails.RequestReceived(req), emails.RequestReceivedText(req))
+	if err != nil {
+		return err
+	}
+
+	return email.Send(ctx, msg)
+}
+
+// SendClientInvite lets the client know their visit was confirmed, with
+// the invite to the visit attached
+func SendClientInvite(ctx context.Context, req *db.Request) error {
+	if req.Email == "" || !email.Enabled() {
+		return nil
+	}
+
+	req = withDetails(ctx, req)
+	invite, err := inviteAttachment(visits.ClientCalendar(req))
+	if err != nil {
+		return err
+	}
+
+	msg := &email.Message{
+		To:          []string{req.Email},
+		Subject:     "Tu visita está confirmada | Sibra Durango",
+		Attachments: []email.Attachment{invite},
+	}
+	if agent, err := db.GetUserById(req.Agent); err == nil {
+		msg.ReplyTo = agent.Email
+	}
+	err = msg.Render(ctx, emails.ClientVisitConfirmed(req), emails.ClientVisitConfirmedText(req))
+	if err != nil {
+		return err
+	}
+
+	return email.Send(ctx, msg)
+}
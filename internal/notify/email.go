@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/a-h/templ"
+	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/ics"
+	"github.com/vladwithcode/sibra-site/internal/templates/emails"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+// Email sends the notifications to the email of the user
+type Email struct{}
+
+func (Email) Notify(ctx context.Context, n *Notification) error {
+	to := os.Getenv(email.EnvVarNotificationAddress)
+	if n.User != nil {
+		to = n.User.Email
+	}
+	if to == "" {
+		return ErrEmailNotSet
+	}
+
+	req := withDetails(ctx, n.Request)
+	link := requestLink(req)
+	msg := &email.Message{
+		To:      []string{to},
+		ReplyTo: req.Email,
+	}
+
+	var html, text templ.Component
+	switch n.Event {
+	case EventNewLead:
+		msg.Subject = fmt.Sprintf("Nueva solicitud de %s", req.Name)
+		html, text = emails.NewLead(req, link), emails.NewLeadText(req, link)
+	case EventVisitConfirmed:
+		if n.User == nil {
+			return ErrNoRecipient
+		}
+		invite, err := inviteAttachment(visits.Calendar(n.User, []*db.Request{req}))
+		if err != nil {
+			return err
+		}
+		msg.Subject = fmt.Sprintf("Visita confirmada con %s", req.Name)
+		msg.Attachments = []email.Attachment{invite}
+		html, text = emails.VisitInvite(req, link), emails.VisitInviteText(req, link)
+	case EventSLABreach:
+		if n.Escalated {
+			msg.Subject = fmt.Sprintf("Solicitud de %s sin atender", req.Name)
+			html, text = emails.LeadEscalation(req, link), emails.LeadEscalationText(req, link)
+		} else {
+			msg.Subject = fmt.Sprintf("Recordatorio: solicitud de %s", req.Name)
+			html, text = emails.LeadReminder(req, link), emails.LeadReminderText(req, link)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidEvent, n.Event)
+	}
+
+	if err := msg.Render(ctx, html, text); err != nil {
+		return err
+	}
+
+	return email.Send(ctx, msg)
+}
+
+// requestLink is the link to the request in the admin
+func requestLink(req *db.Request) string {
+	return internal.SiteURL("/admin/solicitudes/" + req.Id)
+}
+
+// withDetails reads the request again for the address of its property and
+// the name of its agent, the ones created in the request form don't have
+// them
+func withDetails(ctx context.Context, req *db.Request) *db.Request {
+	full, err := db.FindRequestById(ctx, req.Id)
+	if err != nil {
+		fmt.Printf("Find request err: %v\n", err)
+		return req
+	}
+	return full
+}
+
+// inviteAttachment is the calendar file of a visit
+func inviteAttachment(cal *ics.Calendar) (email.Attachment, error) {
+	var buf bytes.Buffer
+	if _, err := cal.WriteTo(&buf); err != nil {
+		return email.Attachment{}, err
+	}
+
+	return email.Attachment{
+		Filename:    "visita.ics",
+		ContentType: ics.ContentType + "; method=" + ics.MethodPublish,
+		Data:        buf.Bytes(),
+	}, nil
+}
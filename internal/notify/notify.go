@@ -0,0 +1,244 @@
+// Package notify lets the users know of the events of the requests by the
+// channels each of them chose in their profile, and the clients by email.
+//
+// The events and channels are listed here. A new event is added to Events
+// and handled by each notifier, a new channel is added to Channels with
+// its notifier in channelNotifier.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/wsp"
+)
+
+type Event string
+
+const (
+	// A request was assigned to the user
+	EventNewLead Event = "nueva_solicitud"
+	// A visit of the user was confirmed
+	EventVisitConfirmed Event = "visita_confirmada"
+	// A request of the user is pending past its SLA, or any request for
+	// the admins when it is escalated
+	EventSLABreach Event = "solicitud_sin_atender"
+)
+
+// Events are the events in the order they are listed in the profile
+var Events = []Event{EventNewLead, EventVisitConfirmed, EventSLABreach}
+
+var eventLabels = map[Event]string{
+	EventNewLead:        "Nueva solicitud",
+	EventVisitConfirmed: "Visita confirmada",
+	EventSLABreach:      "Solicitud sin atender",
+}
+
+type Channel string
+
+const (
+	ChannelWhatsApp Channel = "whatsapp"
+	ChannelEmail    Channel = "correo"
+)
+
+// Channels are the channels in the order they are listed in the profile
+var Channels = []Channel{ChannelWhatsApp, ChannelEmail}
+
+var channelLabels = map[Channel]string{
+	ChannelWhatsApp: "WhatsApp",
+	ChannelEmail:    "Correo",
+}
+
+var (
+	ErrInvalidEvent   = errors.New("invalid notification event")
+	ErrInvalidChannel = errors.New("invalid notification channel")
+	ErrNoRecipient    = errors.New("the notification has no one to be sent to")
+	ErrSiteURLNotSet  = errors.New("the site url is not set, the invite can't be linked")
+	ErrEmailNotSet    = errors.New("the user has no email and the notification address is not set")
+)
+
+func ParseEvent(s string) (Event, error) {
+	event := Event(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := eventLabels[event]; !ok {
+		return "", ErrInvalidEvent
+	}
+
+	return event, nil
+}
+
+func (e Event) Label() string {
+	return eventLabels[e]
+}
+
+func ParseChannel(s string) (Channel, error) {
+	channel := Channel(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := channelLabels[channel]; !ok {
+		return "", ErrInvalidChannel
+	}
+
+	return channel, nil
+}
+
+func (c Channel) Label() string {
+	return channelLabels[c]
+}
+
+// Notification is an event of a request for one user
+type Notification struct {
+	Event   Event
+	Request *db.Request
+	// User to notify, nil sends the notification to the notification
+	// phone and address
+	User *db.User
+	// For EventSLABreach, the user is an admin told the request is still
+	// pending instead of its agent being reminded of it
+	Escalated bool
+}
+
+// Notifier delivers the notifications by a channel
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Log writes the notifications to the log instead of sending them, for
+// the channels that are not configured
+type Log struct {
+	Channel Channel
+}
+
+func (l Log) Notify(ctx context.Context, n *Notification) error {
+	to := "notification contact"
+	if n.User != nil {
+		to = n.User.Username
+	}
+	log.Printf("[%s not configured] %s of request %s for %s\n", l.Channel, n.Event, n.Request.Id, to)
+	return nil
+}
+
+// Nop drops the notifications
+type Nop struct{}
+
+func (Nop) Notify(ctx context.Context, n *Notification) error {
+	return nil
+}
+
+// channelNotifier returns the notifier of the channel and whether the
+// channel is configured, the ones that are not log their notifications
+func channelNotifier(c Channel) (Notifier, bool) {
+	var configured bool
+	var notifier Notifier
+	switch c {
+	case ChannelWhatsApp:
+		configured, notifier = wsp.Enabled(), WhatsApp{}
+	case ChannelEmail:
+		configured, notifier = email.Enabled(), Email{}
+	default:
+		return Nop{}, false
+	}
+
+	if !configured {
+		return Log{Channel: c}, false
+	}
+	return notifier, true
+}
+
+// Send delivers the notification by each channel the user chose for the
+// event, the notifications without a user go by every channel. It returns
+// the channels the notification was sent by, the failed and not
+// configured ones are left out.
+func Send(ctx context.Context, n *Notification) ([]Channel, error) {
+	prefs := DefaultPreferences()
+	if n.User != nil {
+		userPrefs, err := FindPreferences(ctx, n.User.Id)
+		if err != nil {
+			log.Printf("Could not find the notification preferences of %s, using the defaults: %v\n", n.User.Id, err)
+		} else {
+			prefs = userPrefs
+		}
+	}
+
+	sent := []Channel{}
+	var errs []error
+	for _, c := range prefs[n.Event] {
+		notifier, configured := channelNotifier(c)
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c, err))
+			continue
+		}
+		if configured {
+			sent = append(sent, c)
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// Preferences are the channels a user gets each event by
+type Preferences map[Event][]Channel
+
+// DefaultPreferences are the preferences of the users that have not chosen
+// theirs, every event by every channel
+func DefaultPreferences() Preferences {
+	prefs := Preferences{}
+	for _, e := range Events {
+		prefs[e] = slices.Clone(Channels)
+	}
+	return prefs
+}
+
+// Has tells if the event is sent by the channel
+func (p Preferences) Has(e Event, c Channel) bool {
+	return slices.Contains(p[e], c)
+}
+
+// FindPreferences returns the preferences of the user, the defaults for
+// the events the user has not chosen the channels of
+func FindPreferences(ctx context.Context, userId string) (Preferences, error) {
+	rows, err := db.FindNotificationPreferences(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := DefaultPreferences()
+	for _, row := range rows {
+		event, err := ParseEvent(row.Event)
+		if err != nil {
+			continue
+		}
+
+		channels := []Channel{}
+		for _, value := range row.Channels {
+			if c, err := ParseChannel(value); err == nil {
+				channels = append(channels, c)
+			}
+		}
+		prefs[event] = channels
+	}
+
+	return prefs, nil
+}
+
+// SavePreferences sets the channels of the events of prefs for the user
+func SavePreferences(ctx context.Context, userId string, prefs Preferences) error {
+	rows := make([]*db.NotificationPreference, 0, len(prefs))
+	for _, e := range Events {
+		channels, ok := prefs[e]
+		if !ok {
+			continue
+		}
+
+		row := &db.NotificationPreference{Event: string(e), Channels: []string{}}
+		for _, c := range channels {
+			row.Channels = append(row.Channels, string(c))
+		}
+		rows = append(rows, row)
+	}
+
+	return db.UpdateNotificationPreferences(ctx, userId, rows)
+}
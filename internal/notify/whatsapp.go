@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+	"github.com/vladwithcode/sibra-site/internal/wsp"
+)
+
+// WhatsApp sends the notifications with the message templates approved
+// for each event
+type WhatsApp struct{}
+
+func (WhatsApp) Notify(ctx context.Context, n *Notification) error {
+	switch n.Event {
+	case EventNewLead:
+		return sendLeadMessage(n)
+	case EventVisitConfirmed:
+		return sendInviteMessage(ctx, n)
+	case EventSLABreach:
+		if n.Escalated {
+			return sendEscalationMessage(n)
+		}
+		return sendReminderMessage(n)
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidEvent, n.Event)
+}
+
+// userPhone is the phone of the user, or the notification phone when the
+// notification has no user
+func userPhone(user *db.User) string {
+	if user == nil {
+		return os.Getenv(wsp.EnvVarNotificationPhone)
+	}
+	if !user.Phone.Valid {
+		return ""
+	}
+	return user.Phone.String
+}
+
+// sendLeadMessage sends the lead to the phone of the agent, or to the
+// notification phone when the lead has no agent or the agent has no
+// phone, so no lead goes unnoticed
+func sendLeadMessage(n *Notification) error {
+	phone := userPhone(n.User)
+	if phone == "" {
+		phone = os.Getenv(wsp.EnvVarNotificationPhone)
+	}
+	if phone == "" {
+		return wsp.ErrPhoneNotSet
+	}
+
+	req := n.Request
+	scheduledDate := req.ScheduledDate
+	if scheduledDate.IsZero() {
+		scheduledDate = req.CreatedAt
+	}
+
+	return wsp.SendTemplateMessage(phone, wsp.TemplateData{
+		TemplateName: "info_request",
+		BodyVars: []wsp.TemplateVar{
+			{
+				"type": "text",
+				"text": req.Name,
+			},
+			{
+				"type": "text",
+				"text": scheduledDate.In(visits.Location).Format("02/01/2006 15:04"),
+			},
+			{
+				"type": "text",
+				"text": req.Phone,
+			},
+		},
+	})
+}
+
+// sendInviteMessage sends the agent of a confirmed visit the invite to add
+// it to their calendar
+func sendInviteMessage(ctx context.Context, n *Notification) error {
+	if n.User == nil {
+		return ErrNoRecipient
+	}
+	if !strings.HasPrefix(internal.SiteURL("/"), "http") {
+		return ErrSiteURLNotSet
+	}
+
+	phone := userPhone(n.User)
+	if phone == "" {
+		return wsp.ErrPhoneNotSet
+	}
+
+	token, err := db.GetCalendarToken(ctx, n.User.Id)
+	if err != nil {
+		return err
+	}
+
+	req := n.Request
+	return wsp.SendDocumentMessage(phone, wsp.DocumentData{
+		Link:     internal.SiteURL(visits.InvitePath(token, req.Id)),
+		Filename: "visita.ics",
+		Caption: fmt.Sprintf(
+			"Visita confirmada con %s el %s",
+			req.Name,
+			req.ScheduledDate.In(visits.Location).Format("02/01/2006 15:04"),
+		),
+	})
+}
+
+func sendReminderMessage(n *Notification) error {
+	phone := userPhone(n.User)
+	if phone == "" {
+		return wsp.ErrPhoneNotSet
+	}
+
+	req := n.Request
+	return wsp.SendTemplateMessage(phone, wsp.TemplateData{
+		TemplateName: "lead_reminder",
+		BodyVars: []wsp.TemplateVar{
+			{
+				"type": "text",
+				"text": req.Name,
+			},
+			{
+				"type": "text",
+				"text": req.Phone,
+			},
+			{
+				"type": "text",
+				"text": req.CreatedAt.In(visits.Location).Format("02/01/2006 15:04"),
+			},
+		},
+	})
+}
+
+// sendEscalationMessage tells the admin the request is still pending
+func sendEscalationMessage(n *Notification) error {
+	phone := userPhone(n.User)
+	if phone == "" {
+		return wsp.ErrPhoneNotSet
+	}
+
+	req := n.Request
+	agentName := req.AgentName
+	if agentName == "" {
+		agentName = "Sin asignar"
+	}
+
+	return wsp.SendTemplateMessage(phone, wsp.TemplateData{
+		TemplateName: "lead_escalation",
+		BodyVars: []wsp.TemplateVar{
+			{
+				"type": "text",
+				"text": req.Name,
+			},
+			{
+				"type": "text",
+				"text": agentName,
+			},
+			{
+				"type": "text",
+				"text": req.CreatedAt.In(visits.Location).Format("02/01/2006 15:04"),
+			},
+		},
+	})
+}
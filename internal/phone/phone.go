@@ -0,0 +1,189 @@
+// Package phone parses the phone numbers typed in the forms into their
+// E.164 form, the one the WhatsApp API expects, and the form shown to the
+// agents. Numbers without a country code are taken as mexican.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// CountryCodeMX is the country code the numbers without one are given
+const CountryCodeMX = "52"
+
+var (
+	ErrEmpty   = errors.New("phone number is empty")
+	ErrInvalid = errors.New("phone number is invalid")
+)
+
+// Number is a phone number split into its country code and national
+// number, both digits only
+type Number struct {
+	CountryCode string
+	National    string
+}
+
+// E164 returns the number as +<country code><national number>, the form
+// it is stored in
+func (n *Number) E164() string {
+	return "+" + n.CountryCode + n.National
+}
+
+// WhatsApp returns the number as the WhatsApp API expects it, E.164
+// without the plus sign
+func (n *Number) WhatsApp() string {
+	return n.CountryCode + n.National
+}
+
+// IsMX tells if the number is mexican
+func (n *Number) IsMX() bool {
+	return n.CountryCode == CountryCodeMX
+}
+
+// Display returns the number grouped for reading. The mexican numbers are
+// shown without their country code, e.g. 618 123 4567 or 55 1234 5678.
+func (n *Number) Display() string {
+	switch {
+	case n.IsMX():
+		return displayMX(n.National)
+	case n.CountryCode == "1" && len(n.National) == 10:
+		return "+1 " + n.National[:3] + " " + n.National[3:6] + " " + n.National[6:]
+	default:
+		return "+" + n.CountryCode + " " + n.National
+	}
+}
+
+// Areas of Mexico with two digit lada, the rest have three
+var twoDigitAreas = map[string]bool{
+	"33": true,
+	"55": true,
+	"56": true,
+	"81": true,
+}
+
+func displayMX(national string) string {
+	if twoDigitAreas[national[:2]] {
+		return national[:2] + " " + national[2:6] + " " + national[6:]
+	}
+	return national[:3] + " " + national[3:6] + " " + national[6:]
+}
+
+// Country codes of one and two digits, the rest have three (ITU-T E.164)
+var shortCountryCodes = map[string]bool{
+	"1": true, "7": true,
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true,
+	"34": true, "36": true, "39": true, "40": true, "41": true, "43": true,
+	"44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true,
+	"64": true, "65": true, "66": true, "81": true, "82": true, "84": true,
+	"86": true, "90": true, "91": true, "92": true, "93": true, "94": true,
+	"95": true, "98": true,
+}
+
+// Parse reads a phone number as typed, with or without spaces, dashes,
+// dots or parentheses. It accepts:
+//
+//   - mexican numbers of 10 digits, optionally after the old 01, 044 and
+//     045 prefixes
+//   - mexican numbers with their country code, including the legacy 1 of
+//     the mobiles (+52 1 618 123 4567 or 521 618 123 4567)
+//   - international numbers after a + or 00
+func Parse(s string) (*Number, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrEmpty
+	}
+
+	international := strings.HasPrefix(s, "+")
+	if international {
+		s = s[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return nil, ErrInvalid
+		}
+	}
+
+	d := digits.String()
+	if !international && strings.HasPrefix(d, "00") {
+		international = true
+		d = d[2:]
+	}
+
+	if international {
+		return parseInternational(d)
+	}
+
+	switch {
+	case len(d) == 13 && (strings.HasPrefix(d, "044") || strings.HasPrefix(d, "045")):
+		d = d[3:]
+	case len(d) == 12 && strings.HasPrefix(d, "01"):
+		d = d[2:]
+	case len(d) == 12 && strings.HasPrefix(d, CountryCodeMX),
+		len(d) == 13 && strings.HasPrefix(d, CountryCodeMX+"1"):
+		return parseInternational(d)
+	}
+
+	return parseMX(d)
+}
+
+func parseMX(national string) (*Number, error) {
+	// The national numbers never start with 0 or 1, those are prefixes
+	if len(national) != 10 || national[0] == '0' || national[0] == '1' {
+		return nil, ErrInvalid
+	}
+
+	return &Number{CountryCode: CountryCodeMX, National: national}, nil
+}
+
+func parseInternational(d string) (*Number, error) {
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return nil, ErrInvalid
+	}
+
+	if national, ok := strings.CutPrefix(d, CountryCodeMX); ok {
+		// The 1 after the country code was required to call the mobiles
+		// until 2020, it is no longer part of the number
+		if len(national) == 11 && national[0] == '1' {
+			national = national[1:]
+		}
+		return parseMX(national)
+	}
+
+	codeLen := 3
+	if shortCountryCodes[d[:1]] {
+		codeLen = 1
+	} else if shortCountryCodes[d[:2]] {
+		codeLen = 2
+	}
+
+	return &Number{CountryCode: d[:codeLen], National: d[codeLen:]}, nil
+}
+
+// Normalize returns the E.164 and display forms of the number
+func Normalize(s string) (e164, display string, err error) {
+	n, err := Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+
+	return n.E164(), n.Display(), nil
+}
+
+// Format returns the display form of the number, or the number as it is
+// when it can't be parsed
+func Format(s string) string {
+	n, err := Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return n.Display()
+}
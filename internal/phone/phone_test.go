@@ -0,0 +1,89 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in      string
+		e164    string
+		display string
+	}{
+		{"6181234567", "+526181234567", "618 123 4567"},
+		{"5512345678", "+525512345678", "55 1234 5678"},
+		{"33 1234 5678", "+523312345678", "33 1234 5678"},
+		{"618-123-4567", "+526181234567", "618 123 4567"},
+		{"(618) 123.45.67", "+526181234567", "618 123 4567"},
+		{"  618 123 4567  ", "+526181234567", "618 123 4567"},
+
+		// Old mobile and long distance prefixes
+		{"044 618 123 4567", "+526181234567", "618 123 4567"},
+		{"045 55 1234 5678", "+525512345678", "55 1234 5678"},
+		{"01 618 123 4567", "+526181234567", "618 123 4567"},
+
+		// Country code, with and without the legacy 1 of the mobiles
+		{"+52 618 123 4567", "+526181234567", "618 123 4567"},
+		{"+52 1 618 123 4567", "+526181234567", "618 123 4567"},
+		{"526181234567", "+526181234567", "618 123 4567"},
+		{"5216181234567", "+526181234567", "618 123 4567"},
+		{"0052 618 123 4567", "+526181234567", "618 123 4567"},
+
+		// International
+		{"+1 (915) 555-0123", "+19155550123", "+1 915 555 0123"},
+		{"001 915 555 0123", "+19155550123", "+1 915 555 0123"},
+		{"+34 612 345 678", "+34612345678", "+34 612345678"},
+		{"+502 2345 6789", "+50223456789", "+502 23456789"},
+	}
+
+	for _, tt := range tests {
+		e164, display, err := Normalize(tt.in)
+		if err != nil {
+			t.Errorf("Normalize(%q) err: %v", tt.in, err)
+			continue
+		}
+		if e164 != tt.e164 || display != tt.display {
+			t.Errorf("Normalize(%q) = %q, %q, want %q, %q", tt.in, e164, display, tt.e164, tt.display)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{"", ErrEmpty},
+		{"   ", ErrEmpty},
+		{"618123456", ErrInvalid},
+		{"61812345678", ErrInvalid},
+		{"0181234567", ErrInvalid},
+		{"1181234567", ErrInvalid},
+		{"044 618 123 456", ErrInvalid},
+		{"+52 618 123 456", ErrInvalid},
+		{"+52 1 618 123 45678", ErrInvalid},
+		{"+1234567", ErrInvalid},
+		{"+1234567890123456", ErrInvalid},
+		{"+0 618 123 4567", ErrInvalid},
+		{"618 123 4567 ext 2", ErrInvalid},
+		{"618/123/4567", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		n, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) = %+v, %v, want %v", tt.in, n, err, tt.err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format("6181234567"); got != "618 123 4567" {
+		t.Errorf("Format = %q", got)
+	}
+	// The numbers that can't be parsed are shown as they are
+	if got := Format("618-123"); got != "618-123" {
+		t.Errorf("Format = %q", got)
+	}
+}
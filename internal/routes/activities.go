@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func RegisterActivityRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/solicitudes/{id}", auth.WithAuthMiddleware(RenderAdminRequest))
+	router.HandleFunc("POST /api/requests/{id}/activities", auth.WithAuthMiddleware(CreateRequestActivity))
+	router.HandleFunc("POST /api/requests/{id}/tasks", auth.WithAuthMiddleware(CreateRequestTask))
+
+	router.HandleFunc("GET /admin/pendientes", auth.WithAuthMiddleware(RenderAgentTasks))
+	router.HandleFunc("PUT /api/tasks/{id}/done", auth.WithAuthMiddleware(UpdateTaskDone))
+	router.HandleFunc("DELETE /api/tasks/{id}", auth.WithAuthMiddleware(DeleteTask))
+}
+
+const (
+	// dueDateLayout is the format of the datetime-local inputs
+	dueDateLayout = "2006-01-02T15:04"
+	// tasksDoneFor is how long the done tasks stay in the pending list
+	tasksDoneFor = 24 * time.Hour
+)
+
+func RenderAdminRequest(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	req, err := db.FindRequestById(ctx, r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la solicitud"})
+		return
+	}
+
+	activities, err := db.FindRequestActivities(ctx, req.Id)
+	if err != nil {
+		fmt.Printf("Find request activities err: %v\n", err)
+		activities = []*db.Activity{}
+	}
+
+	tasks, err := db.FindRequestTasks(ctx, req.Id)
+	if err != nil {
+		fmt.Printf("Find request tasks err: %v\n", err)
+		tasks = []*db.Task{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminRequest(
+			req,
+			components.RequestActivities(req.Id, activities, ""),
+			components.RequestTasks(req.Id, tasks, time.Now(), ""),
+		),
+		a,
+		req.Name+" | Solicitudes | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func respondWithActivities(w http.ResponseWriter, r *http.Request, requestId, errMsg string, status int) {
+	activities, err := db.FindRequestActivities(r.Context(), requestId)
+	if err != nil {
+		fmt.Printf("Find request activities err: %v\n", err)
+		activities = []*db.Activity{}
+	}
+
+	w.WriteHeader(status)
+	err = components.RequestActivities(requestId, activities, errMsg).Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func CreateRequestActivity(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	requestId := r.PathValue("id")
+	if _, err := db.FindRequestById(r.Context(), requestId); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la solicitud"))
+		return
+	}
+
+	kind, err := db.ParseActivityKind(r.FormValue("kind"))
+	if err != nil {
+		respondWithActivities(w, r, requestId, "Seleccione el tipo de actividad", 400)
+		return
+	}
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		respondWithActivities(w, r, requestId, "Escriba la nota", 400)
+		return
+	}
+
+	err = db.CreateActivity(r.Context(), &db.Activity{
+		Request: requestId,
+		Author:  a.Id,
+		Kind:    kind,
+		Body:    body,
+	})
+	if err != nil {
+		fmt.Printf("Create activity err: %v\n", err)
+		respondWithActivities(w, r, requestId, "Ocurrió un error al registrar la actividad", 500)
+		return
+	}
+
+	respondWithActivities(w, r, requestId, "", 201)
+}
+
+func respondWithTasks(w http.ResponseWriter, r *http.Request, requestId, errMsg string, status int) {
+	tasks, err := db.FindRequestTasks(r.Context(), requestId)
+	if err != nil {
+		fmt.Printf("Find request tasks err: %v\n", err)
+		tasks = []*db.Task{}
+	}
+
+	w.WriteHeader(status)
+	err = components.RequestTasks(requestId, tasks, time.Now(), errMsg).Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// CreateRequestTask creates a task of the request for the user that
+// creates it
+func CreateRequestTask(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	requestId := r.PathValue("id")
+	if _, err := db.FindRequestById(r.Context(), requestId); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la solicitud"))
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		respondWithTasks(w, r, requestId, "Escriba la tarea", 400)
+		return
+	}
+	due, err := time.ParseInLocation(dueDateLayout, r.FormValue("due"), visits.Location)
+	if err != nil {
+		respondWithTasks(w, r, requestId, "La fecha no es válida", 400)
+		return
+	}
+
+	err = db.CreateTask(r.Context(), &db.Task{
+		Request: requestId,
+		Agent:   a.Id,
+		Title:   title,
+		DueDate: due,
+	})
+	if err != nil {
+		fmt.Printf("Create task err: %v\n", err)
+		respondWithTasks(w, r, requestId, "Ocurrió un error al crear la tarea", 500)
+		return
+	}
+
+	respondWithTasks(w, r, requestId, "", 201)
+}
+
+// RenderAgentTasks is the list of pending tasks of the user, admins can
+// see the tasks of the other agents
+func RenderAgentTasks(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	agentId := a.Id
+	if id := r.URL.Query().Get("agente"); id != "" && canManageAgent(a, id) {
+		agentId = id
+	}
+
+	agent, err := db.GetUserById(agentId)
+	if err != nil {
+		fmt.Printf("Find agent err: %v\n", err)
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el agente"})
+		return
+	}
+
+	var agents []*db.User
+	if a.Role == db.RoleAdmin {
+		agents, err = db.FindAgents(ctx)
+		if err != nil {
+			fmt.Printf("Find agents err: %v\n", err)
+		}
+	}
+
+	now := time.Now()
+	tasks, err := db.FindAgentTasks(ctx, agent.Id, now.Add(-tasksDoneFor))
+	if err != nil {
+		fmt.Printf("Find agent tasks err: %v\n", err)
+		tasks = []*db.Task{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminTasks(agent, agents, tasks, now),
+		a,
+		"Pendientes | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+// findOwnTask returns the task when it belongs to the user or the user
+// is an admin
+func findOwnTask(w http.ResponseWriter, r *http.Request, a *auth.Auth) *db.Task {
+	task, err := db.FindTaskById(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find task err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la tarea"))
+		return nil
+	}
+	if !canManageAgent(a, task.Agent) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar esta tarea"))
+		return nil
+	}
+
+	return task
+}
+
+func UpdateTaskDone(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	task := findOwnTask(w, r, a)
+	if task == nil {
+		return
+	}
+
+	err := db.SetTaskDone(r.Context(), task, r.FormValue("done") == "true")
+	if err != nil {
+		fmt.Printf("Update task err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrió un error al actualizar la tarea"))
+		return
+	}
+
+	withRequest := r.FormValue("withRequest") == "true"
+	err = components.TaskItem(task, time.Now(), withRequest).Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func DeleteTask(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	task := findOwnTask(w, r, a)
+	if task == nil {
+		return
+	}
+
+	err := db.DeleteTask(r.Context(), task.Id)
+	if err != nil {
+		fmt.Printf("Delete task err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrió un error al eliminar la tarea"))
+		return
+	}
+
+	w.WriteHeader(200)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/vladwithcode/sibra-site/internal"
 	"github.com/vladwithcode/sibra-site/internal/auth"
 	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/notify"
 	"github.com/vladwithcode/sibra-site/internal/templates/pages"
 )
 
@@ -24,25 +25,27 @@ func RegisterAdminRoutes(router *customServeMux) {
 	router.HandleFunc("GET /admin/propiedades/nueva", auth.WithAuthMiddleware(RenderNewProperty))
 	router.HandleFunc("GET /admin/propiedades/editar/{id}", auth.WithAuthMiddleware(RenderUpdateProperty))
 	router.HandleFunc("GET /admin/propiedades/eliminar/{id}", auth.WithAuthMiddleware(RenderDeleteProperty))
+	router.HandleFunc("GET /admin/duplicados", auth.WithAuthMiddleware(RenderDuplicates))
 
 	router.HandleFunc("GET /admin/mi-usuario", auth.WithAuthMiddleware(RenderUserProfile))
 }
 
 func RenderDashboard(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
-	templ, err := template.ParseFiles("web/templates/admin/layout.html", "web/templates/admin/dashboard.html")
-
+	ctx := r.Context()
+	saleTrends, err := db.FindMarketTrends(ctx, &db.MarketTrendFilter{Contract: "venta"})
 	if err != nil {
-		fmt.Printf("Parse err: %v\n", err)
-		respondWithError(w, 500, ErrorParams{})
-		return
+		fmt.Printf("Find sale trends err: %v\n", err)
+		saleTrends = []*db.MarketSnapshot{}
+	}
+	rentTrends, err := db.FindMarketTrends(ctx, &db.MarketTrendFilter{Contract: "renta"})
+	if err != nil {
+		fmt.Printf("Find rent trends err: %v\n", err)
+		rentTrends = []*db.MarketSnapshot{}
 	}
 
-	err = templ.Execute(w, map[string]any{
-		"User": a,
-	})
-
+	err = pages.AdminDashboard(a, saleTrends, rentTrends).Render(context.Background(), w)
 	if err != nil {
-		fmt.Printf("Execute err: %v\n", err)
+		fmt.Printf("Render err: %v\n", err)
 		respondWithError(w, 500, ErrorParams{})
 		return
 	}
@@ -124,13 +127,44 @@ func RenderUpdateProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth)
 		return
 	}
 
+	duplicates, err := db.FindDuplicateImages(ctx, property.Id, db.DuplicateMaxDistance)
+	if err != nil {
+		fmt.Printf("Find duplicates err: %v\n", err)
+	}
+
+	docs, err := db.FindPropertyDocuments(ctx, property.Id, false)
+	if err != nil {
+		fmt.Printf("Find documents err: %v\n", err)
+	}
+
 	pages.AdminLayout(
-		pages.EditProperty(property),
+		pages.EditProperty(property, duplicates, docs),
 		a,
 		"Editar Propiedad | Sibra Durango",
 	).Render(context.Background(), w)
 }
 
+// RenderDuplicates lists the photos that look the same in different
+// properties, listings published twice or with scraped photos
+func RenderDuplicates(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	if a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver esta página"})
+		return
+	}
+
+	matches, err := db.FindDuplicateImages(r.Context(), "", db.DuplicateMaxDistance)
+	if err != nil {
+		fmt.Printf("Find duplicates err: %v\n", err)
+		matches = []*db.ImageMatch{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminDuplicates(matches),
+		a,
+		"Posibles duplicados | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
 func RenderDeleteProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 	id := r.PathValue("id")
 	ctx := r.Context()
@@ -287,8 +321,17 @@ func RenderUserProfile(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
+	prefs, err := notify.FindPreferences(r.Context(), user.Id)
+	if err != nil {
+		fmt.Printf("Find notification preferences err: %v\n", err)
+		prefs = notify.DefaultPreferences()
+	}
+
 	err = templ.Execute(w, map[string]any{
-		"User": user,
+		"User":        user,
+		"Events":      notify.Events,
+		"Channels":    notify.Channels,
+		"Preferences": prefs,
 	})
 
 	if err != nil {
@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/ics"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func RegisterCalendarRoutes(router *customServeMux) {
+	// The token in the path is the only credential, calendar apps can't
+	// log in
+	router.HandleFunc("GET /calendario/{file}", ServeAgentCalendar)
+	router.HandleFunc("GET /calendario/{token}/{file}", ServeVisitInvite)
+
+	router.HandleFunc("GET /admin/solicitudes/{id}/invitacion", auth.WithAuthMiddleware(DownloadVisitInvite))
+	router.HandleFunc("POST /api/agents/{id}/calendar-token", auth.WithAuthMiddleware(ResetAgentCalendarToken))
+}
+
+// absoluteURL returns the URL of path in the site, from the host of the
+// request when SITE_URL is not set
+func absoluteURL(r *http.Request, path string) string {
+	url := internal.SiteURL(path)
+	if strings.HasPrefix(url, "http") {
+		return url
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + path
+}
+
+func serveCalendar(w http.ResponseWriter, cal *ics.Calendar, disposition string) {
+	w.Header().Set("Content-Type", ics.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", disposition)
+
+	_, err := cal.WriteTo(w)
+	if err != nil {
+		fmt.Printf("Write calendar err: %v\n", err)
+	}
+}
+
+// isCalendarVisit tells if the request is a visit listed in the calendar
+// of its agent
+func isCalendarVisit(req *db.Request) bool {
+	return req.Type == db.RequestTypeQuote &&
+		!req.ScheduledDate.IsZero() &&
+		slices.Contains(db.CalendarStatuses, req.Status)
+}
+
+// findCalendarAgent returns the agent of the token, the token is the
+// value of the wildcard without the .ics extension
+func findCalendarAgent(r *http.Request, token string) (*db.User, error) {
+	if token == "" {
+		return nil, pgx.ErrNoRows
+	}
+
+	return db.FindUserByCalendarToken(r.Context(), token)
+}
+
+// ServeAgentCalendar is the feed of the visits of an agent, calendar apps
+// subscribe to it
+func ServeAgentCalendar(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok {
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el calendario"})
+		return
+	}
+
+	agent, err := findCalendarAgent(r, token)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find calendar agent err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el calendario"})
+		return
+	}
+
+	requests, err := db.FindCalendarVisits(r.Context(), agent.Id, time.Now().Add(-visits.CalendarPast))
+	if err != nil {
+		fmt.Printf("Find calendar visits err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al obtener las visitas"))
+		return
+	}
+
+	serveCalendar(w, visits.Calendar(agent, requests), `inline; filename="visitas.ics"`)
+}
+
+// ServeVisitInvite is the invite to a single visit of the agent of the
+// token, the link sent when the visit is confirmed
+func ServeVisitInvite(w http.ResponseWriter, r *http.Request) {
+	requestId, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok {
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la visita"})
+		return
+	}
+
+	agent, err := findCalendarAgent(r, r.PathValue("token"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find calendar agent err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la visita"})
+		return
+	}
+
+	req, err := db.FindRequestById(r.Context(), requestId)
+	if err != nil || req.Agent != agent.Id || !isCalendarVisit(req) {
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la visita"})
+		return
+	}
+
+	serveCalendar(w, visits.Calendar(agent, []*db.Request{req}), `attachment; filename="visita.ics"`)
+}
+
+// DownloadVisitInvite is the invite to a visit from the inbox, for the
+// admins and the agent of the visit
+func DownloadVisitInvite(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	req, err := db.FindRequestById(r.Context(), r.PathValue("id"))
+	if err != nil || !isCalendarVisit(req) {
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la visita"})
+		return
+	}
+	if req.Agent != a.Id && a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver esta visita"})
+		return
+	}
+
+	agent, err := db.GetUserById(req.Agent)
+	if err != nil {
+		fmt.Printf("Find agent err: %v\n", err)
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el agente de la visita"})
+		return
+	}
+
+	serveCalendar(w, visits.Calendar(agent, []*db.Request{req}), `attachment; filename="visita.ics"`)
+}
+
+// calendarComponent is the link to the calendar feed of the agent, the
+// token is created the first time
+func calendarComponent(ctx context.Context, r *http.Request, agentId string) templ.Component {
+	token, err := db.GetCalendarToken(ctx, agentId)
+	if err != nil {
+		fmt.Printf("Get calendar token err: %v\n", err)
+		return components.AgentCalendar(agentId, "", "No se pudo obtener el calendario")
+	}
+
+	return components.AgentCalendar(agentId, absoluteURL(r, visits.FeedPath(token)), "")
+}
+
+// ResetAgentCalendarToken changes the link of the calendar feed of the
+// agent, for when the link was shared by mistake
+func ResetAgentCalendarToken(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	agentId := r.PathValue("id")
+	if !canManageAgent(a, agentId) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar este calendario"))
+		return
+	}
+
+	token, err := db.ResetCalendarToken(r.Context(), agentId)
+	if err != nil {
+		fmt.Printf("Reset calendar token err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("No se pudo cambiar el enlace del calendario"))
+		return
+	}
+
+	err = components.AgentCalendar(agentId, absoluteURL(r, visits.FeedPath(token)), "").Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
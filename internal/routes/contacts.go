@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/phone"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+)
+
+func RegisterContactRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/contactos", auth.WithAuthMiddleware(RenderAdminContacts))
+	router.HandleFunc("GET /admin/contactos/{id}", auth.WithAuthMiddleware(RenderAdminContact))
+
+	router.HandleFunc("PUT /api/contacts/{id}", auth.WithAuthMiddleware(UpdateContact))
+	router.HandleFunc("POST /api/contacts/{id}/merge", auth.WithAuthMiddleware(MergeContact))
+}
+
+// contactsPerPage is the page size of the contacts list
+const contactsPerPage = 25
+
+func RenderAdminContacts(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("pagina"))
+	if page < 1 {
+		page = 1
+	}
+
+	pagination, err := db.GetContactsPagination(ctx, search, contactsPerPage, page)
+	if err != nil {
+		fmt.Printf("Get contacts pagination err: %v\n", err)
+		pagination = db.NewPagination(0, contactsPerPage, page)
+	}
+
+	contacts, err := db.FindContacts(ctx, search, contactsPerPage, page)
+	if err != nil {
+		fmt.Printf("Find contacts err: %v\n", err)
+		contacts = []*db.Contact{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminContacts(contacts, pagination, search),
+		a,
+		"Contactos | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func RenderAdminContact(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	contact, err := db.FindContactById(ctx, r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find contact err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el contacto"})
+		return
+	}
+
+	requests, err := db.FindRequests(ctx, &db.RequestFilter{Contact: &contact.Id}, 0, 0)
+	if err != nil {
+		fmt.Printf("Find contact requests err: %v\n", err)
+		requests = []*db.Request{}
+	}
+
+	activities, err := db.FindContactActivities(ctx, contact.Id)
+	if err != nil {
+		fmt.Printf("Find contact activities err: %v\n", err)
+		activities = []*db.Activity{}
+	}
+
+	duplicates, err := db.FindContactDuplicates(ctx, contact)
+	if err != nil {
+		fmt.Printf("Find contact duplicates err: %v\n", err)
+		duplicates = []*db.Contact{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminContact(contact, requests, activities, duplicates, components.ContactInfo(contact, "", false)),
+		a,
+		contact.Name+" | Contactos | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func UpdateContact(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	contact, err := db.FindContactById(ctx, r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find contact err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró el contacto"))
+		return
+	}
+
+	contact.Name = strings.TrimSpace(r.FormValue("name"))
+	contact.Phone = strings.TrimSpace(r.FormValue("phone"))
+	contact.Email = strings.TrimSpace(r.FormValue("email"))
+	if contact.Name == "" {
+		w.WriteHeader(400)
+		components.ContactInfo(contact, "El nombre es obligatorio", false).Render(ctx, w)
+		return
+	}
+	if contact.Phone != "" {
+		if _, err := phone.Parse(contact.Phone); err != nil {
+			w.WriteHeader(400)
+			components.ContactInfo(contact, "El teléfono es inválido", false).Render(ctx, w)
+			return
+		}
+	}
+
+	err = db.UpdateContact(ctx, contact)
+	if err != nil {
+		msg := "Ocurrió un error al guardar el contacto"
+		status := 500
+		if errors.Is(err, db.ErrContactTaken) {
+			msg = "Otro contacto tiene ese teléfono o correo, fusiónalos en su lugar"
+			status = 400
+		} else {
+			fmt.Printf("Update contact err: %v\n", err)
+		}
+		w.WriteHeader(status)
+		components.ContactInfo(contact, msg, false).Render(ctx, w)
+		return
+	}
+
+	err = components.ContactInfo(contact, "", true).Render(ctx, w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MergeContact merges the duplicate into the contact of the path, the
+// duplicate is sent by its id or by its phone or email
+func MergeContact(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	contactId := r.PathValue("id")
+	key := strings.TrimSpace(r.FormValue("duplicate"))
+
+	duplicateId := ""
+	if id, err := uuid.Parse(key); err == nil {
+		duplicateId = id.String()
+	} else if key != "" {
+		duplicate, err := db.FindContactByKey(ctx, key)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find contact err: %v\n", err)
+		}
+		if duplicate != nil {
+			duplicateId = duplicate.Id
+		}
+	}
+	if duplicateId == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("No se encontró el contacto duplicado"))
+		return
+	}
+
+	err := db.MergeContacts(ctx, contactId, duplicateId)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrMergeSameContact):
+			w.WriteHeader(400)
+			w.Write([]byte("No se puede fusionar un contacto consigo mismo"))
+		case errors.Is(err, pgx.ErrNoRows):
+			w.WriteHeader(400)
+			w.Write([]byte("No se encontró el contacto duplicado"))
+		default:
+			fmt.Printf("Merge contacts err: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("Ocurrió un error al fusionar los contactos"))
+		}
+		return
+	}
+
+	w.Header().Add("HX-Redirect", "/admin/contactos/"+contactId)
+	w.WriteHeader(200)
+}
@@ -0,0 +1,337 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+	"github.com/vladwithcode/sibra-site/internal/templates"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+)
+
+func RegisterDocumentRoutes(router *customServeMux) {
+	router.HandleFunc("GET /documentos/{docId}", DownloadPublicDocument)
+	router.HandleFunc("GET /admin/documentos/{docId}", auth.WithAuthMiddleware(DownloadDocument))
+
+	router.HandleFunc("POST /api/property/{id}/documents", auth.WithAuthMiddleware(UploadPropertyDocument))
+	router.HandleFunc("DELETE /api/property/documents/{docId}", auth.WithAuthMiddleware(DeletePropertyDocument))
+}
+
+func UploadPropertyDocument(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, db.MaxDocumentSize+1<<20)
+	err := r.ParseMultipartForm(maxPicturesFormMemory)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("El documento excede el tamaño máximo de %dMB", db.MaxDocumentSize>>20)))
+			return
+		}
+
+		fmt.Printf("Parse form err: %v\n", err)
+		w.WriteHeader(400)
+		w.Write([]byte("El formulario no pudo ser procesado"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	property, err := db.FindPropertyById(ctx, id)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+	if !canManageProperty(a, property) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para agregar documentos a esta propiedad"))
+		return
+	}
+
+	doc := &db.PropertyDocument{
+		Id:         uuid.Must(uuid.NewV7()).String(),
+		PropertyId: property.Id,
+		Title:      strings.TrimSpace(r.FormValue("title")),
+		Public:     r.FormValue("public") == "true",
+		UploadedBy: &a.Id,
+	}
+	invalidFields := templates.InvalidFields{}
+	if len(doc.Title) > 128 {
+		invalidFields["title"] = "El título no debe exceder 128 caracteres"
+	}
+	doc.Kind, err = db.ParseDocumentKind(r.FormValue("kind"))
+	if err != nil {
+		invalidFields["kind"] = "Seleccione un tipo de documento válido"
+	}
+
+	var data []byte
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		invalidFields["file"] = "Seleccione un archivo"
+	} else {
+		defer file.Close()
+		data, err = readDocument(file, fileHeader.Size)
+		if err != nil {
+			invalidFields["file"] = documentErrorMessage(err, fileHeader.Filename)
+			if invalidFields["file"] == "" {
+				fmt.Printf("Read document err: %v\n", err)
+				w.WriteHeader(500)
+				w.Write([]byte("Error al procesar el documento " + fileHeader.Filename))
+				return
+			}
+		}
+	}
+
+	if len(invalidFields) > 0 {
+		respondWithDocuments(w, r, property, invalidFields, false, 400)
+		return
+	}
+
+	data, ext, err := normalizeDocument(data)
+	if err != nil {
+		msg := documentErrorMessage(err, fileHeader.Filename)
+		if msg == "" {
+			fmt.Printf("Read document err: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("Error al procesar el documento " + fileHeader.Filename))
+			return
+		}
+
+		respondWithDocuments(w, r, property, templates.InvalidFields{"file": msg}, false, 400)
+		return
+	}
+
+	// The name is only kept for the downloads, the extension follows the
+	// stored contents since images may have been converted
+	name := path.Base(strings.ReplaceAll(fileHeader.Filename, "\\", "/"))
+	doc.FileName = strings.TrimSuffix(name, path.Ext(name)) + ext
+	doc.ContentType = storage.ContentType(doc.FileName)
+	doc.Size = int64(len(data))
+
+	err = storage.Private.Put(ctx, doc.Key(), bytes.NewReader(data), doc.ContentType)
+	if err != nil {
+		fmt.Printf("Store document err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al guardar el documento"))
+		return
+	}
+
+	err = db.CreatePropertyDocument(ctx, doc)
+	if err != nil {
+		fmt.Printf("Create document err: %v\n", err)
+		if err := storage.Private.Delete(ctx, doc.Key()); err != nil {
+			fmt.Printf("Delete document err: %v\n", err)
+		}
+		w.WriteHeader(500)
+		w.Write([]byte("Error al guardar el documento"))
+		return
+	}
+
+	respondWithDocuments(w, r, property, templates.InvalidFields{}, true, 200)
+}
+
+func DeletePropertyDocument(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	doc, err := db.FindPropertyDocumentById(ctx, r.PathValue("docId"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find document err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró el documento solicitado"))
+		return
+	}
+
+	property, err := db.FindPropertyById(ctx, doc.PropertyId)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+	if !canManageProperty(a, property) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para eliminar este documento"))
+		return
+	}
+
+	err = db.DeletePropertyDocument(ctx, doc.Id)
+	if err != nil {
+		fmt.Printf("Delete document err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al eliminar el documento"))
+		return
+	}
+
+	if err = storage.Private.Delete(ctx, doc.Key()); err != nil {
+		fmt.Printf("Delete document file err: %v\n", err)
+	}
+
+	respondWithDocuments(w, r, property, templates.InvalidFields{}, true, 200)
+}
+
+// DownloadPublicDocument serves the documents shown on the property page,
+// the private ones are not found here
+func DownloadPublicDocument(w http.ResponseWriter, r *http.Request) {
+	serveDocument(w, r, nil)
+}
+
+// documentLinkExpiry is how long the link to a private document works
+const documentLinkExpiry = 5 * time.Minute
+
+func DownloadDocument(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	serveDocument(w, r, a)
+}
+
+// canManageProperty reports whether the user may see the private documents
+// of the property and change them
+func canManageProperty(a *auth.Auth, property *db.Property) bool {
+	return a.Id == property.Agent || a.Role == db.RoleAdmin
+}
+
+// serveDocument serves the document, the private ones only to the agent of
+// the property and the admins. Without a user only the public ones are found.
+func serveDocument(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	doc, err := db.FindPropertyDocumentById(ctx, r.PathValue("docId"))
+	if err != nil || (a == nil && !doc.Public) {
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find document err: %v\n", err)
+		}
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el documento"})
+		return
+	}
+	if !doc.Public {
+		property, err := db.FindPropertyById(ctx, doc.PropertyId)
+		if err != nil {
+			fmt.Printf("Find err: %v\n", err)
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el documento"})
+			return
+		}
+		if !canManageProperty(a, property) {
+			respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver este documento"})
+			return
+		}
+	}
+
+	// The private documents are not streamed through the site, the agent
+	// gets a link that works only for a few minutes
+	if !doc.Public {
+		link, err := storage.Private.SignedURL(ctx, doc.Key(), documentLinkExpiry)
+		if err != nil {
+			fmt.Printf("Sign document url err: %v\n", err)
+			respondWithError(w, 500, ErrorParams{})
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+
+	file, err := storage.Private.Get(ctx, doc.Key())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el documento"})
+			return
+		}
+
+		fmt.Printf("Get document err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	io.Copy(w, file)
+}
+
+var errUnsupportedDocument = errors.New("unsupported document type")
+
+// readDocument reads an uploaded document enforcing db.MaxDocumentSize
+func readDocument(file io.Reader, size int64) ([]byte, error) {
+	if size > db.MaxDocumentSize {
+		return nil, imaging.ErrFileTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, db.MaxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > db.MaxDocumentSize {
+		return nil, imaging.ErrFileTooLarge
+	}
+
+	return data, nil
+}
+
+// normalizeDocument checks the document is a PDF or an image and returns
+// the contents to store with their extension. Images are re-encoded like the
+// gallery pictures so the metadata of the camera is not shared.
+func normalizeDocument(data []byte) ([]byte, string, error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return data, ".pdf", nil
+	}
+
+	upload, err := imaging.ReadUpload(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedImage) || errors.Is(err, imaging.ErrEmptyImage) {
+			return nil, "", errUnsupportedDocument
+		}
+		return nil, "", err
+	}
+
+	data, err = upload.EncodeOriginal()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, imaging.Extension(upload.Format), nil
+}
+
+// documentErrorMessage returns the message shown to the user when a
+// document is rejected, or an empty string if err is not a validation error
+func documentErrorMessage(err error, fileName string) string {
+	switch {
+	case errors.Is(err, errUnsupportedDocument):
+		return "Formato no soportado: " + fileName + " (se aceptan PDF, JPEG, PNG, WebP y HEIC)"
+	case errors.Is(err, imaging.ErrFileTooLarge):
+		return fmt.Sprintf("El documento %s excede el tamaño máximo de %dMB", fileName, db.MaxDocumentSize>>20)
+	}
+
+	return pictureErrorMessage(err, fileName)
+}
+
+func respondWithDocuments(w http.ResponseWriter, r *http.Request, property *db.Property, invalidFields templates.InvalidFields, successfulUpdate bool, status int) {
+	docs, err := db.FindPropertyDocuments(r.Context(), property.Id, false)
+	if err != nil {
+		fmt.Printf("Find documents err: %v\n", err)
+		docs = []*db.PropertyDocument{}
+	}
+
+	w.WriteHeader(status)
+	err = components.PropDocuments(property, docs, invalidFields, successfulUpdate).Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
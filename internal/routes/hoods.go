@@ -0,0 +1,372 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/geo"
+	"github.com/vladwithcode/sibra-site/internal/templates"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/tiles"
+)
+
+const maxBoundariesFileSize = 10 << 20
+
+func RegisterHoodRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/colonias", auth.WithAuthMiddleware(RenderAdminHoods))
+	router.HandleFunc("GET /admin/colonias/nueva", auth.WithAuthMiddleware(RenderNewHood))
+	router.HandleFunc("GET /admin/colonias/editar/{id}", auth.WithAuthMiddleware(RenderUpdateHood))
+
+	router.HandleFunc("POST /api/hoods", auth.WithAuthMiddleware(CreateHood))
+	router.HandleFunc("POST /api/hoods/import", auth.WithAuthMiddleware(ImportHoods))
+	router.HandleFunc("PUT /api/hoods/{id}", auth.WithAuthMiddleware(UpdateHood))
+	router.HandleFunc("DELETE /api/hoods/{id}", auth.WithAuthMiddleware(DeleteHood))
+	router.HandleFunc("POST /api/hoods/{id}/boundaries", auth.WithAuthMiddleware(UpdateHoodBoundaries))
+	router.HandleFunc("POST /api/hoods/{id}/prices", auth.WithAuthMiddleware(AddHoodPrice))
+}
+
+func RenderAdminHoods(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	hoods, err := db.FindHoods()
+	if err != nil {
+		fmt.Printf("Find hoods err: %v\n", err)
+		hoods = []*db.Hood{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminHoods(hoods),
+		a,
+		"Colonias | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func RenderNewHood(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	pages.AdminLayout(
+		pages.AdminHoodForm(nil, nil),
+		a,
+		"Nueva Colonia | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func RenderUpdateHood(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	hood, err := db.FindHoodById(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la colonia"})
+			return
+		}
+
+		fmt.Printf("Find hood err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	prices, err := db.FindHoodPrices(ctx, hood.Id)
+	if err != nil {
+		fmt.Printf("Find hood prices err: %v\n", err)
+		prices = []*db.HoodPrice{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminHoodForm(hood, prices),
+		a,
+		"Editar Colonia | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+// readBoundariesFile parses the optional "boundaries" file of a multipart
+// form. Returns nil features if no file was sent.
+func readBoundariesFile(r *http.Request) ([]geo.Feature, error) {
+	file, handle, err := r.FormFile("boundaries")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if handle.Size > maxBoundariesFileSize {
+		return nil, fmt.Errorf("el archivo excede el tamaño máximo de %dMB", maxBoundariesFileSize>>20)
+	}
+
+	return geo.ReadFeatures(handle.Filename, file)
+}
+
+// parseHoodPrice reads the "sqMeterPrice" and "effectiveDate" fields.
+// ok is false if no price was sent.
+func parseHoodPrice(r *http.Request, invalid templates.InvalidFields) (price float64, effectiveDate time.Time, ok bool) {
+	strPrice := strings.TrimSpace(r.FormValue("sqMeterPrice"))
+	if strPrice == "" {
+		return
+	}
+
+	price, err := strconv.ParseFloat(strPrice, 64)
+	if err != nil || price < 0 {
+		invalid["sqMeterPrice"] = "Ingresa un precio válido"
+		return
+	}
+
+	effectiveDate = time.Now()
+	if strDate := r.FormValue("effectiveDate"); strDate != "" {
+		effectiveDate, err = time.Parse("2006-01-02", strDate)
+		if err != nil {
+			invalid["effectiveDate"] = "Ingresa una fecha válida"
+			return
+		}
+	}
+
+	ok = true
+	return
+}
+
+func CreateHood(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	err := r.ParseMultipartForm(maxBoundariesFileSize)
+	if err != nil {
+		fmt.Printf("Parse form err: %v\n", err)
+		respondWithError(w, 400, ErrorParams{ErrorMessage: "El formulario contiene información inválida"})
+		return
+	}
+
+	invalid := templates.InvalidFields{}
+	hood := db.Hood{
+		Name: strings.TrimSpace(r.FormValue("name")),
+	}
+	if hood.Name == "" {
+		invalid["name"] = templates.DEFAULT_FIELD_VALIDATE_MSG
+	}
+
+	features, err := readBoundariesFile(r)
+	if err != nil {
+		invalid["boundaries"] = err.Error()
+	} else if len(features) > 0 {
+		// Every polygon of the file is considered part of the same hood
+		mp := features[0].Boundaries
+		for _, f := range features[1:] {
+			mp = append(mp, f.Boundaries...)
+		}
+		hood.SetBoundaries(mp)
+	}
+
+	price, effectiveDate, hasPrice := parseHoodPrice(r, invalid)
+
+	if len(invalid) > 0 {
+		w.WriteHeader(400)
+		components.HoodCreateForm(&hood, invalid, "").Render(context.Background(), w)
+		return
+	}
+
+	err = db.CreateHood(&hood)
+	if err != nil {
+		fmt.Printf("Create hood err: %v\n", err)
+		msg := "Ocurrió un error al registrar la colonia"
+		if strings.Contains(err.Error(), "duplicate key") {
+			msg = "Ya existe una colonia con ese nombre"
+		}
+		w.WriteHeader(400)
+		components.HoodCreateForm(&hood, invalid, msg).Render(context.Background(), w)
+		return
+	}
+
+	if hasPrice {
+		_, err = db.AddHoodPrice(ctx, hood.Id, price, effectiveDate)
+		if err != nil {
+			fmt.Printf("Add hood price err: %v\n", err)
+		}
+	}
+	tiles.Invalidate(tiles.LayerHoods)
+
+	w.Header().Add("HX-Redirect", "/admin/colonias/editar/"+hood.Id)
+	w.WriteHeader(201)
+}
+
+func ImportHoods(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	err := r.ParseMultipartForm(maxBoundariesFileSize)
+	if err != nil {
+		fmt.Printf("Parse form err: %v\n", err)
+		w.WriteHeader(400)
+		components.HoodImportResult(0, 0, 0, "El formulario contiene información inválida").Render(context.Background(), w)
+		return
+	}
+
+	features, err := readBoundariesFile(r)
+	if err != nil || len(features) == 0 {
+		msg := "Selecciona un archivo GeoJSON o KML"
+		if err != nil {
+			msg = "No se pudo leer el archivo: " + err.Error()
+		}
+		w.WriteHeader(400)
+		components.HoodImportResult(0, 0, 0, msg).Render(context.Background(), w)
+		return
+	}
+
+	created, updated, skipped, err := db.ImportHoods(ctx, features)
+	if err != nil {
+		fmt.Printf("Import hoods err: %v\n", err)
+		w.WriteHeader(500)
+		components.HoodImportResult(0, 0, 0, "Ocurrió un error al importar las colonias").Render(context.Background(), w)
+		return
+	}
+
+	tiles.Invalidate(tiles.LayerHoods)
+	components.HoodImportResult(created, updated, skipped, "").Render(context.Background(), w)
+}
+
+func UpdateHood(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	hood, err := db.FindHoodById(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la colonia"})
+			return
+		}
+
+		fmt.Printf("Find hood err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	invalid := templates.InvalidFields{}
+	hood.Name = strings.TrimSpace(r.FormValue("name"))
+	if hood.Name == "" {
+		invalid["name"] = templates.DEFAULT_FIELD_VALIDATE_MSG
+		w.WriteHeader(400)
+		components.HoodNameForm(hood, invalid, false).Render(context.Background(), w)
+		return
+	}
+
+	err = db.UpdateHood(ctx, hood)
+	if err != nil {
+		fmt.Printf("Update hood err: %v\n", err)
+		invalid["name"] = "No se pudo actualizar la colonia"
+		if strings.Contains(err.Error(), "duplicate key") {
+			invalid["name"] = "Ya existe una colonia con ese nombre"
+		}
+		w.WriteHeader(400)
+		components.HoodNameForm(hood, invalid, false).Render(context.Background(), w)
+		return
+	}
+
+	// The properties of the hood were moved to the new name
+	tiles.Invalidate(tiles.LayerHoods, tiles.LayerProperties)
+	components.HoodNameForm(hood, invalid, true).Render(context.Background(), w)
+}
+
+func UpdateHoodBoundaries(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	hood, err := db.FindHoodById(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la colonia"})
+			return
+		}
+
+		fmt.Printf("Find hood err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	err = r.ParseMultipartForm(maxBoundariesFileSize)
+	if err != nil {
+		fmt.Printf("Parse form err: %v\n", err)
+		w.WriteHeader(400)
+		components.HoodBoundariesForm(hood, "El formulario contiene información inválida", false).Render(context.Background(), w)
+		return
+	}
+
+	features, err := readBoundariesFile(r)
+	if err != nil || len(features) == 0 {
+		msg := "Selecciona un archivo GeoJSON o KML"
+		if err != nil {
+			msg = "No se pudo leer el archivo: " + err.Error()
+		}
+		w.WriteHeader(400)
+		components.HoodBoundariesForm(hood, msg, false).Render(context.Background(), w)
+		return
+	}
+
+	mp := features[0].Boundaries
+	for _, f := range features[1:] {
+		mp = append(mp, f.Boundaries...)
+	}
+	hood.SetBoundaries(mp)
+
+	err = db.UpdateHood(ctx, hood)
+	if err != nil {
+		fmt.Printf("Update hood boundaries err: %v\n", err)
+		w.WriteHeader(500)
+		components.HoodBoundariesForm(hood, "Ocurrió un error al guardar los límites", false).Render(context.Background(), w)
+		return
+	}
+
+	tiles.Invalidate(tiles.LayerHoods)
+	components.HoodBoundariesForm(hood, "", true).Render(context.Background(), w)
+}
+
+func AddHoodPrice(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	hoodId := r.PathValue("id")
+
+	invalid := templates.InvalidFields{}
+	price, effectiveDate, ok := parseHoodPrice(r, invalid)
+	if !ok && len(invalid) == 0 {
+		invalid["sqMeterPrice"] = templates.DEFAULT_FIELD_VALIDATE_MSG
+	}
+
+	if len(invalid) > 0 {
+		prices, err := db.FindHoodPrices(ctx, hoodId)
+		if err != nil {
+			fmt.Printf("Find hood prices err: %v\n", err)
+		}
+		w.WriteHeader(400)
+		components.HoodPriceHistory(hoodId, prices, invalid).Render(context.Background(), w)
+		return
+	}
+
+	_, err := db.AddHoodPrice(ctx, hoodId, price, effectiveDate)
+	if err != nil {
+		fmt.Printf("Add hood price err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+	tiles.Invalidate(tiles.LayerHoods)
+
+	prices, err := db.FindHoodPrices(ctx, hoodId)
+	if err != nil {
+		fmt.Printf("Find hood prices err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	components.HoodPriceHistory(hoodId, prices, invalid).Render(context.Background(), w)
+}
+
+func DeleteHood(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	err := db.DeleteHoodById(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró la colonia"})
+			return
+		}
+
+		fmt.Printf("Delete hood err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	tiles.Invalidate(tiles.LayerHoods)
+	w.Header().Add("HX-Redirect", "/admin/colonias")
+	w.WriteHeader(200)
+	w.Write([]byte("Colonia eliminada"))
+}
@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+)
+
+const (
+	defaultTrendMonths = 12
+	maxTrendMonths     = 120
+)
+
+func RegisterMarketRoutes(router *customServeMux) {
+	router.HandleFunc("GET /api/market/trends", FindMarketTrends)
+	router.HandleFunc("GET /colonias/{id}", auth.CheckAuthMiddleware(RenderHoodDetail))
+}
+
+// FindMarketTrends responds with the monthly snapshots of a single series.
+// Omitted dimensions select the aggregate of every value, so no parameters
+// returns the trend of the whole market.
+func FindMarketTrends(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	months := defaultTrendMonths
+	if monthsStr := query.Get("months"); monthsStr != "" {
+		m, err := strconv.Atoi(monthsStr)
+		if err != nil || m <= 0 || m > maxTrendMonths {
+			respondWithError(w, 400, ErrorParams{
+				ErrorMessage: fmt.Sprintf("El número de meses debe estar entre 1 y %d", maxTrendMonths),
+			})
+			return
+		}
+		months = m
+	}
+
+	now := time.Now()
+	filter := db.MarketTrendFilter{
+		Hood:         query.Get("hood"),
+		City:         query.Get("city"),
+		Contract:     query.Get("contract"),
+		PropertyType: query.Get("propType"),
+		From:         db.MonthStart(now).AddDate(0, -(months - 1), 0),
+		To:           now,
+	}
+
+	snapshots, err := db.FindMarketTrends(r.Context(), &filter)
+	if err != nil {
+		fmt.Printf("Find market trends err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]any{
+		"hood":      filter.Hood,
+		"city":      filter.City,
+		"contract":  filter.Contract,
+		"propType":  filter.PropertyType,
+		"snapshots": snapshots,
+	})
+	if err != nil {
+		fmt.Printf("Encode market trends err: %v\n", err)
+	}
+}
+
+func RenderHoodDetail(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	hood, err := db.FindHoodById(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			render404Page(w, r, a)
+			return
+		}
+
+		fmt.Printf("Find hood err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	saleTrends, err := db.FindMarketTrends(ctx, &db.MarketTrendFilter{Hood: hood.Name, Contract: "venta"})
+	if err != nil {
+		fmt.Printf("Find sale trends err: %v\n", err)
+		saleTrends = []*db.MarketSnapshot{}
+	}
+	rentTrends, err := db.FindMarketTrends(ctx, &db.MarketTrendFilter{Hood: hood.Name, Contract: "renta"})
+	if err != nil {
+		fmt.Printf("Find rent trends err: %v\n", err)
+		rentTrends = []*db.MarketSnapshot{}
+	}
+
+	status := db.PropertyStatusPublished
+	orderBy := db.OrderByListingDate
+	orderDir := db.OrderDirectionDESC
+	props, err := db.GetProperties(ctx, &db.PropertyFilter{
+		NbHood:         &hood.Name,
+		Status:         &status,
+		OrderBy:        &orderBy,
+		OrderDirection: &orderDir,
+	}, db.DefaultPageSize, 1)
+	if err != nil {
+		fmt.Printf("Find hood props err: %v\n", err)
+		props = []*db.Property{}
+	}
+
+	err = pages.HoodDetail(hood, saleTrends, rentTrends, props, a).Render(context.Background(), w)
+	if err != nil {
+		fmt.Printf("Render err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+func RegisterMediaRoutes(router *customServeMux) {
+	// Signed URLs of the local stores, S3 serves its own signed URLs
+	router.HandleFunc("GET "+storage.SignedPathPrefix+"{key...}", ServeSignedMedia)
+	router.HandleFunc("GET "+storage.PrivateSignedPathPrefix+"{key...}", ServeSignedPrivateMedia)
+}
+
+func ServeSignedMedia(w http.ResponseWriter, r *http.Request) {
+	serveSignedObject(w, r, storage.Media)
+}
+
+func ServeSignedPrivateMedia(w http.ResponseWriter, r *http.Request) {
+	serveSignedObject(w, r, storage.Private)
+}
+
+func serveSignedObject(w http.ResponseWriter, r *http.Request, store storage.Store) {
+	local, ok := store.(*storage.Local)
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+
+	key := r.PathValue("key")
+	query := r.URL.Query()
+	if err := local.VerifySignature(key, query.Get("expires"), query.Get("signature")); err != nil {
+		w.WriteHeader(403)
+		w.Write([]byte("El enlace no es válido o ha expirado"))
+		return
+	}
+
+	file, err := local.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			w.WriteHeader(404)
+			return
+		}
+
+		fmt.Printf("Get media err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", storage.ContentType(key))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, file)
+}
@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/spam"
+	"github.com/vladwithcode/sibra-site/internal/templates/emails"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+)
+
+// minPasswordLength is the shortest password a reset takes
+const minPasswordLength = 8
+
+// The resets asked for by each IP and for each email in an hour, so the
+// form can't be used to flood the inboxes of the users
+var (
+	resetIPLimiter    = spam.NewLimiter("reset-ip", 10, time.Hour)
+	resetEmailLimiter = spam.NewLimiter("reset-email", 3, time.Hour)
+)
+
+func RegisterPasswordRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/recuperar-contrasena", auth.CheckAuthMiddleware(RenderForgotPassword))
+	router.HandleFunc("POST /admin/recuperar-contrasena", auth.CheckAuthMiddleware(SendPasswordReset))
+	router.HandleFunc("GET /admin/restablecer-contrasena/{token}", auth.CheckAuthMiddleware(RenderResetPassword))
+	router.HandleFunc("POST /admin/restablecer-contrasena/{token}", auth.CheckAuthMiddleware(ResetPassword))
+}
+
+func RenderForgotPassword(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	pages.ForgotPassword(a, false, "").Render(r.Context(), w)
+}
+
+// SendPasswordReset emails the link to reset the password to the user of
+// the email. The answer is the same whether the user exists or not, so
+// the form can't be used to find the emails of the users.
+func SendPasswordReset(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	if !email.Enabled() {
+		w.WriteHeader(503)
+		pages.ForgotPassword(a, false, "El envío de correos no está disponible, pide a un administrador que cambie tu contraseña").Render(ctx, w)
+		return
+	}
+
+	// The link is only built from SITE_URL, the Host of the request can be
+	// set by anyone and would send the token to their site
+	if !strings.HasPrefix(internal.SiteURL("/"), "http") {
+		fmt.Printf("Password reset not sent: %s is not set\n", internal.EnvVarSiteURL)
+		w.WriteHeader(503)
+		pages.ForgotPassword(a, false, "El envío de correos no está disponible, pide a un administrador que cambie tu contraseña").Render(ctx, w)
+		return
+	}
+
+	address := strings.TrimSpace(r.FormValue("email"))
+	if !email.ValidAddress(address) {
+		w.WriteHeader(400)
+		pages.ForgotPassword(a, false, "Escribe un correo válido").Render(ctx, w)
+		return
+	}
+
+	now := time.Now()
+	ip := spam.ClientIP(r)
+	if !resetIPLimiter.Allow(ctx, ip, now) || !resetEmailLimiter.Allow(ctx, strings.ToLower(address), now) {
+		fmt.Printf("Password reset rate limited for ip %s\n", ip)
+		w.WriteHeader(429)
+		pages.ForgotPassword(a, false, "Recibimos demasiadas solicitudes, inténtalo más tarde").Render(ctx, w)
+		return
+	}
+
+	user, token, err := db.CreatePasswordReset(ctx, address, now)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Create password reset err: %v\n", err)
+			w.WriteHeader(500)
+			pages.ForgotPassword(a, false, "Ocurrió un error, vuelve a intentarlo").Render(ctx, w)
+			return
+		}
+		pages.ForgotPassword(a, true, "").Render(ctx, w)
+		return
+	}
+
+	link := internal.SiteURL("/admin/restablecer-contrasena/" + token)
+	go func() {
+		ctx := context.Background()
+		msg := &email.Message{
+			To:      []string{user.Email},
+			Subject: "Restablecer contraseña | Sibra Durango",
+		}
+		err := msg.Render(
+			ctx,
+			emails.PasswordReset(user, link, db.PasswordResetValidity),
+			emails.PasswordResetText(user, link, db.PasswordResetValidity),
+		)
+		if err == nil {
+			err = email.Send(ctx, msg)
+		}
+		if err != nil {
+			log.Printf("Could not email the password reset of %s: %v", user.Username, err)
+		}
+	}()
+
+	pages.ForgotPassword(a, true, "").Render(ctx, w)
+}
+
+func RenderResetPassword(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	token := r.PathValue("token")
+
+	_, err := db.FindPasswordReset(ctx, token, time.Now())
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("Find password reset err: %v\n", err)
+	}
+
+	pages.ResetPassword(a, token, err == nil, false, "").Render(ctx, w)
+}
+
+func ResetPassword(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	token := r.PathValue("token")
+	newPass := r.FormValue("new-pass")
+
+	if len(newPass) < minPasswordLength {
+		w.WriteHeader(400)
+		pages.ResetPassword(a, token, true, false, fmt.Sprintf("La contraseña debe tener al menos %d caracteres", minPasswordLength)).Render(ctx, w)
+		return
+	}
+	if newPass != r.FormValue("confirm-pass") {
+		w.WriteHeader(400)
+		pages.ResetPassword(a, token, true, false, "Las contraseñas no coinciden").Render(ctx, w)
+		return
+	}
+
+	_, err := db.ResetPassword(ctx, token, newPass, time.Now())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.WriteHeader(404)
+			pages.ResetPassword(a, token, false, false, "").Render(ctx, w)
+			return
+		}
+
+		fmt.Printf("Reset password err: %v\n", err)
+		w.WriteHeader(500)
+		pages.ResetPassword(a, token, true, false, "Ocurrió un error al cambiar la contraseña").Render(ctx, w)
+		return
+	}
+
+	pages.ResetPassword(a, token, false, true, "").Render(ctx, w)
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/storage"
+)
+
+// maxPicturesFormMemory is the part of a pictures form kept in memory,
+// the rest of the files are buffered to disk by ParseMultipartForm
+const maxPicturesFormMemory = 32 << 20
+
+// readPictureFile reads a picture sent in a multipart form, up to one
+// byte over the size limit so the larger ones are rejected
+func readPictureFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	if fileHeader.Size > imaging.MaxFileSize {
+		return nil, imaging.ErrFileTooLarge
+	}
+
+	pic, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer pic.Close()
+
+	return io.ReadAll(io.LimitReader(pic, imaging.MaxFileSize+1))
+}
+
+// checkUploadedPicture validates a picture sent in a multipart form by its
+// header, without decoding it. See imaging.CheckUpload for the checks
+// applied.
+func checkUploadedPicture(fileHeader *multipart.FileHeader) error {
+	data, err := readPictureFile(fileHeader)
+	if err != nil {
+		return err
+	}
+
+	_, err = imaging.CheckUpload(data)
+	return err
+}
+
+// readUploadedPicture validates a picture sent in a multipart form before
+// anything is stored. See imaging.ReadUpload for the checks applied.
+func readUploadedPicture(fileHeader *multipart.FileHeader) (*imaging.Upload, error) {
+	data, err := readPictureFile(fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.ReadUpload(data)
+}
+
+// pictureErrorMessage returns the message shown to the user when a picture
+// is rejected, or an empty string if err is not a validation error
+func pictureErrorMessage(err error, fileName string) string {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return fmt.Sprintf("Las imagenes exceden el tamaño máximo de %dMB", maxBytesErr.Limit>>20)
+	case errors.Is(err, imaging.ErrFileTooLarge):
+		return fmt.Sprintf("La imagen %s excede el tamaño máximo de %dMB", fileName, imaging.MaxFileSize>>20)
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		return fmt.Sprintf("La imagen %s excede la resolución máxima de %d megapixeles", fileName, imaging.MaxPixels/1_000_000)
+	case errors.Is(err, imaging.ErrDecoderNotFound):
+		return "El formato de la imagen " + fileName + " no es soportado por el servidor, conviertala a JPEG"
+	case errors.Is(err, imaging.ErrUnsupportedImage), errors.Is(err, imaging.ErrEmptyImage):
+		return "Formato de imagen no soportado: " + fileName + " (se aceptan JPEG, PNG, WebP y HEIC)"
+	}
+
+	return ""
+}
+
+// storePicture stores the upload without metadata as baseName with the
+// extension of its format and returns the resulting file name
+func storePicture(ctx context.Context, upload *imaging.Upload, prefix, baseName string) (string, error) {
+	data, err := upload.EncodeOriginal()
+	if err != nil {
+		return "", err
+	}
+
+	fileName := baseName + imaging.Extension(upload.Format)
+	key := storage.Key(prefix, fileName)
+	err = storage.Media.Put(ctx, key, bytes.NewReader(data), storage.ContentType(key))
+	if err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
@@ -3,12 +3,11 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"io"
+	"maps"
 	"net/http"
-	"os"
-	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
@@ -18,8 +17,12 @@ import (
 	"github.com/vladwithcode/sibra-site/internal"
 	"github.com/vladwithcode/sibra-site/internal/auth"
 	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/gallery"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/spam"
 	"github.com/vladwithcode/sibra-site/internal/templates"
 	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/tiles"
 )
 
 func RegisterPropertyRoutes(router *customServeMux) {
@@ -30,6 +33,10 @@ func RegisterPropertyRoutes(router *customServeMux) {
 	router.HandleFunc("PUT /api/property/{id}", auth.WithAuthMiddleware(UpdateProperty))
 	router.HandleFunc("DELETE /api/property/{id}/delete", auth.WithAuthMiddleware(DeletePropertyById))
 	router.HandleFunc("POST /api/property/pictures/{id}", auth.WithAuthMiddleware(UploadPropertyPictures))
+	router.HandleFunc("PUT /api/property/pictures/{id}/order", auth.WithAuthMiddleware(ReorderPropertyPictures))
+	router.HandleFunc("PUT /api/property/pictures/{id}/main", auth.WithAuthMiddleware(SetPropertyMainPicture))
+	router.HandleFunc("PUT /api/property/pictures/{id}/details", auth.WithAuthMiddleware(UpdatePropertyPictureDetails))
+	router.HandleFunc("PUT /api/property/pictures/{id}/watermark", auth.WithAuthMiddleware(SetPropertyWatermark))
 	// router.HandleFunc("DELETE /api/property/pictures/{id}", auth.WithAuthMiddleware(UploadPropertyPictures))
 }
 
@@ -229,6 +236,7 @@ func CreateProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
+	tiles.Invalidate(tiles.LayerProperties)
 	data["id"] = property.Id
 
 	err = templ.Execute(w, map[string]any{
@@ -243,12 +251,6 @@ func CreateProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		respondWithError(w, 500, ErrorParams{})
 		return
 	}
-
-	err = property.CreateStaticDir()
-
-	if err != nil {
-		fmt.Printf("Error creating static dir for prop: %v\n", property.Id)
-	}
 }
 
 func UpdateProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
@@ -272,6 +274,7 @@ func UpdateProperty(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		w.Write([]byte("No se pudo actualizar la propiedad"))
 		return
 	}
+	tiles.Invalidate(tiles.LayerProperties)
 
 	err = components.UpdatePropForm(
 		&newProperty,
@@ -287,11 +290,16 @@ func UploadPropertyPictures(w http.ResponseWriter, r *http.Request, a *auth.Auth
 	id := r.PathValue("id")
 	ctx := r.Context()
 
-	err := r.ParseMultipartForm(90 << 20)
+	r.Body = http.MaxBytesReader(w, r.Body, (db.MaxPropertyImages+1)*imaging.MaxFileSize)
+	err := r.ParseMultipartForm(maxPicturesFormMemory)
 	if err != nil {
 		fmt.Printf("parse form err: %v\n", err)
+		msg := pictureErrorMessage(err, "")
+		if msg == "" {
+			msg = "El formulario no pudo ser procesado"
+		}
 		w.WriteHeader(400)
-		w.Write([]byte("El formulario no pudo ser procesado"))
+		w.Write([]byte(msg))
 		return
 	}
 
@@ -304,111 +312,400 @@ func UploadPropertyPictures(w http.ResponseWriter, r *http.Request, a *auth.Auth
 		return
 	}
 
-	filePath := filepath.Join("web/static/properties", property.Id)
-	err = os.MkdirAll(filePath, 0644)
+	delPicIds := r.MultipartForm.Value["delPics"]
+	keptPics := []string{}
+	for _, img := range property.Images {
+		if img != "" && !slices.Contains(delPicIds, img) {
+			keptPics = append(keptPics, img)
+		}
+	}
 
-	if err != nil && !os.IsExist(err) {
-		fmt.Printf("Create dir err: %v\n", err)
+	pics := r.MultipartForm.File["pics"]
+	if len(keptPics)+len(pics) > db.MaxPropertyImages {
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf(
+			"Solo se permiten %d imagenes por propiedad, elimine %d para continuar",
+			db.MaxPropertyImages,
+			len(keptPics)+len(pics)-db.MaxPropertyImages,
+		)))
+		return
+	}
+
+	// Every file is validated by its header before storing any of them,
+	// they are decoded one at a time when they are saved so only one
+	// bitmap is in memory at once
+	for _, fileHeader := range pics {
+		err = checkUploadedPicture(fileHeader)
+		if err != nil {
+			respondWithPictureError(w, err, fileHeader.Filename)
+			return
+		}
+	}
+
+	_, handle, err := r.FormFile("main-pic")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) {
+		fmt.Printf("Parse pic err: %v\n", err)
 		w.WriteHeader(500)
-		w.Write([]byte("Error al crear el directorio para la propiedad"))
+		w.Write([]byte("Error al procesar la imagen"))
 		return
 	}
 
-	pics := r.MultipartForm.File["pics"]
+	if handle != nil {
+		err = checkUploadedPicture(handle)
+		if err != nil {
+			respondWithPictureError(w, err, handle.Filename)
+			return
+		}
+	}
+
+	if property.ImgVariants == nil {
+		property.ImgVariants = map[string]imaging.Variants{}
+	}
+
+	// The replaced pictures are removed once the database no longer
+	// references them, with the variants they had
+	removed := []string{}
+	for _, img := range property.Images {
+		if img != "" && !slices.Contains(keptPics, img) {
+			removed = append(removed, img)
+		}
+	}
+	if handle != nil && property.MainImg != "" {
+		removed = append(removed, property.MainImg)
+	}
+	previous := *property
+	previous.ImgVariants = maps.Clone(property.ImgVariants)
+
+	// On failure the pictures of this request are removed, the variants
+	// half written are left to the media check
+	saved := []string{}
+	hashes := map[string]uint64{}
+	discardSaved := func() {
+		for _, img := range saved {
+			if err := gallery.Remove(ctx, property, img); err != nil {
+				fmt.Printf("Remove picture err: %v\n", err)
+			}
+		}
+	}
+
 	for _, fileHeader := range pics {
-		pic, err := fileHeader.Open()
-		fmt.Printf("pic: %v\n", pic)
+		upload, err := readUploadedPicture(fileHeader)
 		if err != nil {
-			fmt.Printf("Open file err: %v\n", err)
-			w.WriteHeader(500)
-			w.Write([]byte("Error al procesar las imagenes"))
+			discardSaved()
+			respondWithPictureError(w, err, fileHeader.Filename)
 			return
 		}
-		defer pic.Close()
 
-		fileName := time.Now().Format("20060102-150405") + "-" + uuid.NewString() + filepath.Ext(fileHeader.Filename)
-		filep := filepath.Join(filePath, fileName)
-		outFile, err := os.Create(filep)
+		baseName := time.Now().Format("20060102-150405") + "-" + uuid.NewString()
+		fileName, variants, err := gallery.Save(ctx, property, upload, baseName)
 		if err != nil {
-			fmt.Printf("Create outfile err: %v\n", err)
+			fmt.Printf("Save picture err: %v\n", err)
+			discardSaved()
 			w.WriteHeader(500)
 			w.Write([]byte("Error al guardar las imagenes"))
 			return
 		}
-		defer outFile.Close()
 
-		_, err = io.Copy(outFile, pic)
+		saved = append(saved, fileName)
+		property.ImgVariants[fileName] = *variants
+		hashes[fileName] = imaging.DHash(upload.Image)
+	}
+	property.Images = append(keptPics, saved...)
+
+	if handle != nil {
+		mainUpload, err := readUploadedPicture(handle)
 		if err != nil {
-			fmt.Printf("Write file err: %v\n", err)
+			discardSaved()
+			respondWithPictureError(w, err, handle.Filename)
+			return
+		}
+
+		// A new name on every upload so browsers don't keep showing the
+		// cached picture
+		baseName := "main-" + time.Now().Format("20060102-150405") + "-" + uuid.NewString()
+		mainFileName, variants, err := gallery.Save(ctx, property, mainUpload, baseName)
+		if err != nil {
+			fmt.Printf("Save main picture err: %v\n", err)
+			discardSaved()
 			w.WriteHeader(500)
-			w.Write([]byte("Error al guardar las imagenes"))
+			w.Write([]byte("Error al guardar la imagen principal"))
 			return
 		}
 
-		property.Images = append(property.Images, fileName)
+		saved = append(saved, mainFileName)
+		property.MainImg = mainFileName
+		property.ImgVariants[mainFileName] = *variants
+		hashes[mainFileName] = imaging.DHash(mainUpload.Image)
 	}
 
-	mainPic, handle, err := r.FormFile("main-pic")
-	if err != nil && !strings.Contains(err.Error(), "no such file") {
-		fmt.Printf("Parse pic err: %v\n", err)
+	for _, img := range removed {
+		delete(property.ImgVariants, img)
+	}
+
+	err = db.UpdatePropertyImages(property)
+	if err != nil {
+		fmt.Printf("Update images err: %v\n", err)
+		discardSaved()
 		w.WriteHeader(500)
-		w.Write([]byte("Error al procesar la imagen"))
+		w.Write([]byte("Error al procesar las imagenes"))
 		return
 	}
 
-	if handle != nil {
-		defer mainPic.Close()
+	for _, img := range removed {
+		if err = gallery.Remove(ctx, &previous, img); err != nil {
+			fmt.Printf("Remove picture err: %v\n", err)
+		}
+	}
+	err = db.UpdatePropertyImageHashes(ctx, property.Id, hashes)
+	if err != nil {
+		fmt.Printf("Update image hashes err: %v\n", err)
+	}
+	tiles.Invalidate(tiles.LayerProperties)
 
-		mainFileName := "main-pic" + filepath.Ext(handle.Filename)
-		file, err := os.Create(filepath.Join(filePath, mainFileName))
-		if err != nil {
-			fmt.Printf("Create main file err: %v\n", err)
+	property.Gallery, err = db.FindPropertyImages(ctx, property.Id)
+	if err != nil {
+		fmt.Printf("Find images err: %v\n", err)
+	}
+
+	duplicates, err := db.FindDuplicateImages(ctx, property.Id, db.DuplicateMaxDistance)
+	if err != nil {
+		fmt.Printf("Find duplicates err: %v\n", err)
+	}
+
+	err = components.UpdatePropImagesForm(property, true).Render(context.Background(), w)
+	if err != nil {
+		panic(err)
+	}
+	err = components.PropImageDuplicates(duplicates).Render(context.Background(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func ReorderPropertyPictures(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("El formulario no pudo ser procesado"))
+		return
+	}
+
+	property, err := db.FindPropertyById(ctx, id)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+
+	err = property.ReorderImages(r.Form["order"])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("El orden no coincide con las imagenes de la propiedad, recargue la página"))
+		return
+	}
+
+	err = db.UpdatePropertyImages(property)
+	if err != nil {
+		fmt.Printf("Update images err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al ordenar las imagenes"))
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func SetPropertyMainPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("El formulario no pudo ser procesado"))
+		return
+	}
+
+	property, err := db.FindPropertyById(ctx, id)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+
+	err = property.SetMainImg(r.FormValue("img"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("La imagen no pertenece a la galería de la propiedad"))
+		return
+	}
+
+	err = db.UpdatePropertyImages(property)
+	if err != nil {
+		fmt.Printf("Update images err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al cambiar la imagen principal"))
+		return
+	}
+	tiles.Invalidate(tiles.LayerProperties)
+
+	property.Gallery, err = db.FindPropertyImages(ctx, property.Id)
+	if err != nil {
+		fmt.Printf("Find images err: %v\n", err)
+	}
+
+	err = components.UpdatePropImagesForm(property, true).Render(context.Background(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetPropertyWatermark toggles the watermark of the property and renders
+// its pictures again
+func SetPropertyWatermark(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("El formulario no pudo ser procesado"))
+		return
+	}
+
+	property, err := db.FindPropertyById(ctx, id)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+
+	watermark := r.FormValue("watermark") == "on"
+	if watermark {
+		if _, err = imaging.DefaultWatermark(); err != nil {
+			fmt.Printf("Load watermark err: %v\n", err)
 			w.WriteHeader(500)
-			w.Write([]byte("Error al guardar la imagen principal"))
+			w.Write([]byte("La marca de agua no está disponible en el servidor"))
 			return
 		}
-		defer file.Close()
+	}
 
-		_, err = io.Copy(file, mainPic)
+	if watermark != property.Watermark {
+		err = db.UpdatePropertyWatermark(ctx, property.Id, watermark)
 		if err != nil {
-			fmt.Printf("Copy err: %v\n", err)
+			fmt.Printf("Update watermark err: %v\n", err)
 			w.WriteHeader(500)
-			w.Write([]byte("Error al guardar la imagen principal"))
+			w.Write([]byte("Error al actualizar la marca de agua"))
 			return
 		}
-		property.MainImg = mainFileName
-	}
+		property.Watermark = watermark
 
-	delPicIds := r.MultipartForm.Value["delPics"]
-	if len(delPicIds) > 0 {
-		newPics := [12]string{}
-		i := 0
-		for _, img := range property.Images {
-			if !slices.Contains(delPicIds, img) && img != "" {
-				newPics[i] = img
-				i++
+		if property.ImgVariants == nil {
+			property.ImgVariants = map[string]imaging.Variants{}
+		}
+		for _, img := range property.AllImages() {
+			variants, err := gallery.Render(ctx, property, img)
+			if err != nil {
+				fmt.Printf("Render picture %s err: %v\n", img, err)
+				continue
 			}
+			property.ImgVariants[img] = *variants
+		}
+
+		err = db.UpdatePropertyImgVariants(ctx, property.Id, property.ImgVariants)
+		if err != nil {
+			fmt.Printf("Update variants err: %v\n", err)
 		}
+	}
 
-		property.Images = newPics[:i]
+	err = components.UpdatePropImagesForm(property, true).Render(context.Background(), w)
+	if err != nil {
+		panic(err)
 	}
+}
 
-	err = db.UpdatePropertyImages(property)
-	err = db.UpdatePropertyMainImg(property.Id, property.MainImg)
+func UpdatePropertyPictureDetails(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("El formulario no pudo ser procesado"))
+		return
+	}
+
+	property, err := db.FindPropertyById(ctx, id)
+	if err != nil {
+		fmt.Printf("Find err: %v\n", err)
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la propiedad solicitada"))
+		return
+	}
+
+	details := property.ImageDetails(r.FormValue("img"))
+	if details == nil {
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la imagen solicitada"))
+		return
+	}
+
+	details.Caption = strings.TrimSpace(r.FormValue("caption"))
+	details.AltText = strings.TrimSpace(r.FormValue("altText"))
+	invalidFields := templates.InvalidFields{}
+	if len(details.Caption) > 256 {
+		invalidFields["caption"] = "La descripción no debe exceder 256 caracteres"
+	}
+	if len(details.AltText) > 256 {
+		invalidFields["altText"] = "El texto alternativo no debe exceder 256 caracteres"
+	}
+	details.Room, err = db.ParseRoomTag(r.FormValue("room"))
+	if err != nil {
+		invalidFields["room"] = "Seleccione un espacio válido"
+	}
+	if len(invalidFields) > 0 {
+		w.WriteHeader(400)
+		err = components.PropImageDetailsForm(property, details, invalidFields, false).Render(ctx, w)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
 
+	err = db.UpdatePropertyImageDetails(ctx, details)
 	if err != nil {
-		fmt.Printf("Write file err: %v\n", err)
+		fmt.Printf("Update image details err: %v\n", err)
 		w.WriteHeader(500)
-		w.Write([]byte("Error al procesar las imagenes"))
+		w.Write([]byte("Error al guardar los detalles de la imagen"))
 		return
 	}
 
-	err = components.UpdatePropImagesForm(property, true).Render(context.Background(), w)
+	err = components.PropImageDetailsForm(property, details, templates.InvalidFields{}, true).Render(ctx, w)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// respondWithPictureError writes the reason a picture was rejected
+func respondWithPictureError(w http.ResponseWriter, err error, fileName string) {
+	msg := pictureErrorMessage(err, fileName)
+	if msg == "" {
+		fmt.Printf("Read picture err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al procesar la imagen " + fileName))
+		return
+	}
+
+	w.WriteHeader(400)
+	w.Write([]byte(msg))
+}
+
 func DeletePropertyById(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 	id := r.PathValue("id")
 	err := db.DeletePropertyById(id)
@@ -424,6 +721,11 @@ func DeletePropertyById(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
+	if err = gallery.RemoveAll(r.Context(), id); err != nil {
+		fmt.Printf("Remove property media err: %v\n", err)
+	}
+
+	tiles.Invalidate(tiles.LayerProperties)
 	w.Header().Add("HX-Redirect", "/admin/propiedades")
 	w.WriteHeader(200)
 	w.Write([]byte("Propiedad eliminada"))
@@ -492,6 +794,26 @@ func FindPropertyWithNearbyProps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	nearbyPois, err := db.FindNearestPois(ctx, id, db.MaxPoiDistance)
+	if err != nil {
+		fmt.Printf("Find nearby pois err: %v\n", err)
+		nearbyPois = []*db.Poi{}
+	}
+
+	docs, err := db.FindPropertyDocuments(ctx, id, true)
+	if err != nil {
+		fmt.Printf("Find documents err: %v\n", err)
+	}
+	floorPlans := []*db.PropertyDocument{}
+	otherDocs := []*db.PropertyDocument{}
+	for _, doc := range docs {
+		if doc.Kind == db.DocumentPlano {
+			floorPlans = append(floorPlans, doc)
+		} else {
+			otherDocs = append(otherDocs, doc)
+		}
+	}
+
 	templ, err := template.New("layout.html").Funcs(template.FuncMap{
 		"FormatMoney": internal.FormatMoney,
 		"FormatDate":  internal.FormatDate,
@@ -512,6 +834,12 @@ func FindPropertyWithNearbyProps(w http.ResponseWriter, r *http.Request) {
 	err = templ.Execute(w, map[string]any{
 		"Prop":        prop,
 		"NearbyProps": nearbyProps,
+		"NearbyPois":  nearbyPois,
+		"FloorPlans":  floorPlans,
+		"Documents":   otherDocs,
+		// Checked by spam.Guard when the request form is sent
+		"FormToken":     spam.NewToken(time.Now()),
+		"PowDifficulty": spam.PowDifficulty(),
 	})
 
 	if err != nil {
@@ -572,6 +900,11 @@ func FindProperties(w http.ResponseWriter, r *http.Request) {
 	if intBaths, err := strconv.Atoi(baths); baths != "" && err == nil {
 		filter.Baths = &intBaths
 	}
+	for _, poi := range r.URL.Query()["poi"] {
+		if proximity, err := db.ParsePoiProximity(poi); err == nil {
+			filter.NearPois = append(filter.NearPois, proximity)
+		}
+	}
 
 	props, err := db.GetProperties(ctx, &filter, db.DefaultPageSize, page)
 
@@ -0,0 +1,308 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/sla"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func RegisterReportRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/reportes/solicitudes", auth.WithAuthMiddleware(RenderRequestsReport))
+	router.HandleFunc("GET /admin/reportes/sla", auth.WithAuthMiddleware(RenderSLAReport))
+	router.HandleFunc("GET /admin/reportes/origen", auth.WithAuthMiddleware(RenderSourcesReport))
+}
+
+// reportDays is the range of the reports when no dates are given
+const reportDays = 30
+
+// reportRange reads the desde and hasta dates of the query, the last
+// reportDays by default. The defaults are set on the query so the forms
+// show them.
+func reportRange(query url.Values) (from, to time.Time) {
+	now := time.Now().In(visits.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, visits.Location)
+
+	to, err := time.ParseInLocation(time.DateOnly, query.Get("hasta"), visits.Location)
+	if err != nil {
+		to = today
+		query.Set("hasta", to.Format(time.DateOnly))
+	}
+	from, err = time.ParseInLocation(time.DateOnly, query.Get("desde"), visits.Location)
+	if err != nil || from.After(to) {
+		from = to.AddDate(0, 0, -reportDays+1)
+		query.Set("desde", from.Format(time.DateOnly))
+	}
+
+	return from, to
+}
+
+// reportFilter reads the filters of the inbox from the query, the range of
+// the dates always set
+func reportFilter(query url.Values) *db.RequestFilter {
+	reportRange(query)
+	return parseRequestFilter(query)
+}
+
+// reportFilterOptions are the agents and properties of the filters form
+func reportFilterOptions(ctx context.Context) ([]*db.User, []*db.RequestProperty) {
+	agents, err := db.FindAgents(ctx)
+	if err != nil {
+		fmt.Printf("Find agents err: %v\n", err)
+		agents = []*db.User{}
+	}
+
+	properties, err := db.FindRequestProperties(ctx)
+	if err != nil {
+		fmt.Printf("Find request properties err: %v\n", err)
+		properties = []*db.RequestProperty{}
+	}
+
+	return agents, properties
+}
+
+// serveCSV sends the rows as a download named after the report and the
+// dates of the filters
+func serveCSV(w http.ResponseWriter, name string, query url.Values, rows [][]string) {
+	filename := fmt.Sprintf("%s_%s_%s.csv", name, query.Get("desde"), query.Get("hasta"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	// Excel reads the accents as latin-1 without the BOM
+	w.Write([]byte("\ufeff"))
+	cw := csv.NewWriter(w)
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = escapeCSVCell(cell)
+		}
+	}
+	cw.WriteAll(rows)
+	if err := cw.Error(); err != nil {
+		fmt.Printf("Write csv err: %v\n", err)
+	}
+}
+
+// csvFormulaPrefixes are the first characters of the cells the
+// spreadsheets read as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVCell quotes the cells that would be read as formulas, the names
+// and sources come from the site and could run in the spreadsheet of
+// whoever opens the report
+func escapeCSVCell(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(p, 'f', 1, 64)
+}
+
+// formatMinutes is a duration as whole minutes, for the spreadsheets
+func formatMinutes(d time.Duration) string {
+	return strconv.Itoa(int(d.Round(time.Minute).Minutes()))
+}
+
+// RenderRequestsReport is the volume of the requests by day or week, their
+// breakdown by type and status and how many got to be attended. The csv
+// query param downloads the volumen, embudo or desglose table.
+func RenderRequestsReport(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	if a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver los reportes"})
+		return
+	}
+
+	ctx := r.Context()
+	query := r.URL.Query()
+	filter := reportFilter(query)
+	period, err := db.ParseReportPeriod(query.Get("periodo"))
+	if err != nil {
+		period = db.ReportPeriodDay
+		query.Set("periodo", string(period))
+	}
+
+	volume, err := db.FindRequestVolume(ctx, filter, period, visits.Location)
+	if err != nil {
+		fmt.Printf("Find request volume err: %v\n", err)
+		volume = []*db.RequestVolume{}
+	}
+
+	funnel, err := db.FindRequestFunnel(ctx, filter)
+	if err != nil {
+		fmt.Printf("Find request funnel err: %v\n", err)
+		funnel = &db.Conversions{}
+	}
+
+	breakdown, err := db.FindRequestBreakdown(ctx, filter)
+	if err != nil {
+		fmt.Printf("Find request breakdown err: %v\n", err)
+		breakdown = []*db.RequestBreakdown{}
+	}
+
+	switch query.Get("csv") {
+	case "volumen":
+		rows := [][]string{{"Periodo", "Solicitudes", "Citas", "Confirmadas", "Atendidas"}}
+		for _, v := range volume {
+			rows = append(rows, []string{
+				v.Period.Format(time.DateOnly),
+				strconv.Itoa(v.Leads),
+				strconv.Itoa(v.Visits),
+				strconv.Itoa(v.Confirmed),
+				strconv.Itoa(v.Attended),
+			})
+		}
+		serveCSV(w, "solicitudes_"+string(period), query, rows)
+		return
+	case "embudo":
+		serveCSV(w, "embudo", query, [][]string{
+			{"Etapa", "Solicitudes", "Porcentaje"},
+			{db.RequestStatusPending.Label(), strconv.Itoa(funnel.Leads), formatPercent(100)},
+			{db.RequestStatusConfirmed.Label(), strconv.Itoa(funnel.Confirmed), formatPercent(funnel.ConfirmedPercent())},
+			{db.RequestStatusDone.Label(), strconv.Itoa(funnel.Attended), formatPercent(funnel.AttendedPercent())},
+		})
+		return
+	case "desglose":
+		rows := [][]string{{"Tipo", "Estado", "Solicitudes"}}
+		for _, b := range breakdown {
+			rows = append(rows, []string{b.Type.Label(), b.Status.Label(), strconv.Itoa(b.Count)})
+		}
+		serveCSV(w, "desglose", query, rows)
+		return
+	}
+
+	agents, properties := reportFilterOptions(ctx)
+	pages.AdminLayout(
+		pages.AdminRequestsReport(volume, funnel, breakdown, query, agents, properties),
+		a,
+		"Reporte de solicitudes | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func RenderSLAReport(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	if a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver los reportes"})
+		return
+	}
+
+	ctx := r.Context()
+	query := r.URL.Query()
+	requests, err := db.FindRequests(ctx, reportFilter(query), 0, 0)
+	if err != nil {
+		fmt.Printf("Find requests err: %v\n", err)
+		requests = []*db.Request{}
+	}
+	reports := sla.Report(requests, time.Now())
+
+	if query.Get("csv") != "" {
+		rows := [][]string{{
+			"Agente", "Solicitudes", "Atendidas", "Mediana (min)", "Promedio (min)", "Máximo (min)",
+			"Fuera de tiempo", "Recordatorios", "Escaladas",
+		}}
+		for _, report := range reports {
+			rows = append(rows, []string{
+				report.AgentName,
+				strconv.Itoa(report.Requests),
+				strconv.Itoa(report.Answered),
+				formatMinutes(report.MedianResponse),
+				formatMinutes(report.AverageResponse),
+				formatMinutes(report.MaxResponse),
+				strconv.Itoa(report.Breached),
+				strconv.Itoa(report.Reminded),
+				strconv.Itoa(report.Escalated),
+			})
+		}
+		serveCSV(w, "tiempo_de_respuesta", query, rows)
+		return
+	}
+
+	agents, properties := reportFilterOptions(ctx)
+	pages.AdminLayout(
+		pages.AdminSLA(reports, query, agents, properties, sla.RemindAfter(), sla.EscalateAfter()),
+		a,
+		"Tiempo de respuesta | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+// RenderSourcesReport credits the requests to the source and campaign of
+// the first visit of their clients. The csv query param downloads the
+// fuentes or propiedades table.
+func RenderSourcesReport(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	if a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver los reportes"})
+		return
+	}
+
+	ctx := r.Context()
+	query := r.URL.Query()
+	filter := reportFilter(query)
+
+	sources, err := db.FindSourceConversions(ctx, filter)
+	if err != nil {
+		fmt.Printf("Find source conversions err: %v\n", err)
+		sources = []*db.SourceConversions{}
+	}
+
+	properties, err := db.FindPropertyConversions(ctx, filter)
+	if err != nil {
+		fmt.Printf("Find property conversions err: %v\n", err)
+		properties = []*db.PropertyConversions{}
+	}
+
+	switch query.Get("csv") {
+	case "fuentes":
+		rows := [][]string{{"Fuente", "Medio", "Campaña", "Solicitudes", "Citas", "Confirmadas", "Atendidas", "Conversión (%)"}}
+		for _, s := range sources {
+			source := s.Source
+			if source == "" {
+				source = db.DirectSource
+			}
+			rows = append(rows, []string{
+				source,
+				s.Medium,
+				s.Campaign,
+				strconv.Itoa(s.Leads),
+				strconv.Itoa(s.Visits),
+				strconv.Itoa(s.Confirmed),
+				strconv.Itoa(s.Attended),
+				formatPercent(s.AttendedPercent()),
+			})
+		}
+		serveCSV(w, "origen", query, rows)
+		return
+	case "propiedades":
+		rows := [][]string{{"Propiedad", "Solicitudes", "Citas", "Confirmadas", "Atendidas", "Conversión (%)"}}
+		for _, p := range properties {
+			rows = append(rows, []string{
+				p.Address,
+				strconv.Itoa(p.Leads),
+				strconv.Itoa(p.Visits),
+				strconv.Itoa(p.Confirmed),
+				strconv.Itoa(p.Attended),
+				formatPercent(p.AttendedPercent()),
+			})
+		}
+		serveCSV(w, "origen_propiedades", query, rows)
+		return
+	}
+
+	agents, requestProperties := reportFilterOptions(ctx)
+	pages.AdminLayout(
+		pages.AdminSources(sources, properties, query, agents, requestProperties),
+		a,
+		"Origen de los clientes | Sibra Durango",
+	).Render(context.Background(), w)
+}
@@ -2,22 +2,239 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/attribution"
+	"github.com/vladwithcode/sibra-site/internal/auth"
 	"github.com/vladwithcode/sibra-site/internal/db"
-	"github.com/vladwithcode/sibra-site/internal/wsp"
+	"github.com/vladwithcode/sibra-site/internal/email"
+	"github.com/vladwithcode/sibra-site/internal/leads"
+	"github.com/vladwithcode/sibra-site/internal/notify"
+	"github.com/vladwithcode/sibra-site/internal/phone"
+	"github.com/vladwithcode/sibra-site/internal/spam"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/visits"
 )
 
 func RegisterRequestsRouter(r *customServeMux) {
 	r.HandleFunc("POST /api/requests", CreateRequest)
+
+	r.HandleFunc("GET /admin/solicitudes", auth.WithAuthMiddleware(RenderAdminRequests))
+	r.HandleFunc("PUT /api/requests/{id}/status", auth.WithAuthMiddleware(UpdateRequestStatus))
+}
+
+// requestsPerPage is the page size of the requests inbox
+const requestsPerPage = 25
+
+// parseRequestFilter reads the inbox filters from the query, values that
+// can't be parsed are ignored
+func parseRequestFilter(query url.Values) *db.RequestFilter {
+	filter := &db.RequestFilter{}
+
+	if t, err := db.ParseRequestType(query.Get("tipo")); err == nil {
+		filter.Type = &t
+	}
+	if status, err := db.ParseRequestStatus(query.Get("estado")); err == nil {
+		filter.Status = &status
+	}
+	if agent, err := uuid.Parse(query.Get("agente")); err == nil {
+		agentId := agent.String()
+		filter.Agent = &agentId
+	}
+	if property, err := uuid.Parse(query.Get("propiedad")); err == nil {
+		propertyId := property.String()
+		filter.Property = &propertyId
+	}
+	if from, err := time.ParseInLocation(time.DateOnly, query.Get("desde"), visits.Location); err == nil {
+		filter.CreatedFrom = &from
+	}
+	if to, err := time.ParseInLocation(time.DateOnly, query.Get("hasta"), visits.Location); err == nil {
+		filter.CreatedTo = &to
+	}
+
+	return filter
+}
+
+func RenderAdminRequests(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	filter := parseRequestFilter(query)
+	page, _ := strconv.Atoi(query.Get("pagina"))
+	if page < 1 {
+		page = 1
+	}
+	query.Del("pagina")
+
+	pagination, err := db.GetRequestsPagination(ctx, filter, requestsPerPage, page)
+	if err != nil {
+		fmt.Printf("Get requests pagination err: %v\n", err)
+		pagination = db.NewPagination(0, requestsPerPage, page)
+	}
+
+	requests, err := db.FindRequests(ctx, filter, requestsPerPage, page)
+	if err != nil {
+		fmt.Printf("Find requests err: %v\n", err)
+		requests = []*db.Request{}
+	}
+
+	agents, err := db.FindAgents(ctx)
+	if err != nil {
+		fmt.Printf("Find agents err: %v\n", err)
+		agents = []*db.User{}
+	}
+
+	properties, err := db.FindRequestProperties(ctx)
+	if err != nil {
+		fmt.Printf("Find request properties err: %v\n", err)
+		properties = []*db.RequestProperty{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminRequests(requests, pagination, query, agents, properties),
+		a,
+		"Solicitudes | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+// UpdateRequestStatus moves the request to the next status of its
+// workflow and renders its row of the inbox
+func UpdateRequestStatus(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+
+	req, err := db.FindRequestById(ctx, r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la solicitud"))
+		return
+	}
+
+	status, err := db.ParseRequestStatus(r.FormValue("status"))
+	if err != nil || !req.Status.CanMoveTo(status) {
+		w.WriteHeader(400)
+		components.RequestRow(req, "No se puede cambiar la solicitud a ese estado").Render(ctx, w)
+		return
+	}
+
+	err = db.UpdateRequestStatus(ctx, req, status)
+	if errors.Is(err, db.ErrRequestChanged) {
+		if current, err := db.FindRequestById(ctx, req.Id); err == nil {
+			req = current
+		}
+		w.WriteHeader(409)
+		components.RequestRow(req, "La solicitud cambió mientras tanto, revisa su estado").Render(ctx, w)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Update request err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al actualizar la solicitud"))
+		return
+	}
+
+	if req.Type == db.RequestTypeQuote && req.Status == db.RequestStatusConfirmed {
+		go func() {
+			ctx := context.Background()
+			agent, err := db.GetUserById(req.Agent)
+			if err != nil {
+				log.Printf("Could not find the agent of the visit (%s): %v", req.Id, err)
+			} else {
+				_, err = notify.Send(ctx, &notify.Notification{Event: notify.EventVisitConfirmed, Request: req, User: agent})
+				if err != nil {
+					log.Printf("Could not send the visit invite: %v", err)
+				}
+			}
+
+			if err := notify.SendClientInvite(ctx, req); err != nil {
+				log.Printf("Could not email the visit invite to the client: %v", err)
+			}
+		}()
+	}
+
+	err = components.RequestRow(req, "").Render(ctx, w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// assignVisit assigns the visit to the agent of the property if the slot
+// is still open
+func assignVisit(ctx context.Context, req *db.Request) (*db.User, error) {
+	if _, err := uuid.Parse(req.Property); err != nil {
+		return nil, visits.ErrSlotNotOpen
+	}
+
+	agent, err := leads.PropertyAgent(ctx, req.Property)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, visits.ErrSlotNotOpen
+	}
+
+	if err = visits.CheckSlot(ctx, agent.Id, req.ScheduledDate, time.Now()); err != nil {
+		return nil, err
+	}
+
+	leads.Assign(req, agent)
+	return agent, nil
+}
+
+// bookVisit assigns the visit to the agent of the property and creates it
+// if the slot is still open
+func bookVisit(ctx context.Context, req *db.Request) (*db.User, error) {
+	agent, err := assignVisit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent, db.CreateVisitRequest(ctx, req, visits.SlotDuration)
+}
+
+// notifyNewLead lets the agent, or the notification contacts when the
+// request has no agent, know of the new request and sends the receipt to
+// the client. The request is marked as sent once the agent got the
+// WhatsApp message.
+func notifyNewLead(req *db.Request, agent *db.User) {
+	ctx := context.Background()
+	if err := notify.SendReceipt(ctx, req); err != nil {
+		log.Printf("Could not email the receipt of the request (%s): %v", req.Id, err)
+	}
+
+	sent, err := notify.Send(ctx, &notify.Notification{Event: notify.EventNewLead, Request: req, User: agent})
+	if err != nil {
+		log.Printf("Could not notify the lead (%s): %v", req.Id, err)
+	}
+	if !slices.Contains(sent, notify.ChannelWhatsApp) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = db.MarkRequestWspSent(ctx, req.Id)
+	if err != nil {
+		log.Printf("Failed to mark request (%s) as sent: %v", req.Id, err)
+	}
 }
 
+// requestGuard keeps the rate limits of the request form
+var requestGuard = spam.NewGuard()
+
 func CreateRequest(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	templ, err := template.ParseFiles("web/templates/request-form.html")
@@ -27,11 +244,38 @@ func CreateRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now()
 	err = r.ParseForm()
 	if err != nil {
 		fmt.Printf("Parse Form err: %v\n", err)
 		templ.ExecuteTemplate(w, "request-form", map[string]any{
-			"Error": "El formulario contiene información inválida",
+			"Error":         "El formulario contiene información inválida",
+			"FormToken":     spam.NewToken(now),
+			"PowDifficulty": spam.PowDifficulty(),
+		})
+		return
+	}
+
+	// The token of the sent form is kept when the form is rendered again
+	// after an error, so the fill time counts from the first render
+	token := r.FormValue("form-token")
+	if _, err := spam.ParseToken(token); err != nil {
+		token = spam.NewToken(now)
+	}
+	renderForm := func(values map[string]any) error {
+		values["FormToken"] = token
+		values["PowDifficulty"] = spam.PowDifficulty()
+		return templ.ExecuteTemplate(w, "request-form", values)
+	}
+
+	ip := spam.ClientIP(r)
+	if !requestGuard.AllowIP(ctx, ip, now) {
+		fmt.Printf("Request rate limited for ip %s\n", ip)
+		w.WriteHeader(429)
+		renderForm(map[string]any{
+			"Error":        true,
+			"ErrorMessage": "Recibimos demasiadas solicitudes, inténtalo más tarde",
+			"Prop":         &db.Property{Id: r.FormValue("property")},
 		})
 		return
 	}
@@ -40,26 +284,33 @@ func CreateRequest(w http.ResponseWriter, r *http.Request) {
 	formIsInvalid := false
 
 	propId := r.FormValue("property")
-	phone := r.FormValue("phone")
-	if phone == "" {
+	phoneErr := ""
+	phoneE164, phoneDisplay, err := phone.Normalize(r.FormValue("phone"))
+	if err != nil {
 		invalidFields["phone"] = true
 		formIsInvalid = true
+		if errors.Is(err, phone.ErrInvalid) {
+			phoneErr = "Escribe un teléfono de 10 dígitos o con su lada internacional (+1 555 123 4567)"
+		}
 	}
 	name := r.FormValue("name")
 	if name == "" {
 		invalidFields["name"] = true
 		formIsInvalid = true
 	}
-	agent := r.FormValue("agent")
-	if agent == "" {
-		invalidFields["agent"] = true
+	clientEmail := strings.TrimSpace(r.FormValue("email"))
+	if clientEmail != "" && !email.ValidAddress(clientEmail) {
+		invalidFields["email"] = true
 		formIsInvalid = true
 	}
-	date := time.Now()
-	dateStr := r.FormValue("date")
-	if dateStr != "" {
-		date, err = time.Parse("2006-01-02 15:04", dateStr)
-
+	reqType, err := db.ParseRequestType(r.FormValue("type"))
+	if err != nil {
+		reqType = db.RequestTypeQuote
+	}
+	// Only the visits have a date, one of the open slots of the agent
+	var date time.Time
+	if reqType == db.RequestTypeQuote {
+		date, err = visits.ParseSlot(r.FormValue("date"))
 		if err != nil {
 			invalidFields["date"] = true
 			formIsInvalid = true
@@ -70,96 +321,107 @@ func CreateRequest(w http.ResponseWriter, r *http.Request) {
 	for k, v := range r.Form {
 		data[k] = v[0]
 	}
+	prop := &db.Property{Id: propId}
 	if formIsInvalid {
 		w.WriteHeader(400)
-		templ.ExecuteTemplate(w, "request-form", map[string]any{
-			"Invalid": invalidFields,
-			"Error":   true,
-			"Data":    data,
+		renderForm(map[string]any{
+			"Invalid":    invalidFields,
+			"Error":      true,
+			"PhoneError": phoneErr,
+			"Data":       data,
+			"Prop":       prop,
 		})
 		return
 	}
 
-	reqType := r.FormValue("type")
-	if reqType == "" {
-		reqType = string(db.RequestTypeQuote)
-	}
-	status := r.FormValue("status")
-	if status == "" {
-		status = string(db.RequestStatusPending)
-	}
-
 	id, _ := uuid.NewV7()
 	req := db.Request{
 		Id:            id.String(),
-		Type:          db.RequestType(reqType),
-		Phone:         phone,
+		Type:          reqType,
+		Phone:         phoneE164,
+		PhoneDisplay:  phoneDisplay,
 		Name:          name,
+		Email:         clientEmail,
 		ScheduledDate: date,
-		Status:        db.RequestStatus(status),
-		Agent:         agent,
+		Status:        db.RequestStatusPending,
 		Property:      propId,
 	}
+	req.Attribution, _ = attribution.Read(r)
+
+	reason, err := requestGuard.Check(ctx, &spam.Submission{
+		IP:       ip,
+		Phone:    phoneE164,
+		Honeypot: r.FormValue(spam.HoneypotField),
+		Token:    r.FormValue("form-token"),
+		Nonce:    r.FormValue("pow-nonce"),
+	}, now)
+	if err != nil {
+		fmt.Printf("Request rate limited for phone %s\n", phoneE164)
+		w.WriteHeader(429)
+		renderForm(map[string]any{
+			"Error":        true,
+			"ErrorMessage": "Ya recibimos tus solicitudes, pronto te contactará un asesor",
+			"Data":         data,
+			"Prop":         prop,
+		})
+		return
+	}
+	// The spam gets the same answer as the rest so it is not retried
+	if reason != "" {
+		req.Status = db.RequestStatusSpam
+		req.SpamReason = string(reason)
+		err = db.CreateRequest(ctx, &req)
+		if err != nil {
+			fmt.Printf("Create spam req err: %v\n", err)
+		}
 
-	if req.ScheduledDate.IsZero() == false {
-		req.Status = db.RequestStatusPending
+		token = spam.NewToken(now)
+		renderForm(map[string]any{
+			"Success": true,
+			"Prop":    prop,
+		})
+		return
 	}
 
-	err = db.CreateRequest(ctx, &req)
+	var agent *db.User
+	if reqType == db.RequestTypeQuote {
+		agent, err = bookVisit(ctx, &req)
+	} else {
+		agent, err = leads.Route(ctx, &req)
+		if err != nil {
+			fmt.Printf("Route request err: %v\n", err)
+		}
+		err = db.CreateRequest(ctx, &req)
+	}
 
 	if err != nil {
+		if errors.Is(err, visits.ErrSlotNotOpen) || errors.Is(err, db.ErrSlotTaken) {
+			w.WriteHeader(409)
+			renderForm(map[string]any{
+				"Invalid":   db.InvalidFields{"date": true},
+				"SlotError": "El horario seleccionado ya no está disponible, elige otro",
+				"Data":      data,
+				"Prop":      prop,
+			})
+			return
+		}
+
 		fmt.Printf("Create req err: %v\n", err)
 		w.WriteHeader(500)
-		templ.ExecuteTemplate(w, "request-form", map[string]any{
+		renderForm(map[string]any{
 			"Data":  data,
 			"Error": "Ocurrió un error al procesar la solicitud",
+			"Prop":  prop,
 		})
 		return
 	}
 
-	err = templ.ExecuteTemplate(w, "request-form", map[string]any{
+	token = spam.NewToken(now)
+	err = renderForm(map[string]any{
 		"Data":    data,
 		"Success": true,
+		"Prop":    prop,
 	})
 
-	go func() {
-		wspData := wsp.TemplateData{
-			TemplateName: "info_request",
-			BodyVars: []wsp.TemplateVar{
-				{
-					"type": "text",
-					"text": req.Name,
-				},
-				{
-					"type": "text",
-					"text": req.ScheduledDate.Format("02/01/2006 15:04"),
-				},
-				{
-					"type": "text",
-					"text": req.Phone,
-				},
-			},
-		}
-		notifPhone := os.Getenv(wsp.EnvVarNotificationPhone)
-		if notifPhone == "" {
-			log.Printf("Could not send whatsapp message. Notification phone envvar is not set %v", notifPhone)
-			return
-		}
-
-		err = wsp.SendTemplateMessage(notifPhone, wspData)
-		if err != nil {
-			log.Printf("Could not send whatsapp message: %v", err)
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		req.WspSent = true
-		err = db.UpdateRequest(ctx, &req)
-		if err != nil {
-			log.Printf("Failed to mark request (%s) as sent: %v", req.Id, err)
-			return
-		}
-	}()
+	go notifyNewLead(&req, agent)
 }
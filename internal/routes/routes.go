@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/attribution"
 	"github.com/vladwithcode/sibra-site/internal/auth"
 	"github.com/vladwithcode/sibra-site/internal/db"
 	"github.com/vladwithcode/sibra-site/internal/templates/pages"
@@ -25,6 +26,18 @@ func NewRouter() http.Handler {
 	RegisterAdminRoutes(router)
 	RegisterUserRoutes(router)
 	RegisterRequestsRouter(router)
+	RegisterHoodRoutes(router)
+	RegisterTileRoutes(router)
+	RegisterMarketRoutes(router)
+	RegisterMediaRoutes(router)
+	RegisterDocumentRoutes(router)
+	RegisterVisitRoutes(router)
+	RegisterCalendarRoutes(router)
+	RegisterContactRoutes(router)
+	RegisterActivityRoutes(router)
+	RegisterReportRoutes(router)
+	RegisterSpamRoutes(router)
+	RegisterPasswordRoutes(router)
 
 	// Signup/Signin
 	//router.HandleFunc("GET /registrarse", auth.CheckAuthMiddleware(RenderSignin))
@@ -38,7 +51,7 @@ func NewRouter() http.Handler {
 
 	router.NotFoundHandleFunc(auth.CheckAuthMiddleware(render404Page))
 
-	return router
+	return attribution.Middleware(router)
 }
 
 func RenderIndex(w http.ResponseWriter, r *http.Request) {
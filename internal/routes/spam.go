@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/leads"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func RegisterSpamRoutes(router *customServeMux) {
+	router.HandleFunc("GET /admin/solicitudes/spam", auth.WithAuthMiddleware(RenderAdminSpam))
+
+	router.HandleFunc("POST /api/requests/{id}/release", auth.WithAuthMiddleware(ReleaseSpamRequest))
+	router.HandleFunc("DELETE /api/requests/{id}", auth.WithAuthMiddleware(DeleteSpamRequest))
+}
+
+func RenderAdminSpam(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	if a.Role != db.RoleAdmin {
+		respondWithError(w, 403, ErrorParams{ErrorMessage: "No tienes permiso para ver las solicitudes en cuarentena"})
+		return
+	}
+
+	ctx := r.Context()
+	page, _ := strconv.Atoi(r.URL.Query().Get("pagina"))
+	if page < 1 {
+		page = 1
+	}
+
+	status := db.RequestStatusSpam
+	filter := &db.RequestFilter{Status: &status}
+	pagination, err := db.GetRequestsPagination(ctx, filter, requestsPerPage, page)
+	if err != nil {
+		fmt.Printf("Get spam pagination err: %v\n", err)
+		pagination = db.NewPagination(0, requestsPerPage, page)
+	}
+
+	requests, err := db.FindRequests(ctx, filter, requestsPerPage, page)
+	if err != nil {
+		fmt.Printf("Find spam err: %v\n", err)
+		requests = []*db.Request{}
+	}
+
+	pages.AdminLayout(
+		pages.AdminSpam(requests, pagination),
+		a,
+		"Cuarentena | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+// findSpamRequest returns the quarantined request of the path, it writes
+// the error response when there is none
+func findSpamRequest(w http.ResponseWriter, r *http.Request, a *auth.Auth) *db.Request {
+	if a.Role != db.RoleAdmin {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para modificar la solicitud"))
+		return nil
+	}
+
+	req, err := db.FindRequestById(r.Context(), r.PathValue("id"))
+	if err != nil || req.Status != db.RequestStatusSpam {
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("Find request err: %v\n", err)
+		}
+		w.WriteHeader(404)
+		w.Write([]byte("No se encontró la solicitud en cuarentena"))
+		return nil
+	}
+
+	return req
+}
+
+// ReleaseSpamRequest moves the quarantined request to pending, assigns it
+// and notifies the agent like a new request. The visits are booked like
+// new ones, the release fails when the slot is no longer open.
+func ReleaseSpamRequest(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	req := findSpamRequest(w, r, a)
+	if req == nil {
+		return
+	}
+
+	var agent *db.User
+	var err error
+	if req.Type == db.RequestTypeQuote {
+		agent, err = assignVisit(ctx, req)
+		if err == nil {
+			err = db.ReleaseSpamRequest(ctx, req, visits.SlotDuration)
+		}
+	} else {
+		agent, err = leads.Route(ctx, req)
+		if err != nil {
+			fmt.Printf("Route request err: %v\n", err)
+		}
+		err = db.ReleaseSpamRequest(ctx, req, 0)
+	}
+
+	if err != nil {
+		if errors.Is(err, visits.ErrSlotNotOpen) || errors.Is(err, db.ErrSlotTaken) {
+			w.WriteHeader(409)
+			components.SpamRow(req, "El horario de la visita ya no está disponible, no se puede liberar la solicitud").Render(ctx, w)
+			return
+		}
+
+		fmt.Printf("Release request err: %v\n", err)
+		w.WriteHeader(500)
+		components.SpamRow(req, "Ocurrió un error al liberar la solicitud").Render(ctx, w)
+		return
+	}
+
+	go notifyNewLead(req, agent)
+
+	w.WriteHeader(200)
+}
+
+func DeleteSpamRequest(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	req := findSpamRequest(w, r, a)
+	if req == nil {
+		return
+	}
+
+	err := db.DeleteSpamRequest(ctx, req.Id)
+	if err != nil {
+		fmt.Printf("Delete request err: %v\n", err)
+		w.WriteHeader(500)
+		components.SpamRow(req, "Ocurrió un error al eliminar la solicitud").Render(ctx, w)
+		return
+	}
+
+	w.WriteHeader(200)
+}
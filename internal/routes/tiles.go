@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/tiles"
+)
+
+func RegisterTileRoutes(router *customServeMux) {
+	// ServeMux wildcards must take a full segment, so the ".mvt" extension
+	// is stripped from {y} by the handler
+	router.HandleFunc("GET /tiles/{layer}/{z}/{x}/{y}", ServeTile)
+}
+
+func ServeTile(w http.ResponseWriter, r *http.Request) {
+	layer := r.PathValue("layer")
+	if !tiles.IsValidLayer(layer) {
+		w.WriteHeader(404)
+		w.Write([]byte("Capa no encontrada"))
+		return
+	}
+
+	yStr, hasExt := strings.CutSuffix(r.PathValue("y"), ".mvt")
+	z, zErr := strconv.Atoi(r.PathValue("z"))
+	x, xErr := strconv.Atoi(r.PathValue("x"))
+	y, yErr := strconv.Atoi(yStr)
+	if !hasExt || zErr != nil || xErr != nil || yErr != nil || tiles.ValidateCoords(z, x, y) != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Coordenadas de tile inválidas"))
+		return
+	}
+
+	tile, ok := tiles.Get(layer, z, x, y)
+	if !ok {
+		var err error
+		switch layer {
+		case tiles.LayerProperties:
+			tile, err = db.FindPropertiesTile(r.Context(), z, x, y)
+		case tiles.LayerHoods:
+			tile, err = db.FindHoodsTile(r.Context(), z, x, y)
+		}
+
+		if err != nil {
+			fmt.Printf("Build tile err: %v\n", err)
+			w.WriteHeader(500)
+			return
+		}
+
+		if err = tiles.Put(layer, z, x, y, tile); err != nil {
+			fmt.Printf("Cache tile err: %v\n", err)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if len(tile) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	w.Header().Set("Content-Length", strconv.Itoa(len(tile)))
+	w.Write(tile)
+}
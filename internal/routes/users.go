@@ -5,16 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
 	"github.com/vladwithcode/sibra-site/internal"
 	"github.com/vladwithcode/sibra-site/internal/auth"
 	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/imaging"
+	"github.com/vladwithcode/sibra-site/internal/notify"
+	"github.com/vladwithcode/sibra-site/internal/phone"
+	"github.com/vladwithcode/sibra-site/internal/storage"
 )
 
 func RegisterUserRoutes(router *customServeMux) {
@@ -23,6 +24,7 @@ func RegisterUserRoutes(router *customServeMux) {
 	router.HandleFunc("PUT /api/users/{id}/password", auth.WithAuthMiddleware(UpdatePassword))
 	router.HandleFunc("PUT /api/users/{id}/pic", auth.WithAuthMiddleware(UpdateUserPicture))
 	router.HandleFunc("DELETE /api/users/{id}/pic", auth.WithAuthMiddleware(DeleteUserPicture))
+	router.HandleFunc("PUT /api/users/{id}/notifications", auth.WithAuthMiddleware(UpdateNotificationPreferences))
 }
 
 func CreateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
@@ -43,8 +45,18 @@ func CreateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 	id, _ := uuid.NewV7()
 	data.Id = id.String()
 
-	phone := sql.NullString{}
-	phone.String = data.Phone
+	phoneNumber := sql.NullString{}
+	phoneDisplay := ""
+	if data.Phone != "" {
+		phoneNumber.String, phoneDisplay, err = phone.Normalize(data.Phone)
+		if err != nil {
+			respondWithError(w, 400, ErrorParams{
+				ErrorMessage: "El teléfono es inválido",
+			})
+			return
+		}
+		phoneNumber.Valid = true
+	}
 
 	/*
 		if a.Role != db.RoleAdmin {
@@ -52,14 +64,15 @@ func CreateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		} */
 
 	_, err = db.CreateUser(&db.User{
-		Id:       data.Id,
-		Name:     data.Name,
-		Lastname: data.Lastname,
-		Password: data.Password,
-		Phone:    phone,
-		Role:     data.Role,
-		Username: data.Username,
-		Email:    data.Email,
+		Id:           data.Id,
+		Name:         data.Name,
+		Lastname:     data.Lastname,
+		Password:     data.Password,
+		Phone:        phoneNumber,
+		PhoneDisplay: phoneDisplay,
+		Role:         data.Role,
+		Username:     data.Username,
+		Email:        data.Email,
 	})
 
 	if err != nil {
@@ -99,7 +112,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 
 	name := r.FormValue("name")
 	lastname := r.FormValue("lastname")
-	phone := r.FormValue("phone")
+	phoneValue := r.FormValue("phone")
 	email := r.FormValue("email")
 
 	if err != nil {
@@ -115,7 +128,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 				"name":     name,
 				"lastname": lastname,
 				"email":    email,
-				"phone":    phone,
+				"phone":    phoneValue,
 			},
 		})
 
@@ -138,6 +151,14 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		invalid["Email"] = true
 		isFormInvalid = true
 	}
+	phoneE164, phoneDisplay := "", ""
+	if phoneValue != "" {
+		phoneE164, phoneDisplay, err = phone.Normalize(phoneValue)
+		if err != nil {
+			invalid["Phone"] = true
+			isFormInvalid = true
+		}
+	}
 
 	if isFormInvalid {
 		w.WriteHeader(400)
@@ -148,7 +169,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 				"name":     name,
 				"lastname": lastname,
 				"email":    email,
-				"phone":    phone,
+				"phone":    phoneValue,
 			},
 		})
 		return
@@ -156,8 +177,9 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 
 	user.Name = name
 	user.Lastname = lastname
-	user.Phone.String = phone
-	user.Phone.Valid = phone != ""
+	user.Phone.String = phoneE164
+	user.Phone.Valid = phoneE164 != ""
+	user.PhoneDisplay = phoneDisplay
 	user.Email = email
 
 	err = db.UpdateUser(user)
@@ -175,7 +197,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 				"name":     name,
 				"lastname": lastname,
 				"email":    email,
-				"phone":    phone,
+				"phone":    phoneValue,
 			},
 		})
 		return
@@ -350,41 +372,50 @@ func UpdateUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
-	mainPic, handle, err := r.FormFile("picture")
+	r.Body = http.MaxBytesReader(w, r.Body, imaging.MaxFileSize+(1<<20))
+	_, handle, err := r.FormFile("picture")
 	if err != nil {
 		fmt.Printf("Parse pic err: %v\n", err)
-		w.WriteHeader(500)
+		msg := pictureErrorMessage(err, "")
+		if msg == "" {
+			msg = "Error al procesar la imagen"
+		}
+		w.WriteHeader(400)
 		templ.ExecuteTemplate(w, "edit-pic-form", map[string]any{
 			"Error":        true,
-			"ErrorMessage": "Error al procesar la imagen",
+			"ErrorMessage": msg,
 			"User": map[string]any{
 				"Id": id,
 			},
 		})
 		return
 	}
-	defer mainPic.Close()
 
-	filePath := "web/static/users"
-	mainFileName := user.Id + filepath.Ext(handle.Filename)
-	file, err := os.Create(filepath.Join(filePath, mainFileName))
+	upload, err := readUploadedPicture(handle)
 	if err != nil {
-		fmt.Printf("Create main file %v\n", err)
-		w.WriteHeader(500)
+		fmt.Printf("Read pic err: %v\n", err)
+		msg := pictureErrorMessage(err, handle.Filename)
+		if msg == "" {
+			msg = "Error al procesar la imagen"
+			w.WriteHeader(500)
+		} else {
+			w.WriteHeader(400)
+		}
 		templ.ExecuteTemplate(w, "edit-pic-form", map[string]any{
 			"Error":        true,
-			"ErrorMessage": "Error al procesar la imagen",
+			"ErrorMessage": msg,
 			"User": map[string]any{
 				"Id": id,
 			},
 		})
 		return
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, mainPic)
+	// A new key on every upload, the old picture is kept until the user
+	// points to the new one and the browsers don't show the cached one
+	mainFileName, err := storePicture(r.Context(), upload, "users", user.Id+"/"+uuid.Must(uuid.NewV7()).String())
 	if err != nil {
-		fmt.Printf("Copy err: %v\n", err)
+		fmt.Printf("Store picture err: %v\n", err)
 		w.WriteHeader(500)
 		templ.ExecuteTemplate(w, "edit-pic-form", map[string]any{
 			"Error":        true,
@@ -395,12 +426,17 @@ func UpdateUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		})
 		return
 	}
+	oldImg := user.Img
 	user.Img = mainFileName
 
 	err = db.UpdateUser(user)
 
 	if err != nil {
 		fmt.Printf("Update user err: %v\n", err)
+		err = storage.Media.Delete(r.Context(), storage.Key("users", mainFileName))
+		if err != nil {
+			fmt.Printf("Delete new picture err: %v\n", err)
+		}
 		w.WriteHeader(500)
 		templ.ExecuteTemplate(w, "edit-pic-form", map[string]any{
 			"Error":        true,
@@ -412,6 +448,13 @@ func UpdateUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
+	if oldImg != "" {
+		err = storage.Media.Delete(r.Context(), storage.Key("users", oldImg))
+		if err != nil {
+			fmt.Printf("Delete old picture err: %v\n", err)
+		}
+	}
+
 	w.WriteHeader(201)
 	templ.ExecuteTemplate(w, "edit-pic-form", map[string]any{
 		"Success": true,
@@ -451,24 +494,7 @@ func DeleteUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
-	fileName := filepath.Join("web/static/users", user.Img)
-	err = os.Remove(fileName)
-
-	if err != nil {
-		fmt.Printf("Get err: %v\n", err)
-		w.WriteHeader(500)
-		templ.ExecuteTemplate(w, "del-pic-form", map[string]any{
-			"Error":        true,
-			"ErrorMessage": "Error al eliminar la imagen",
-		})
-
-		if err != nil {
-			fmt.Printf("Exec error templ err: %v\n", err)
-			respondWithError(w, 500, ErrorParams{})
-			return
-		}
-		return
-	}
+	oldImg := user.Img
 	user.Img = ""
 
 	err = db.UpdateUser(user)
@@ -483,6 +509,14 @@ func DeleteUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 
+	// The picture is removed once the user no longer points to it
+	if oldImg != "" {
+		err = storage.Media.Delete(r.Context(), storage.Key("users", oldImg))
+		if err != nil {
+			fmt.Printf("Delete picture err: %v\n", err)
+		}
+	}
+
 	w.WriteHeader(201)
 	err = templ.ExecuteTemplate(w, "del-pic-form", map[string]any{
 		"Success": true,
@@ -495,3 +529,74 @@ func DeleteUserPicture(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
 		return
 	}
 }
+
+// UpdateNotificationPreferences sets the channels the user gets each event
+// by, the form has a checkbox per event and channel. Only the user and the
+// admins can change them.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	templ, err := template.New("user.html").Funcs(template.FuncMap{
+		"PrintRole": internal.PrintRole,
+	}).ParseFiles(
+		"web/templates/admin/user.html",
+	)
+
+	if err != nil {
+		fmt.Printf("Parse templ err: %v\n", err)
+		respondWithError(w, 500, ErrorParams{})
+		return
+	}
+
+	id := r.PathValue("id")
+	data := map[string]any{
+		"User": map[string]any{
+			"Id": id,
+		},
+		"Events":   notify.Events,
+		"Channels": notify.Channels,
+	}
+
+	if a.Id != id && a.Role != db.RoleAdmin {
+		w.WriteHeader(403)
+		data["Error"] = true
+		data["ErrorMessage"] = "No puedes cambiar las notificaciones de otro usuario"
+		data["Preferences"] = notify.DefaultPreferences()
+		templ.ExecuteTemplate(w, "notifications-form", data)
+		return
+	}
+
+	err = r.ParseForm()
+	prefs := notify.Preferences{}
+	for _, event := range notify.Events {
+		prefs[event] = []notify.Channel{}
+		for _, value := range r.Form[string(event)] {
+			channel, parseErr := notify.ParseChannel(value)
+			if parseErr != nil {
+				err = parseErr
+				continue
+			}
+			prefs[event] = append(prefs[event], channel)
+		}
+	}
+	data["Preferences"] = prefs
+
+	if err != nil {
+		fmt.Printf("Parse notification preferences err: %v\n", err)
+		w.WriteHeader(400)
+		data["Error"] = true
+		data["ErrorMessage"] = "El formulario contiene información inválida"
+		templ.ExecuteTemplate(w, "notifications-form", data)
+		return
+	}
+
+	err = notify.SavePreferences(r.Context(), id, prefs)
+	if err != nil {
+		fmt.Printf("Save notification preferences err: %v\n", err)
+		w.WriteHeader(500)
+		data["Error"] = true
+		templ.ExecuteTemplate(w, "notifications-form", data)
+		return
+	}
+
+	data["Success"] = true
+	templ.ExecuteTemplate(w, "notifications-form", data)
+}
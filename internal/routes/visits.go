@@ -0,0 +1,236 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/sibra-site/internal/auth"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/leads"
+	"github.com/vladwithcode/sibra-site/internal/templates/components"
+	"github.com/vladwithcode/sibra-site/internal/templates/pages"
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func RegisterVisitRoutes(router *customServeMux) {
+	router.HandleFunc("GET /api/property/{id}/slots", FindPropertyVisitSlots)
+
+	router.HandleFunc("GET /admin/disponibilidad", auth.WithAuthMiddleware(RenderAgentAvailability))
+	router.HandleFunc("POST /api/agents/{id}/availability", auth.WithAuthMiddleware(CreateAgentAvailability))
+	router.HandleFunc("DELETE /api/agents/{id}/availability/{availId}", auth.WithAuthMiddleware(DeleteAgentAvailability))
+	router.HandleFunc("POST /api/agents/{id}/blocked-dates", auth.WithAuthMiddleware(CreateAgentBlockedDate))
+	router.HandleFunc("DELETE /api/agents/{id}/blocked-dates/{blockId}", auth.WithAuthMiddleware(DeleteAgentBlockedDate))
+}
+
+// FindPropertyVisitSlots renders the open visit slots of the agent of the
+// property as the options of the request form
+func FindPropertyVisitSlots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	days := []*visits.Day{}
+
+	agent, err := leads.PropertyAgent(ctx, r.PathValue("id"))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("Find property agent err: %v\n", err)
+	}
+	if agent != nil {
+		days, err = visits.OpenSlots(ctx, agent.Id, time.Now(), visits.BookingDays)
+		if err != nil {
+			fmt.Printf("Find open slots err: %v\n", err)
+			days = []*visits.Day{}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = components.VisitSlotOptions(days).Render(ctx, w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// canManageAgent tells if the user can change the schedule of the agent,
+// only admins can change the schedule of someone else
+func canManageAgent(a *auth.Auth, agentId string) bool {
+	return a.Id == agentId || a.Role == db.RoleAdmin
+}
+
+func RenderAgentAvailability(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	ctx := r.Context()
+	agentId := a.Id
+	if id := r.URL.Query().Get("agente"); id != "" && canManageAgent(a, id) {
+		agentId = id
+	}
+
+	agent, err := db.GetUserById(agentId)
+	if err != nil {
+		fmt.Printf("Find agent err: %v\n", err)
+		respondWithError(w, 404, ErrorParams{ErrorMessage: "No se encontró el agente"})
+		return
+	}
+
+	var agents []*db.User
+	if a.Role == db.RoleAdmin {
+		agents, err = db.FindAgents(ctx)
+		if err != nil {
+			fmt.Printf("Find agents err: %v\n", err)
+		}
+	}
+
+	pages.AdminLayout(
+		pages.AdminAvailability(
+			agent,
+			agents,
+			calendarComponent(ctx, r, agent.Id),
+			availabilityComponent(ctx, agent.Id, ""),
+		),
+		a,
+		"Disponibilidad | Sibra Durango",
+	).Render(context.Background(), w)
+}
+
+func availabilityComponent(ctx context.Context, agentId, errMsg string) templ.Component {
+	availability, err := db.FindAgentAvailability(ctx, agentId)
+	if err != nil {
+		fmt.Printf("Find availability err: %v\n", err)
+		availability = []*db.Availability{}
+	}
+
+	blocked, err := db.FindBlockedDates(ctx, agentId, time.Now().In(visits.Location))
+	if err != nil {
+		fmt.Printf("Find blocked dates err: %v\n", err)
+		blocked = []*db.BlockedDate{}
+	}
+
+	return components.AgentAvailability(agentId, availability, blocked, errMsg)
+}
+
+func respondWithAvailability(w http.ResponseWriter, r *http.Request, agentId, errMsg string, status int) {
+	w.WriteHeader(status)
+	err := availabilityComponent(r.Context(), agentId, errMsg).Render(r.Context(), w)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func CreateAgentAvailability(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	agentId := r.PathValue("id")
+	if !canManageAgent(a, agentId) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar esta disponibilidad"))
+		return
+	}
+
+	weekday, err := strconv.Atoi(r.FormValue("weekday"))
+	if err != nil || weekday < 0 || weekday > 6 {
+		respondWithAvailability(w, r, agentId, "Seleccione un día válido", 400)
+		return
+	}
+	start, err := visits.ParseMinute(r.FormValue("start"))
+	if err != nil {
+		respondWithAvailability(w, r, agentId, "La hora de inicio no es válida", 400)
+		return
+	}
+	end, err := visits.ParseMinute(r.FormValue("end"))
+	if err != nil {
+		respondWithAvailability(w, r, agentId, "La hora de fin no es válida", 400)
+		return
+	}
+
+	err = db.CreateAvailability(r.Context(), &db.Availability{
+		Agent:       agentId,
+		Weekday:     time.Weekday(weekday),
+		StartMinute: start,
+		EndMinute:   end,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidAvailability) {
+			respondWithAvailability(w, r, agentId, "La hora de inicio debe ser anterior a la hora de fin", 400)
+			return
+		}
+
+		fmt.Printf("Create availability err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al guardar la disponibilidad"))
+		return
+	}
+
+	respondWithAvailability(w, r, agentId, "", 200)
+}
+
+func DeleteAgentAvailability(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	agentId := r.PathValue("id")
+	if !canManageAgent(a, agentId) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar esta disponibilidad"))
+		return
+	}
+
+	err := db.DeleteAvailability(r.Context(), r.PathValue("availId"), agentId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("Delete availability err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al eliminar la disponibilidad"))
+		return
+	}
+
+	respondWithAvailability(w, r, agentId, "", 200)
+}
+
+func CreateAgentBlockedDate(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	agentId := r.PathValue("id")
+	if !canManageAgent(a, agentId) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar esta disponibilidad"))
+		return
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, r.FormValue("date"), visits.Location)
+	if err != nil {
+		respondWithAvailability(w, r, agentId, "Seleccione una fecha válida", 400)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if len(reason) > 256 {
+		respondWithAvailability(w, r, agentId, "El motivo no debe exceder 256 caracteres", 400)
+		return
+	}
+
+	err = db.CreateBlockedDate(r.Context(), &db.BlockedDate{
+		Agent:  agentId,
+		Date:   date,
+		Reason: reason,
+	})
+	if err != nil {
+		fmt.Printf("Create blocked date err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al bloquear la fecha"))
+		return
+	}
+
+	respondWithAvailability(w, r, agentId, "", 200)
+}
+
+func DeleteAgentBlockedDate(w http.ResponseWriter, r *http.Request, a *auth.Auth) {
+	agentId := r.PathValue("id")
+	if !canManageAgent(a, agentId) {
+		w.WriteHeader(403)
+		w.Write([]byte("No tienes permiso para cambiar esta disponibilidad"))
+		return
+	}
+
+	err := db.DeleteBlockedDate(r.Context(), r.PathValue("blockId"), agentId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("Delete blocked date err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error al eliminar la fecha"))
+		return
+	}
+
+	respondWithAvailability(w, r, agentId, "", 200)
+}
@@ -0,0 +1,168 @@
+package sla
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+// Days off besides the official holidays, as comma separated dates (e.g.
+// 2026-12-12,2026-12-24)
+const EnvVarExtraHolidays = "SLA_EXTRA_HOLIDAYS"
+
+// BusinessHours are the opening hours of each weekday in minutes from
+// midnight of visits.Location, the days left out are closed
+var BusinessHours = map[time.Weekday][2]int{
+	time.Monday:    {9 * 60, 19 * 60},
+	time.Tuesday:   {9 * 60, 19 * 60},
+	time.Wednesday: {9 * 60, 19 * 60},
+	time.Thursday:  {9 * 60, 19 * 60},
+	time.Friday:    {9 * 60, 19 * 60},
+	time.Saturday:  {9 * 60, 14 * 60},
+}
+
+// nthWeekday returns the nth weekday of the month, e.g. the third monday
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, visits.Location)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// Holidays are the mandatory days off of the year by the Ley Federal del
+// Trabajo (art. 74)
+func Holidays(year int) []time.Time {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, visits.Location)
+	}
+
+	holidays := []time.Time{
+		date(time.January, 1),
+		nthWeekday(year, time.February, time.Monday, 1),
+		nthWeekday(year, time.March, time.Monday, 3),
+		date(time.May, 1),
+		date(time.September, 16),
+		nthWeekday(year, time.November, time.Monday, 3),
+		date(time.December, 25),
+	}
+
+	// The day the federal executive power is transmitted, every six years.
+	// It moved from december 1 to october 1 in 2024.
+	if year >= 2024 && (year-2024)%6 == 0 {
+		holidays = append(holidays, date(time.October, 1))
+	} else if year < 2024 && (2024-year)%6 == 0 {
+		holidays = append(holidays, date(time.December, 1))
+	}
+
+	return holidays
+}
+
+func extraHolidays() map[string]bool {
+	days := map[string]bool{}
+	value := os.Getenv(EnvVarExtraHolidays)
+	if value == "" {
+		return days
+	}
+
+	for _, s := range strings.Split(value, ",") {
+		d, err := time.Parse(time.DateOnly, strings.TrimSpace(s))
+		if err != nil {
+			log.Printf("invalid date %q in %s\n", s, EnvVarExtraHolidays)
+			continue
+		}
+		days[d.Format(time.DateOnly)] = true
+	}
+
+	return days
+}
+
+// Calendar tells the business time between two moments, leaving out the
+// closed hours, the holidays and the extra days off
+type Calendar struct {
+	holidays map[string]bool
+}
+
+func NewCalendar() *Calendar {
+	return &Calendar{holidays: extraHolidays()}
+}
+
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	t = t.In(visits.Location)
+	if c.holidays[t.Format(time.DateOnly)] {
+		return true
+	}
+
+	for _, h := range Holidays(t.Year()) {
+		if h.Year() == t.Year() && h.YearDay() == t.YearDay() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hours returns when the business opens and closes on the day of t,
+// ok is false when it is closed all day
+func (c *Calendar) hours(t time.Time) (open, close time.Time, ok bool) {
+	t = t.In(visits.Location)
+	h, found := BusinessHours[t.Weekday()]
+	if !found || c.IsHoliday(t) {
+		return
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, visits.Location)
+	return midnight.Add(time.Duration(h[0]) * time.Minute), midnight.Add(time.Duration(h[1]) * time.Minute), true
+}
+
+func nextMidnight(t time.Time) time.Time {
+	t = t.In(visits.Location)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, visits.Location)
+}
+
+// maxDays bounds Add in case the calendar has no business hours
+const maxDays = 366
+
+// Add returns when d of business time has passed since from
+func (c *Calendar) Add(from time.Time, d time.Duration) time.Time {
+	t := from
+	for range maxDays {
+		open, close, ok := c.hours(t)
+		if ok && t.Before(close) {
+			if t.Before(open) {
+				t = open
+			}
+			left := close.Sub(t)
+			if d <= left {
+				return t.Add(d)
+			}
+			d -= left
+		}
+		t = nextMidnight(t)
+	}
+
+	return t
+}
+
+// Between returns the business time from from to to
+func (c *Calendar) Between(from, to time.Time) time.Duration {
+	var total time.Duration
+	for t := from; t.Before(to); t = nextMidnight(t) {
+		open, close, ok := c.hours(t)
+		if ok {
+			start, end := t, to
+			if start.Before(open) {
+				start = open
+			}
+			if end.After(close) {
+				end = close
+			}
+			if end.After(start) {
+				total += end.Sub(start)
+			}
+		}
+	}
+
+	return total
+}
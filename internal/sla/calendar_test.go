@@ -0,0 +1,223 @@
+package sla
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/visits"
+)
+
+func at(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, visits.Location)
+}
+
+func TestHolidays(t *testing.T) {
+	want := []string{
+		"2026-01-01",
+		// The first monday of february and the third of march and
+		// november, not the dates they remember
+		"2026-02-02",
+		"2026-03-16",
+		"2026-05-01",
+		"2026-09-16",
+		"2026-11-16",
+		"2026-12-25",
+	}
+
+	got := Holidays(2026)
+	if len(got) != len(want) {
+		t.Fatalf("Holidays(2026) has %d days, want %d", len(got), len(want))
+	}
+	for i, h := range got {
+		if h.Format(time.DateOnly) != want[i] {
+			t.Errorf("Holidays(2026)[%d] = %s, want %s", i, h.Format(time.DateOnly), want[i])
+		}
+	}
+
+	// The transmission of the executive power
+	tests := []struct {
+		year int
+		day  string
+	}{
+		{2024, "2024-10-01"},
+		{2030, "2030-10-01"},
+		{2018, "2018-12-01"},
+	}
+	for _, tt := range tests {
+		days := Holidays(tt.year)
+		if last := days[len(days)-1].Format(time.DateOnly); last != tt.day {
+			t.Errorf("Holidays(%d) ends with %s, want %s", tt.year, last, tt.day)
+		}
+	}
+}
+
+func TestIsHoliday(t *testing.T) {
+	t.Setenv(EnvVarExtraHolidays, "2026-12-12, 2026-12-24")
+	c := NewCalendar()
+
+	tests := []struct {
+		day  time.Time
+		want bool
+	}{
+		{at(2026, time.February, 2, 12, 0), true},
+		{at(2026, time.February, 5, 12, 0), false},
+		{at(2026, time.March, 16, 12, 0), true},
+		{at(2026, time.March, 21, 12, 0), false},
+		{at(2026, time.November, 16, 12, 0), true},
+		{at(2026, time.November, 20, 12, 0), false},
+		{at(2026, time.December, 12, 12, 0), true},
+		{at(2026, time.December, 24, 12, 0), true},
+		{at(2026, time.October, 1, 12, 0), false},
+		// 00:30 of january 1 in Mexico City is still december 31 in UTC
+		{time.Date(2026, time.January, 1, 6, 30, 0, 0, time.UTC), true},
+		{time.Date(2026, time.January, 1, 5, 30, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		if got := c.IsHoliday(tt.day); got != tt.want {
+			t.Errorf("IsHoliday(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	t.Setenv(EnvVarExtraHolidays, "")
+	c := NewCalendar()
+
+	tests := []struct {
+		name string
+		from time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{
+			"within the day",
+			at(2026, time.October, 20, 10, 0), 2 * time.Hour,
+			at(2026, time.October, 20, 12, 0),
+		},
+		{
+			"until closing",
+			at(2026, time.October, 20, 18, 0), time.Hour,
+			at(2026, time.October, 20, 19, 0),
+		},
+		{
+			"to the next day",
+			at(2026, time.October, 20, 18, 0), 2 * time.Hour,
+			at(2026, time.October, 21, 10, 0),
+		},
+		{
+			"before opening",
+			at(2026, time.October, 20, 7, 0), 30 * time.Minute,
+			at(2026, time.October, 20, 9, 30),
+		},
+		{
+			"after hours",
+			at(2026, time.October, 20, 21, 0), time.Hour,
+			at(2026, time.October, 21, 10, 0),
+		},
+		{
+			"saturday closes early",
+			at(2026, time.October, 17, 13, 0), 30 * time.Minute,
+			at(2026, time.October, 17, 13, 30),
+		},
+		{
+			"across the weekend",
+			at(2026, time.October, 17, 13, 0), 2 * time.Hour,
+			at(2026, time.October, 19, 10, 0),
+		},
+		{
+			"from sunday",
+			at(2026, time.October, 18, 12, 0), time.Hour,
+			at(2026, time.October, 19, 10, 0),
+		},
+		{
+			"across a monday holiday",
+			at(2026, time.March, 14, 13, 30), time.Hour,
+			at(2026, time.March, 17, 9, 30),
+		},
+		{
+			"after hours before a holiday",
+			at(2026, time.September, 15, 20, 0), time.Hour,
+			at(2026, time.September, 17, 10, 0),
+		},
+		{
+			"across the new year",
+			at(2025, time.December, 31, 18, 0), 2 * time.Hour,
+			at(2026, time.January, 2, 10, 0),
+		},
+		{
+			"zero",
+			at(2026, time.October, 20, 10, 0), 0,
+			at(2026, time.October, 20, 10, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		got := c.Add(tt.from, tt.d)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: Add(%s, %s) = %s, want %s", tt.name, tt.from, tt.d, got.In(visits.Location), tt.want)
+		}
+	}
+}
+
+func TestAddInUTC(t *testing.T) {
+	t.Setenv(EnvVarExtraHolidays, "")
+	c := NewCalendar()
+
+	// Mexico City is UTC-6 all year since 2022, 15:00 UTC is the opening
+	from := time.Date(2026, time.October, 19, 14, 0, 0, 0, time.UTC)
+	want := time.Date(2026, time.October, 19, 16, 0, 0, 0, time.UTC)
+	if got := c.Add(from, time.Hour); !got.Equal(want) {
+		t.Errorf("Add(%s, 1h) = %s, want %s", from, got.UTC(), want)
+	}
+
+	// 01:00 UTC of tuesday is still monday in Mexico City, after hours
+	from = time.Date(2026, time.October, 20, 1, 0, 0, 0, time.UTC)
+	want = time.Date(2026, time.October, 20, 16, 0, 0, 0, time.UTC)
+	if got := c.Add(from, time.Hour); !got.Equal(want) {
+		t.Errorf("Add(%s, 1h) = %s, want %s", from, got.UTC(), want)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	t.Setenv(EnvVarExtraHolidays, "")
+	c := NewCalendar()
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     time.Duration
+	}{
+		{
+			"within the day",
+			at(2026, time.October, 20, 10, 0), at(2026, time.October, 20, 12, 30),
+			150 * time.Minute,
+		},
+		{
+			"across the weekend",
+			at(2026, time.October, 16, 18, 0), at(2026, time.October, 19, 10, 0),
+			7 * time.Hour,
+		},
+		{
+			"across a monday holiday",
+			at(2026, time.November, 13, 18, 0), at(2026, time.November, 17, 10, 0),
+			7 * time.Hour,
+		},
+		{
+			"closed hours only",
+			at(2026, time.October, 20, 20, 0), at(2026, time.October, 21, 8, 0),
+			0,
+		},
+		{
+			"reversed",
+			at(2026, time.October, 20, 12, 0), at(2026, time.October, 20, 10, 0),
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := c.Between(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: Between(%s, %s) = %s, want %s", tt.name, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
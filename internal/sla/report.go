@@ -0,0 +1,113 @@
+package sla
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+// AgentReport is how fast an agent answered the requests assigned to
+// them, in business time
+type AgentReport struct {
+	AgentId   string
+	AgentName string
+
+	Requests int
+	Answered int
+	// Requests answered, or still pending, after RemindAfter
+	Breached  int
+	Reminded  int
+	Escalated int
+
+	AverageResponse time.Duration
+	MedianResponse  time.Duration
+	MaxResponse     time.Duration
+}
+
+// BreachedPercent is the share of the requests out of the SLA
+func (r *AgentReport) BreachedPercent() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Breached) / float64(r.Requests) * 100
+}
+
+// Report groups the requests by their agent, the time to first response
+// counts from the assignment to the current agent. The agents with the
+// slowest median come first.
+func Report(requests []*db.Request, now time.Time) []*AgentReport {
+	cal := NewCalendar()
+	limit := RemindAfter()
+
+	byAgent := map[string]*AgentReport{}
+	responses := map[string][]time.Duration{}
+	reports := []*AgentReport{}
+	for _, req := range requests {
+		report, ok := byAgent[req.Agent]
+		if !ok {
+			report = &AgentReport{AgentId: req.Agent, AgentName: req.AgentName}
+			if req.Agent == "" {
+				report.AgentName = "Sin asignar"
+			}
+			byAgent[req.Agent] = report
+			reports = append(reports, report)
+		}
+
+		report.Requests++
+		if !req.SLARemindedAt.IsZero() {
+			report.Reminded++
+		}
+		if !req.SLAEscalatedAt.IsZero() {
+			report.Escalated++
+		}
+
+		if req.FirstResponseAt.IsZero() {
+			if limit > 0 && cal.Between(responseStart(req), now) > limit {
+				report.Breached++
+			}
+			continue
+		}
+
+		response := cal.Between(responseStart(req), req.FirstResponseAt)
+		report.Answered++
+		if limit > 0 && response > limit {
+			report.Breached++
+		}
+		responses[req.Agent] = append(responses[req.Agent], response)
+	}
+
+	for agent, durations := range responses {
+		report := byAgent[agent]
+		slices.Sort(durations)
+
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		report.AverageResponse = total / time.Duration(len(durations))
+		report.MedianResponse = durations[len(durations)/2]
+		report.MaxResponse = durations[len(durations)-1]
+	}
+
+	slices.SortStableFunc(reports, func(a, b *AgentReport) int {
+		return cmp.Compare(b.MedianResponse, a.MedianResponse)
+	})
+
+	return reports
+}
+
+// FormatDuration shows a response time in hours and minutes, e.g. 1 h 20 min
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours == 0 {
+		return fmt.Sprintf("%d min", minutes)
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("%d h", hours)
+	}
+	return fmt.Sprintf("%d h %d min", hours, minutes)
+}
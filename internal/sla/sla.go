@@ -0,0 +1,175 @@
+// Package sla reminds the agents of the requests they have not answered
+// in business hours and escalates them to the admins, and reports how
+// long the agents take to answer
+package sla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/notify"
+)
+
+const (
+	// Business time a request can stay pending before its agent is
+	// reminded of it, as a duration (e.g. 90m). 0 disables the reminders.
+	EnvVarRemindAfter = "LEAD_SLA_REMIND_AFTER"
+	// Business time a request can stay pending before the admins are told,
+	// as a duration. 0 disables the escalation.
+	EnvVarEscalateAfter = "LEAD_SLA_ESCALATE_AFTER"
+
+	DefaultRemindAfter   = 2 * time.Hour
+	DefaultEscalateAfter = 4 * time.Hour
+
+	// CheckInterval is how often the pending requests are checked
+	CheckInterval = 5 * time.Minute
+)
+
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s %q, using %v\n", name, value, def)
+		return def
+	}
+
+	return d
+}
+
+func RemindAfter() time.Duration {
+	return durationFromEnv(EnvVarRemindAfter, DefaultRemindAfter)
+}
+
+func EscalateAfter() time.Duration {
+	return durationFromEnv(EnvVarEscalateAfter, DefaultEscalateAfter)
+}
+
+// responseStart is when the agent of the request started to be
+// responsible for it
+func responseStart(req *db.Request) time.Time {
+	if !req.AssignedAt.IsZero() {
+		return req.AssignedAt
+	}
+	return req.CreatedAt
+}
+
+// Check reminds the agents of the pending requests past RemindAfter and
+// escalates the ones past EscalateAfter, counting business time only.
+// Each request is reminded once per agent and escalated once, even when
+// the notification can't be sent, so a missing phone doesn't retry forever.
+// The reminder and escalation are claimed before they are sent, so only
+// one instance sends them.
+func Check(ctx context.Context, now time.Time) (reminded, escalated int, err error) {
+	remindAfter, escalateAfter := RemindAfter(), EscalateAfter()
+	if remindAfter == 0 && escalateAfter == 0 {
+		return 0, 0, nil
+	}
+
+	requests, err := db.FindSLAPending(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cal := NewCalendar()
+	var admins []*db.User
+	var errs []error
+	for _, req := range requests {
+		if remindAfter > 0 && req.SLARemindedAt.IsZero() && req.Agent != "" &&
+			!cal.Add(responseStart(req), remindAfter).After(now) {
+			claimed, err := db.ClaimSLAReminder(ctx, req)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("claim reminder %s: %w", req.Id, err))
+				continue
+			}
+			if claimed {
+				if err := remind(ctx, req); err != nil {
+					log.Printf("Could not remind the agent of request %s: %v\n", req.Id, err)
+				}
+				reminded++
+			}
+		}
+
+		if escalateAfter > 0 && req.SLAEscalatedAt.IsZero() &&
+			!cal.Add(req.CreatedAt, escalateAfter).After(now) {
+			if admins == nil {
+				admins, err = findAdmins(ctx)
+				if err != nil {
+					return reminded, escalated, err
+				}
+			}
+			claimed, err := db.ClaimSLAEscalation(ctx, req)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("claim escalation %s: %w", req.Id, err))
+				continue
+			}
+			if claimed {
+				if err := escalate(ctx, req, admins); err != nil {
+					log.Printf("Could not escalate request %s: %v\n", req.Id, err)
+				}
+				escalated++
+			}
+		}
+	}
+
+	return reminded, escalated, errors.Join(errs...)
+}
+
+func findAdmins(ctx context.Context) ([]*db.User, error) {
+	users, err := db.FindAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	admins := []*db.User{}
+	for _, u := range users {
+		if u.Role == db.RoleAdmin && u.Active {
+			admins = append(admins, u)
+		}
+	}
+
+	return admins, nil
+}
+
+// remind reminds the agent of the request by the channels they chose
+func remind(ctx context.Context, req *db.Request) error {
+	agent, err := db.GetUserById(req.Agent)
+	if err != nil {
+		return err
+	}
+
+	_, err = notify.Send(ctx, &notify.Notification{Event: notify.EventSLABreach, Request: req, User: agent})
+	return err
+}
+
+// escalate tells each admin, or the notification phone and address when
+// there are no admins, that the request is still pending
+func escalate(ctx context.Context, req *db.Request, admins []*db.User) error {
+	if len(admins) == 0 {
+		_, err := notify.Send(ctx, &notify.Notification{Event: notify.EventSLABreach, Request: req, Escalated: true})
+		return err
+	}
+
+	var errs []error
+	for _, admin := range admins {
+		_, err := notify.Send(ctx, &notify.Notification{
+			Event:     notify.EventSLABreach,
+			Request:   req,
+			User:      admin,
+			Escalated: true,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", admin.Username, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
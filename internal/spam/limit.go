@@ -0,0 +1,50 @@
+package spam
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+// Limiter allows up to Max hits of each key in a Window that starts with
+// the first hit, e.g. the requests of an IP in an hour. The counts are
+// kept in Postgres so every instance of the server shares them, the keys
+// are prefixed with the name of the limiter.
+type Limiter struct {
+	Name   string
+	Max    int
+	Window time.Duration
+
+	// hit records a hit of the key and returns the hits of its window,
+	// db.HitRateLimit out of the tests
+	hit func(ctx context.Context, key string, window time.Duration, now time.Time) (int, error)
+}
+
+func NewLimiter(name string, max int, window time.Duration) *Limiter {
+	return &Limiter{
+		Name:   name,
+		Max:    max,
+		Window: window,
+		hit:    db.HitRateLimit,
+	}
+}
+
+// Allow records a hit of the key at now, it returns false when the key
+// already had Max hits in the window. A Max of 0 allows everything. The
+// hit is allowed when the count can't be read, the forms keep working
+// while the database is down and the limits can't be checked.
+func (l *Limiter) Allow(ctx context.Context, key string, now time.Time) bool {
+	if l.Max <= 0 || key == "" {
+		return true
+	}
+
+	count, err := l.hit(ctx, l.Name+":"+key, l.Window, now)
+	if err != nil {
+		log.Printf("Could not count the hit of the %s limit: %v\n", l.Name, err)
+		return true
+	}
+
+	return count <= l.Max
+}
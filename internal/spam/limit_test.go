@@ -0,0 +1,109 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memoryHits counts the hits like db.HitRateLimit, the window of a key
+// starts with its first hit
+type memoryHits struct {
+	counts  map[string]int
+	expires map[string]time.Time
+	keys    []string
+}
+
+func newMemoryHits() *memoryHits {
+	return &memoryHits{counts: map[string]int{}, expires: map[string]time.Time{}}
+}
+
+func (m *memoryHits) hit(ctx context.Context, key string, window time.Duration, now time.Time) (int, error) {
+	m.keys = append(m.keys, key)
+	if !m.expires[key].After(now) {
+		m.counts[key] = 0
+		m.expires[key] = now.Add(window)
+	}
+	m.counts[key]++
+	return m.counts[key], nil
+}
+
+func testLimiter(name string, max int, window time.Duration, hits *memoryHits) *Limiter {
+	l := NewLimiter(name, max, window)
+	l.hit = hits.hit
+	return l
+}
+
+// testGuard is a guard whose limiters count in memory
+func testGuard() *Guard {
+	hits := newMemoryHits()
+	g := NewGuard()
+	for _, l := range []*Limiter{g.ips, g.phones, g.tokens} {
+		l.hit = hits.hit
+	}
+	return g
+}
+
+func TestLimiterAllow(t *testing.T) {
+	ctx := context.Background()
+	hits := newMemoryHits()
+	l := testLimiter("ip", 3, time.Hour, hits)
+	start := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		if !l.Allow(ctx, "1.2.3.4", start.Add(time.Duration(i)*10*time.Minute)) {
+			t.Fatalf("hit %d was not allowed", i+1)
+		}
+	}
+	if l.Allow(ctx, "1.2.3.4", start.Add(30*time.Minute)) {
+		t.Fatal("hit over the limit was allowed")
+	}
+	// The other keys have their own count
+	if !l.Allow(ctx, "5.6.7.8", start.Add(30*time.Minute)) {
+		t.Fatal("hit of another key was not allowed")
+	}
+	if !l.Allow(ctx, "1.2.3.4", start.Add(time.Hour)) {
+		t.Fatal("hit after the window was not allowed")
+	}
+
+	// The limiters sharing the counts don't share the keys
+	other := testLimiter("email", 1, time.Hour, hits)
+	if !other.Allow(ctx, "1.2.3.4", start) {
+		t.Fatal("hit of the same key in another limiter was not allowed")
+	}
+	if hits.keys[0] != "ip:1.2.3.4" || hits.keys[len(hits.keys)-1] != "email:1.2.3.4" {
+		t.Errorf("keys = %v, want them prefixed with the limiter name", hits.keys)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	hits := newMemoryHits()
+	l := testLimiter("ip", 0, time.Hour, hits)
+	for range 100 {
+		if !l.Allow(ctx, "1.2.3.4", now) {
+			t.Fatal("a Max of 0 must allow every hit")
+		}
+	}
+
+	l = testLimiter("ip", 1, time.Hour, hits)
+	for range 3 {
+		if !l.Allow(ctx, "", now) {
+			t.Fatal("an empty key must not be limited")
+		}
+	}
+	if len(hits.keys) != 0 {
+		t.Errorf("counted %d hits of disabled limits", len(hits.keys))
+	}
+
+	// Without the counts the hits are allowed
+	l.hit = func(ctx context.Context, key string, window time.Duration, now time.Time) (int, error) {
+		return 0, errors.New("database is down")
+	}
+	if !l.Allow(ctx, "1.2.3.4", now) || !l.Allow(ctx, "1.2.3.4", now) {
+		t.Fatal("hit was not allowed when the count failed")
+	}
+}
@@ -0,0 +1,242 @@
+// Package spam protects the public request form. The submissions over the
+// rate limits are rejected, the ones that look automated (the honeypot is
+// filled, the form was sent too fast or without a valid proof of work) are
+// quarantined so no agent is notified of them.
+package spam
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Requests an IP can send in LimitWindow, 0 disables the limit
+	EnvVarMaxPerIP = "SPAM_MAX_PER_IP"
+	// Requests a phone can send in LimitWindow, 0 disables the limit
+	EnvVarMaxPerPhone = "SPAM_MAX_PER_PHONE"
+	// Least time from rendering the form to sending it, as a duration
+	// (e.g. 3s)
+	EnvVarMinFillTime = "SPAM_MIN_FILL_TIME"
+	// Leading zero bits of the proof of work, 0 disables it. Every bit
+	// doubles the work of the browser, 16 takes about a second.
+	EnvVarPowDifficulty = "SPAM_POW_DIFFICULTY"
+	// Addresses or CIDRs of the proxies in front of the server, comma
+	// separated (e.g. 10.0.0.0/8,127.0.0.1). X-Forwarded-For is only read
+	// from the requests they forward.
+	EnvVarTrustedProxies = "SPAM_TRUSTED_PROXIES"
+
+	DefaultMaxPerIP      = 10
+	DefaultMaxPerPhone   = 3
+	DefaultMinFillTime   = 3 * time.Second
+	DefaultPowDifficulty = 0
+
+	LimitWindow = time.Hour
+
+	// HoneypotField is the name of the hidden input only bots fill
+	HoneypotField = "website"
+)
+
+var ErrRateLimited = errors.New("too many requests")
+
+func intFromEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %q, using %v\n", name, value, def)
+		return def
+	}
+
+	return n
+}
+
+func MinFillTime() time.Duration {
+	value := os.Getenv(EnvVarMinFillTime)
+	if value == "" {
+		return DefaultMinFillTime
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s %q, using %v\n", EnvVarMinFillTime, value, DefaultMinFillTime)
+		return DefaultMinFillTime
+	}
+
+	return d
+}
+
+func PowDifficulty() int {
+	return intFromEnv(EnvVarPowDifficulty, DefaultPowDifficulty)
+}
+
+// Reason is why a submission was quarantined
+type Reason string
+
+const (
+	ReasonHoneypot Reason = "honeypot"
+	ReasonTooFast  Reason = "muy_rapido"
+	ReasonToken    Reason = "token"
+	ReasonWork     Reason = "pow"
+	ReasonReused   Reason = "token_usado"
+)
+
+var reasonLabels = map[Reason]string{
+	ReasonHoneypot: "Llenó el campo oculto",
+	ReasonTooFast:  "Enviado demasiado rápido",
+	ReasonToken:    "Sin token válido",
+	ReasonWork:     "Sin prueba de trabajo",
+	ReasonReused:   "Token ya usado",
+}
+
+func (r Reason) Label() string {
+	if label, ok := reasonLabels[r]; ok {
+		return label
+	}
+	return string(r)
+}
+
+// Submission is what the checks need from a sent form
+type Submission struct {
+	IP string
+	// E.164 form of the phone, empty when it is not valid
+	Phone    string
+	Honeypot string
+	Token    string
+	Nonce    string
+}
+
+// Guard keeps the rate limits of the form across submissions
+type Guard struct {
+	ips    *Limiter
+	phones *Limiter
+	// The tokens already sent, each one is good for a single submission
+	tokens *Limiter
+}
+
+func NewGuard() *Guard {
+	return &Guard{
+		ips:    NewLimiter("request-ip", intFromEnv(EnvVarMaxPerIP, DefaultMaxPerIP), LimitWindow),
+		phones: NewLimiter("request-phone", intFromEnv(EnvVarMaxPerPhone, DefaultMaxPerPhone), LimitWindow),
+		tokens: NewLimiter("request-token", 1, TokenMaxAge),
+	}
+}
+
+// AllowIP counts a submission from the IP, it is checked before the form
+// is validated so invalid submissions count as well
+func (g *Guard) AllowIP(ctx context.Context, ip string, now time.Time) bool {
+	return g.ips.Allow(ctx, ip, now)
+}
+
+// Check returns ErrRateLimited when the phone is over its limit, or the
+// reason to quarantine the submission, empty when it looks legit
+func (g *Guard) Check(ctx context.Context, s *Submission, now time.Time) (Reason, error) {
+	if !g.phones.Allow(ctx, s.Phone, now) {
+		return "", ErrRateLimited
+	}
+
+	if s.Honeypot != "" {
+		return ReasonHoneypot, nil
+	}
+
+	renderedAt, err := ParseToken(s.Token)
+	if err != nil || now.Sub(renderedAt) > TokenMaxAge {
+		return ReasonToken, nil
+	}
+	if now.Sub(renderedAt) < MinFillTime() {
+		return ReasonTooFast, nil
+	}
+
+	if !VerifyWork(s.Token, s.Nonce, PowDifficulty()) {
+		return ReasonWork, nil
+	}
+
+	// The token expires TokenMaxAge after it was rendered, it is rejected
+	// above by then
+	if !g.tokens.Allow(ctx, s.Token, now) {
+		return ReasonReused, nil
+	}
+
+	return "", nil
+}
+
+// trustedProxies returns the proxies of EnvVarTrustedProxies
+func trustedProxies() []*net.IPNet {
+	proxies := []*net.IPNet{}
+	for _, value := range strings.Split(os.Getenv(EnvVarTrustedProxies), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			log.Printf("invalid proxy %q in %s\n", value, EnvVarTrustedProxies)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies
+}
+
+func isTrusted(proxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the IP the request came from. When it came through one
+// of the trusted proxies it is the last address of X-Forwarded-For that is
+// not a trusted proxy, otherwise the header is ignored as the client can
+// set it.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	proxies := trustedProxies()
+	if !isTrusted(proxies, host) {
+		return host
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		if !isTrusted(proxies, addr) {
+			return addr
+		}
+		host = addr
+	}
+
+	return host
+}
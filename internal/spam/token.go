@@ -0,0 +1,82 @@
+package spam
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"os"
+	"strings"
+	"time"
+)
+
+// TokenMaxAge is how long a form token is accepted after the form is
+// rendered
+const TokenMaxAge = 24 * time.Hour
+
+var ErrInvalidToken = errors.New("invalid form token")
+
+func tokenKey() []byte {
+	return []byte("spam-form-token:" + os.Getenv("JWT_SECRET"))
+}
+
+func tokenSignature(payload string) string {
+	mac := hmac.New(sha256.New, tokenKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// NewToken returns the token of a form rendered at now. It is signed so
+// the time it carries can't be forged, and it is random so it can be the
+// challenge of the proof of work.
+func NewToken(now time.Time) string {
+	var raw [16]byte
+	binary.BigEndian.PutUint64(raw[:8], uint64(now.Unix()))
+	rand.Read(raw[8:])
+
+	payload := base64.RawURLEncoding.EncodeToString(raw[:])
+	return payload + "." + tokenSignature(payload)
+}
+
+// ParseToken checks the signature of the token and returns when its form
+// was rendered
+func ParseToken(token string) (time.Time, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(tokenSignature(payload))) {
+		return time.Time{}, ErrInvalidToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) != 16 {
+		return time.Time{}, ErrInvalidToken
+	}
+
+	return time.Unix(int64(binary.BigEndian.Uint64(raw[:8])), 0), nil
+}
+
+// VerifyWork tells if the SHA-256 of token:nonce starts with at least
+// difficulty zero bits, the browser looks for the nonce before sending
+// the form (web/static/js/pow.js)
+func VerifyWork(token, nonce string, difficulty int) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	if nonce == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			zeros += bits.LeadingZeros8(b)
+			break
+		}
+		zeros += 8
+	}
+
+	return zeros >= difficulty
+}
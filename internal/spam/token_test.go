@@ -0,0 +1,145 @@
+package spam
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	token := NewToken(now)
+	renderedAt, err := ParseToken(token)
+	if err != nil {
+		t.Fatalf("ParseToken err: %v", err)
+	}
+	if !renderedAt.Equal(now) {
+		t.Errorf("ParseToken = %s, want %s", renderedAt, now)
+	}
+	if NewToken(now) == token {
+		t.Error("two tokens of the same time are equal")
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	// A token whose time is moved back to skip the fill time
+	forged := NewToken(now.Add(-time.Hour))
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tampered := []string{
+		"",
+		payload,
+		payload + ".",
+		forgedPayload + "." + signature,
+		payload + "." + signature[1:],
+		payload + "x." + signature,
+		"!!!." + signature,
+	}
+	for _, tok := range tampered {
+		if _, err := ParseToken(tok); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ParseToken(%q) err = %v, want %v", tok, err, ErrInvalidToken)
+		}
+	}
+
+	// Signed with another secret
+	t.Setenv("JWT_SECRET", "other-secret")
+	if _, err := ParseToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken with another secret err = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv(EnvVarMinFillTime, "3s")
+	t.Setenv(EnvVarPowDifficulty, "0")
+	ctx := context.Background()
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		token string
+		want  Reason
+	}{
+		{"valid", NewToken(now.Add(-time.Minute)), ""},
+		{"missing", "", ReasonToken},
+		{"expired", NewToken(now.Add(-TokenMaxAge - time.Second)), ReasonToken},
+		{"too fast", NewToken(now.Add(-time.Second)), ReasonTooFast},
+	}
+
+	for _, tt := range tests {
+		g := testGuard()
+		reason, err := g.Check(ctx, &Submission{Phone: "+526181234567", Token: tt.token}, now)
+		if err != nil {
+			t.Fatalf("%s: Check err: %v", tt.name, err)
+		}
+		if reason != tt.want {
+			t.Errorf("%s: Check = %q, want %q", tt.name, reason, tt.want)
+		}
+	}
+
+	// A token is good for a single submission
+	g := testGuard()
+	token := NewToken(now.Add(-time.Minute))
+	if reason, _ := g.Check(ctx, &Submission{Phone: "+526181234567", Token: token}, now); reason != "" {
+		t.Fatalf("first Check = %q", reason)
+	}
+	if reason, _ := g.Check(ctx, &Submission{Phone: "+526187654321", Token: token}, now.Add(time.Minute)); reason != ReasonReused {
+		t.Errorf("second Check = %q, want %q", reason, ReasonReused)
+	}
+}
+
+// leadingZeroBitsJS is leadingZeroBits of web/static/js/pow.js, Math.clz32
+// counts the zeros of the byte as a 32 bit integer
+func leadingZeroBitsJS(sum []byte) int {
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros32(uint32(b)) - 24
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+func TestVerifyWork(t *testing.T) {
+	token := "AAAAAGj0xKBe2m1RMV4xHw.c2lnbmF0dXJl"
+
+	// Every nonce passes up to the zero bits the browser counts for it
+	for nonce := range 2000 {
+		n := strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(token + ":" + n))
+		zeros := leadingZeroBitsJS(sum[:])
+
+		if !VerifyWork(token, n, zeros) {
+			t.Fatalf("VerifyWork(%s, %d) = false, the browser counts %d zero bits", n, zeros, zeros)
+		}
+		if VerifyWork(token, n, zeros+1) {
+			t.Fatalf("VerifyWork(%s, %d) = true, the browser counts %d zero bits", n, zeros+1, zeros)
+		}
+	}
+
+	// The nonce the browser would send
+	const difficulty = 10
+	nonce := 0
+	for ; ; nonce++ {
+		sum := sha256.Sum256([]byte(token + ":" + strconv.Itoa(nonce)))
+		if leadingZeroBitsJS(sum[:]) >= difficulty {
+			break
+		}
+	}
+	if !VerifyWork(token, strconv.Itoa(nonce), difficulty) {
+		t.Errorf("VerifyWork rejected the nonce %d", nonce)
+	}
+	if !VerifyWork(token, "", 0) {
+		t.Error("a difficulty of 0 must not need a nonce")
+	}
+	if VerifyWork(token, "", 1) {
+		t.Error("an empty nonce was accepted")
+	}
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The routes serving the signed URLs of the local stores
+const (
+	SignedPathPrefix        = "/media/"
+	PrivateSignedPathPrefix = "/media/private/"
+)
+
+// Local keeps the objects on the local filesystem, under Root
+type Local struct {
+	Root      string
+	PublicURL string
+	// Key used to sign the temporary URLs, see SignedURL
+	SigningKey []byte
+	// Route of the signed URLs, SignedPathPrefix when empty
+	SignedPath string
+}
+
+func NewLocalFromEnv() (*Local, error) {
+	root := os.Getenv(EnvVarLocalDir)
+	if root == "" {
+		root = DefaultLocalDir
+	}
+	publicURL := os.Getenv(EnvVarPublicURL)
+	if publicURL == "" {
+		publicURL = DefaultPublicURL
+	}
+
+	return newLocal(root, publicURL, SignedPathPrefix)
+}
+
+// NewPrivateLocalFromEnv creates the Private store, its objects have no
+// public URL and are only served through signed URLs
+func NewPrivateLocalFromEnv() (*Local, error) {
+	root := os.Getenv(EnvVarPrivateDir)
+	if root == "" {
+		root = DefaultPrivateDir
+	}
+
+	return newLocal(root, PrivateSignedPathPrefix, PrivateSignedPathPrefix)
+}
+
+func newLocal(root, publicURL, signedPath string) (*Local, error) {
+	// A key of its own, a leaked signed URL must not help forge the
+	// sessions or the other way around
+	signingKey := os.Getenv(EnvVarSigningKey)
+	if signingKey == "" {
+		return nil, fmt.Errorf("%s must be set to use the local storage", EnvVarSigningKey)
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Local{
+		Root:       root,
+		PublicURL:  strings.TrimSuffix(publicURL, "/"),
+		SigningKey: []byte(signingKey),
+		SignedPath: signedPath,
+	}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Remove the directories left empty, os.Remove fails on the first one
+	// that still has files
+	root := filepath.Clean(l.Root)
+	for dir := filepath.Dir(p); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	// Only walk the directory containing the prefix
+	dir := prefix
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	start := l.Root
+	if dir != "." && dir != "" {
+		p, err := l.path(dir)
+		if err != nil {
+			return nil, err
+		}
+		start = p
+	}
+
+	objects := []Object{}
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{
+			Key:     key,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return objects, nil
+	}
+
+	return objects, err
+}
+
+func (l *Local) URL(key string) string {
+	return l.PublicURL + "/" + key
+}
+
+// sign covers the route of the store too, the stores share the key and a
+// URL of one must not open the same key in the other
+func (l *Local) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.SigningKey)
+	mac.Write([]byte(l.signedPath() + "\n" + key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *Local) signedPath() string {
+	if l.SignedPath == "" {
+		return SignedPathPrefix
+	}
+	return l.SignedPath
+}
+
+// SignedURL returns a URL under SignedPath, which must be checked with
+// VerifySignature before serving the object
+func (l *Local) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	clean, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if len(l.SigningKey) == 0 {
+		return "", errors.New("storage signing key is not set")
+	}
+
+	exp := time.Now().Add(expires).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(exp, 10)},
+		"signature": {l.sign(clean, exp)},
+	}
+
+	return l.signedPath() + clean + "?" + query.Encode(), nil
+}
+
+// VerifySignature checks the expires and signature query values of a URL
+// generated by SignedURL
+func (l *Local) VerifySignature(key, expires, signature string) error {
+	clean, err := CleanKey(key)
+	if err != nil || len(l.SigningKey) == 0 {
+		return ErrInvalidSignedURL
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignedURL
+	}
+
+	if !hmac.Equal([]byte(l.sign(clean, exp)), []byte(signature)) {
+		return ErrInvalidSignedURL
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalSignedURL(t *testing.T) {
+	t.Setenv(EnvVarSigningKey, "test-key")
+	media, err := newLocal(t.TempDir(), "/static", SignedPathPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := newLocal(t.TempDir(), PrivateSignedPathPrefix, PrivateSignedPathPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := private.SignedURL(context.Background(), "documents/1/a.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL err: %v", err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.TrimPrefix(u.Path, PrivateSignedPathPrefix)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if err = private.VerifySignature(key, expires, signature); err != nil {
+		t.Errorf("VerifySignature err: %v", err)
+	}
+	// The stores share the key, the URL of one must not open the other
+	if err = media.VerifySignature(key, expires, signature); !errors.Is(err, ErrInvalidSignedURL) {
+		t.Errorf("VerifySignature of the other store err = %v, want %v", err, ErrInvalidSignedURL)
+	}
+	if err = private.VerifySignature("documents/1/b.pdf", expires, signature); !errors.Is(err, ErrInvalidSignedURL) {
+		t.Errorf("VerifySignature of another key err = %v, want %v", err, ErrInvalidSignedURL)
+	}
+
+	expired := time.Now().Add(-time.Minute).Unix()
+	if err = private.VerifySignature(key, expires+"0", signature); !errors.Is(err, ErrInvalidSignedURL) {
+		t.Errorf("VerifySignature of another expiry err = %v, want %v", err, ErrInvalidSignedURL)
+	}
+	if err = private.VerifySignature(key, strconv.FormatInt(expired, 10), private.sign(key, expired)); !errors.Is(err, ErrInvalidSignedURL) {
+		t.Errorf("VerifySignature of an expired url err = %v, want %v", err, ErrInvalidSignedURL)
+	}
+}
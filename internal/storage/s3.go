@@ -0,0 +1,438 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3Service         = "s3"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+
+	// S3 rejects presigned URLs valid for more than a week
+	s3MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// S3 keeps the objects on an S3-compatible bucket (AWS, MinIO, R2, etc.).
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	// Endpoint including the scheme, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://localhost:9000 for a local MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// MinIO and most self-hosted services need path-style URLs
+	// (endpoint/bucket/key) instead of virtual hosts (bucket.endpoint/key)
+	ForcePathStyle bool
+	// Base URL of the public objects, e.g. a CDN. Defaults to the bucket URL
+	PublicURL string
+	Client    *http.Client
+}
+
+func NewS3FromEnv() (*S3, error) {
+	s := &S3{
+		Endpoint:  strings.TrimSuffix(os.Getenv(EnvVarS3Endpoint), "/"),
+		Region:    os.Getenv(EnvVarS3Region),
+		Bucket:    os.Getenv(EnvVarS3Bucket),
+		AccessKey: os.Getenv(EnvVarS3AccessKey),
+		SecretKey: os.Getenv(EnvVarS3SecretKey),
+		PublicURL: strings.TrimSuffix(os.Getenv(EnvVarPublicURL), "/"),
+		Client:    &http.Client{Timeout: 2 * time.Minute},
+	}
+	s.ForcePathStyle, _ = strconv.ParseBool(os.Getenv(EnvVarS3ForcePathStyle))
+
+	if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return nil, fmt.Errorf(
+			"%s, %s, %s and %s must be set to use the s3 storage",
+			EnvVarS3Endpoint, EnvVarS3Bucket, EnvVarS3AccessKey, EnvVarS3SecretKey,
+		)
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+
+	return s, nil
+}
+
+// NewPrivateS3FromEnv creates the Private store on S3_PRIVATE_BUCKET, the
+// private files are never kept in the public bucket
+func NewPrivateS3FromEnv() (*S3, error) {
+	s, err := NewS3FromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := os.Getenv(EnvVarS3PrivateBucket)
+	if bucket == "" || bucket == s.Bucket {
+		return nil, fmt.Errorf(
+			"%s must be set to a bucket other than %s to use the s3 storage",
+			EnvVarS3PrivateBucket, EnvVarS3Bucket,
+		)
+	}
+	s.Bucket = bucket
+	// The public URL points to the public bucket
+	s.PublicURL = ""
+
+	return s, nil
+}
+
+// objectURL returns the URL of key, or of the bucket when key is empty
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	escapedKey := s3EscapePath(key)
+	if s.ForcePathStyle {
+		u.Path = "/" + s.Bucket + "/" + key
+		u.RawPath = "/" + s.Bucket + "/" + escapedKey
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + escapedKey
+	}
+
+	return u, nil
+}
+
+// s3EscapePath escapes every segment of the key as SigV4 expects
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = s3Escape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Escape encodes everything but the unreserved characters (RFC 3986)
+func s3Escape(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.Format(s3DateFormat) + "/" + s.Region + "/" + s3Service + "/aws4_request"
+}
+
+func (s *S3) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		s.scope(now),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// do signs and sends a request, the body is hashed so it's kept in memory
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte, headers http.Header) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalHeaders := "host:" + u.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + now.Format(s3TimeFormat) + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm,
+		s.AccessKey,
+		s.scope(now),
+		strings.Join(signedHeaders, ";"),
+		s.signature(now, canonicalRequest),
+	))
+
+	return s.Client.Do(req)
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3ResponseError(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	var e s3Error
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return fmt.Errorf("s3 %s: %s: %s", res.Status, e.Code, e.Message)
+	}
+
+	return fmt.Errorf("s3 %s", res.Status)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = ContentType(key)
+	}
+	res, err := s.do(ctx, http.MethodPut, key, nil, body, http.Header{
+		"Content-Type": {contentType},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3ResponseError(res)
+	}
+
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, s3ResponseError(res)
+	}
+
+	return res.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		err = s3ResponseError(res)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		res, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			err = s3ResponseError(res)
+			res.Body.Close()
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{
+				Key:     c.Key,
+				Size:    c.Size,
+				ModTime: c.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) URL(key string) string {
+	if s.PublicURL != "" {
+		return s.PublicURL + "/" + s3EscapePath(key)
+	}
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// SignedURL returns a presigned GET URL, valid for at most a week
+func (s *S3) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expires > s3MaxPresignExpiry {
+		expires = s3MaxPresignExpiry
+	}
+
+	return s.presign(key, expires, time.Now().UTC())
+}
+
+func (s *S3) presign(key string, expires time.Duration, now time.Time) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3Algorithm},
+		"X-Amz-Credential":    {s.AccessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.Format(s3TimeFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		s3CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(now, canonicalRequest))
+	u.RawQuery = s3CanonicalQuery(query)
+
+	return u.String(), nil
+}
@@ -0,0 +1,184 @@
+// Package storage abstracts where the uploaded media (property pictures,
+// user pictures, documents) is kept, so the app can run on several
+// instances with the files on an S3-compatible bucket.
+//
+// Keys are slash separated paths relative to the root of the store, e.g.
+// "properties/{id}/main-pic.jpg".
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("object not found")
+	ErrInvalidKey       = errors.New("invalid object key")
+	ErrUnknownDriver    = errors.New("unknown storage driver")
+	ErrInvalidSignedURL = errors.New("invalid or expired signed url")
+)
+
+const (
+	EnvVarDriver     = "STORAGE_DRIVER"
+	EnvVarLocalDir   = "STORAGE_LOCAL_DIR"
+	EnvVarPublicURL  = "STORAGE_PUBLIC_URL"
+	EnvVarSigningKey = "STORAGE_SIGNING_KEY"
+	EnvVarPrivateDir = "STORAGE_PRIVATE_DIR"
+
+	EnvVarS3Endpoint       = "S3_ENDPOINT"
+	EnvVarS3Region         = "S3_REGION"
+	EnvVarS3Bucket         = "S3_BUCKET"
+	EnvVarS3AccessKey      = "S3_ACCESS_KEY_ID"
+	EnvVarS3SecretKey      = "S3_SECRET_ACCESS_KEY"
+	EnvVarS3ForcePathStyle = "S3_FORCE_PATH_STYLE"
+	// Bucket of the Private store, required with the s3 driver and other
+	// than S3_BUCKET, which is public
+	EnvVarS3PrivateBucket = "S3_PRIVATE_BUCKET"
+
+	DriverLocal = "local"
+	DriverS3    = "s3"
+
+	// The local store keeps the media in its own folder of the served
+	// /static/ tree, the rest of the tree is out of its reach. The media
+	// uploaded before the stores existed is moved there from
+	// web/static/properties and web/static/users.
+	DefaultLocalDir  = "web/static/media"
+	DefaultPublicURL = "/static/media"
+	// Outside of the served tree
+	DefaultPrivateDir = "data/private"
+)
+
+// Object describes a stored file
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type Store interface {
+	// Put stores the contents of r under key, replacing any previous object
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the object stored under key, ErrNotFound if it doesn't exist
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object, deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL returns the public URL of the object
+	URL(key string) string
+	// SignedURL returns a URL granting temporary access to the object,
+	// used for the files that must not be public
+	SignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+var (
+	// Media is the store of the public files, set by Open
+	Media Store
+	// Private is the store of the files that are only shared through
+	// signed URLs, like the unwatermarked originals. Set by Open.
+	Private Store
+)
+
+// Open creates the stores configured by the STORAGE_DRIVER env variable
+// and sets them as Media and Private
+func Open() (Store, error) {
+	var (
+		store   Store
+		private Store
+		err     error
+	)
+
+	switch driver := os.Getenv(EnvVarDriver); driver {
+	case "", DriverLocal:
+		store, err = NewLocalFromEnv()
+		if err == nil {
+			private, err = NewPrivateLocalFromEnv()
+		}
+	case DriverS3:
+		store, err = NewS3FromEnv()
+		if err == nil {
+			private, err = NewPrivateS3FromEnv()
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, driver)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	Media = store
+	Private = private
+	return store, nil
+}
+
+// URL returns the public URL of key in the Media store
+func URL(key string) string {
+	if Media == nil {
+		return DefaultPublicURL + "/" + key
+	}
+
+	return Media.URL(key)
+}
+
+// Key joins the elements into an object key
+func Key(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// CleanKey validates key, rejecting absolute paths and parent references
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+
+	clean := path.Clean(key)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrInvalidKey
+	}
+
+	return clean, nil
+}
+
+// ContentType guesses the content type of key by its extension
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func DeletePrefix(ctx context.Context, store Store, prefix string) error {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err = store.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadAll reads the whole object stored under key
+func ReadAll(ctx context.Context, store Store, key string) ([]byte, error) {
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
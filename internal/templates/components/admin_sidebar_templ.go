@@ -31,12 +31,12 @@ func AdminSidebar(user *auth.Auth) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"relative bg-stone-50 basis-auto shrink-0 grow-0 px-6 border-r border-slate-300 z-30\" id=\"navbar\"><div class=\"relative flex h-14\"><img src=\"/static/img/sibra_logo_256.webp\" alt=\"Logo de sibra durango\" class=\"w-16 h-auto my-auto\" id=\"navbar-logo\"></div><ul class=\"space-y-0.5\"><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin\"><a href=\"/admin\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-none stroke-current\"><use href=\"/static/svg/cube.svg#cube\"></use></svg><p class=\"\">Inicio</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/propiedades\"><a href=\"/admin/propiedades\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/home.svg#home\"></use></svg><p class=\"\">Propiedades</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/mi-usuario\"><a href=\"/admin/mi-usuario\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/sprites.svg#user\"></use></svg><p class=\"\">Mi usuario</p></a></li>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"relative bg-stone-50 basis-auto shrink-0 grow-0 px-6 border-r border-slate-300 z-30\" id=\"navbar\"><div class=\"relative flex h-14\"><img src=\"/static/img/sibra_logo_256.webp\" alt=\"Logo de sibra durango\" class=\"w-16 h-auto my-auto\" id=\"navbar-logo\"></div><ul class=\"space-y-0.5\"><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin\"><a href=\"/admin\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-none stroke-current\"><use href=\"/static/svg/cube.svg#cube\"></use></svg><p class=\"\">Inicio</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/propiedades\"><a href=\"/admin/propiedades\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/home.svg#home\"></use></svg><p class=\"\">Propiedades</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/solicitudes\"><a href=\"/admin/solicitudes\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/list.svg#list\"></use></svg><p class=\"\">Solicitudes</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/pendientes\"><a href=\"/admin/pendientes\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/success.svg#success\"></use></svg><p class=\"\">Pendientes</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/contactos\"><a href=\"/admin/contactos\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/users.svg#users\"></use></svg><p class=\"\">Contactos</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/disponibilidad\"><a href=\"/admin/disponibilidad\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/square.svg#square\"></use></svg><p class=\"\">Disponibilidad</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/colonias\"><a href=\"/admin/colonias\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/map.svg#map\"></use></svg><p class=\"\">Colonias</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/mi-usuario\"><a href=\"/admin/mi-usuario\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/sprites.svg#user\"></use></svg><p class=\"\">Mi usuario</p></a></li>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		if user != nil && user.Role == "admin" {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/usuarios\"><a href=\"/admin/usuarios\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/users.svg#users\"></use></svg><p class=\"\">Usuarios</p></a></li>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/usuarios\"><a href=\"/admin/usuarios\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/users.svg#users\"></use></svg><p class=\"\">Usuarios</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/duplicados\"><a href=\"/admin/duplicados\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/picture.svg#picture\"></use></svg><p class=\"\">Duplicados</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/solicitudes/spam\"><a href=\"/admin/solicitudes/spam\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/times.svg#times\"></use></svg><p class=\"\">Cuarentena</p></a></li><li class=\"relative text-stone-400 navbar-link\" data-nav-href=\"/admin/reportes\"><a href=\"/admin/reportes/solicitudes\" class=\"flex items-center text-sm py-2 gap-x-2\"><svg class=\"w-6 h-6 fill-current\"><use href=\"/static/svg/filter.svg#filter\"></use></svg><p class=\"\">Reportes</p></a></li>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
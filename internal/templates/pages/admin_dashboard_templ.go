@@ -9,9 +9,11 @@ import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
 import "github.com/vladwithcode/sibra-site/internal/auth"
+import "github.com/vladwithcode/sibra-site/internal/db"
 import "github.com/vladwithcode/sibra-site/internal/templates/layouts"
+import "github.com/vladwithcode/sibra-site/internal/templates/components"
 
-func AdminDashboard(user *auth.Auth) templ.Component {
+func AdminDashboard(user *auth.Auth, saleTrends, rentTrends []*db.MarketSnapshot) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -51,13 +53,25 @@ func AdminDashboard(user *auth.Auth) templ.Component {
 			var templ_7745c5c3_Var3 string
 			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(user.Fullname)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/pages/admin_dashboard.templ`, Line: 8, Col: 49}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/pages/admin_dashboard.templ`, Line: 10, Col: 49}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</h3><div class=\"py-2\"></div><div class=\"grid grid-cols-3 gap-2\"><a href=\"/admin/propiedades/nueva\" class=\"aspect-square flex flex-col bg-stone-50 border border-slate-300 text-slate-300 rounded items-center justify-center hover:bg-stone-100 hover:text-slate-600 hover:border-slate-400\"><svg class=\"w-8 h-8 fill-current\"><use href=\"/static/svg/home.svg#home\"></use></svg><p class=\"text-lg\">Registrar Propiedad</p></a></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</h3><div class=\"py-2\"></div><div class=\"grid grid-cols-3 gap-2\"><a href=\"/admin/propiedades/nueva\" class=\"aspect-square flex flex-col bg-stone-50 border border-slate-300 text-slate-300 rounded items-center justify-center hover:bg-stone-100 hover:text-slate-600 hover:border-slate-400\"><svg class=\"w-8 h-8 fill-current\"><use href=\"/static/svg/home.svg#home\"></use></svg><p class=\"text-lg\">Registrar Propiedad</p></a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = components.MarketTrendCard("Mercado en venta", saleTrends).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = components.MarketTrendCard("Mercado en renta", rentTrends).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
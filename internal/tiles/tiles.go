@@ -0,0 +1,115 @@
+// Package tiles keeps an on-disk cache of the Mapbox Vector Tiles served
+// for the map layers
+package tiles
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	EnvVarCacheDir  = "TILE_CACHE_DIR"
+	defaultCacheDir = "cache/tiles"
+
+	MaxZoom = 22
+)
+
+var (
+	ErrInvalidTile = errors.New("invalid tile coordinates")
+)
+
+// Layer names, also used as the name of the layer inside the tile
+const (
+	LayerProperties = "properties"
+	LayerHoods      = "hoods"
+)
+
+func IsValidLayer(layer string) bool {
+	return layer == LayerProperties || layer == LayerHoods
+}
+
+// ValidateCoords checks that x and y exist at zoom z
+func ValidateCoords(z, x, y int) error {
+	if z < 0 || z > MaxZoom {
+		return fmt.Errorf("%w: zoom out of range", ErrInvalidTile)
+	}
+
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return fmt.Errorf("%w: %d/%d/%d", ErrInvalidTile, z, x, y)
+	}
+
+	return nil
+}
+
+func cacheDir() string {
+	if dir := os.Getenv(EnvVarCacheDir); dir != "" {
+		return dir
+	}
+
+	return defaultCacheDir
+}
+
+func tilePath(layer string, z, x, y int) string {
+	return filepath.Join(
+		cacheDir(),
+		layer,
+		strconv.Itoa(z),
+		strconv.Itoa(x),
+		strconv.Itoa(y)+".mvt",
+	)
+}
+
+// Get returns the cached tile, ok is false on a cache miss
+func Get(layer string, z, x, y int) (tile []byte, ok bool) {
+	tile, err := os.ReadFile(tilePath(layer, z, x, y))
+	if err != nil {
+		return nil, false
+	}
+
+	return tile, true
+}
+
+// Put stores the tile in the cache. The file is written to a temporary
+// path first so concurrent readers never get a partial tile.
+func Put(layer string, z, x, y int, tile []byte) error {
+	path := tilePath(layer, z, x, y)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(tile); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Invalidate drops every cached tile of the given layers. Must be called
+// after writing the data the layers are built from.
+func Invalidate(layers ...string) {
+	for _, layer := range layers {
+		if !IsValidLayer(layer) {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(cacheDir(), layer))
+		if err != nil {
+			log.Printf("failed to invalidate %s tiles: %v\n", layer, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"math/big"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -10,6 +11,16 @@ import (
 	"github.com/leekchan/accounting"
 )
 
+// Base URL of the public site (e.g. https://sibradurango.com), for the
+// links sent outside of the site
+const EnvVarSiteURL = "SITE_URL"
+
+// SiteURL returns the absolute URL of path in the public site, path as is
+// when SITE_URL is not set
+func SiteURL(path string) string {
+	return strings.TrimRight(os.Getenv(EnvVarSiteURL), "/") + path
+}
+
 func FormatMoney(amount float64) string {
 	bFloat := big.NewFloat(amount)
 	ac := accounting.Accounting{Symbol: "$", Precision: 0}
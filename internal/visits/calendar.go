@@ -0,0 +1,112 @@
+package visits
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/sibra-site/internal"
+	"github.com/vladwithcode/sibra-site/internal/db"
+	"github.com/vladwithcode/sibra-site/internal/ics"
+)
+
+const (
+	// CalendarPast is how far back the visits of the calendar feed go
+	CalendarPast = 30 * 24 * time.Hour
+	// CalendarRefresh is how often subscribed calendars reload the feed
+	CalendarRefresh = time.Hour
+)
+
+// FeedPath is the path of the calendar feed of the token
+func FeedPath(token string) string {
+	return "/calendario/" + token + ".ics"
+}
+
+// InvitePath is the path of the invite to the visit, under the calendar
+// of the agent so it can be opened without logging in
+func InvitePath(token, requestId string) string {
+	return "/calendario/" + token + "/" + requestId + ".ics"
+}
+
+// MapsURL is the link to the property of the visit in Google Maps, by its
+// coordinates or by its address when it has none
+func MapsURL(req *db.Request) string {
+	query := req.PropertyAddress
+	if req.PropertyLat != 0 || req.PropertyLon != 0 {
+		query = fmt.Sprintf("%f,%f", req.PropertyLat, req.PropertyLon)
+	}
+	if query == "" {
+		return ""
+	}
+
+	return "https://www.google.com/maps/search/?api=1&query=" + url.QueryEscape(query)
+}
+
+// Event is the calendar event of a visit
+func Event(req *db.Request) *ics.Event {
+	var description strings.Builder
+	fmt.Fprintf(&description, "Cliente: %s\nTeléfono: %s\n", req.Name, req.PhoneDisplay)
+	if req.PropertyAddress != "" {
+		fmt.Fprintf(&description, "Propiedad: %s\n", req.PropertyAddress)
+	}
+	if maps := MapsURL(req); maps != "" {
+		fmt.Fprintf(&description, "Mapa: %s\n", maps)
+	}
+
+	event := &ics.Event{
+		UID:         req.Id + "@sibradurango",
+		Start:       req.ScheduledDate,
+		End:         req.ScheduledDate.Add(SlotDuration),
+		Summary:     "Visita: " + req.Name,
+		Description: strings.TrimSpace(description.String()),
+		Location:    req.PropertyAddress,
+		Lat:         req.PropertyLat,
+		Lon:         req.PropertyLon,
+		Status:      ics.StatusConfirmed,
+		Updated:     req.UpdatedAt,
+	}
+	// Calendar apps only take absolute links
+	propertyURL := internal.SiteURL("/propiedades/" + req.PropertyContract + "/" + req.Property)
+	if req.Property != "" && req.PropertyContract != "" && strings.HasPrefix(propertyURL, "http") {
+		event.URL = propertyURL
+	}
+
+	return event
+}
+
+// Calendar is the calendar of the visits of an agent
+func Calendar(agent *db.User, requests []*db.Request) *ics.Calendar {
+	cal := &ics.Calendar{
+		Name:            "Visitas " + agent.Name + " " + agent.Lastname + " | Sibra Durango",
+		RefreshInterval: CalendarRefresh,
+		Events:          make([]*ics.Event, 0, len(requests)),
+	}
+	for _, req := range requests {
+		cal.Events = append(cal.Events, Event(req))
+	}
+
+	return cal
+}
+
+// ClientCalendar is the invite to the visit sent to the client, without
+// the notes for the agent
+func ClientCalendar(req *db.Request) *ics.Calendar {
+	event := Event(req)
+	event.Summary = "Visita con Sibra Durango"
+	if req.PropertyAddress != "" {
+		event.Summary += ": " + req.PropertyAddress
+	}
+	event.Description = ""
+	if req.AgentName != "" {
+		event.Description = "Asesor: " + req.AgentName
+	}
+	if maps := MapsURL(req); maps != "" {
+		event.Description = strings.TrimSpace(event.Description + "\nMapa: " + maps)
+	}
+
+	return &ics.Calendar{
+		Name:   "Visita | Sibra Durango",
+		Events: []*ics.Event{event},
+	}
+}
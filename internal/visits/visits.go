@@ -0,0 +1,190 @@
+// Package visits computes the open visit slots of the agents from their
+// weekly availability, days off and booked visits
+package visits
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/vladwithcode/sibra-site/internal/db"
+)
+
+const (
+	// SlotDuration is the length of a visit, slots start every SlotDuration
+	// from the start of each availability range
+	SlotDuration = time.Hour
+	// MinNotice is how long before a slot it can no longer be booked
+	MinNotice = 2 * time.Hour
+	// BookingDays is how many days ahead the slots are offered
+	BookingDays = 14
+
+	// SlotLayout is the format of the slots sent by the forms
+	SlotLayout = "2006-01-02 15:04"
+)
+
+var ErrSlotNotOpen = errors.New("the slot is not open")
+
+// Location is the time zone of the agents, the dates of the forms and the
+// availability are in it
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Mexico_City")
+	if err != nil {
+		// Mexico City has no DST since 2022
+		return time.FixedZone("CST", -6*60*60)
+	}
+
+	return loc
+}
+
+var weekdayNames = [...]string{"Domingo", "Lunes", "Martes", "Miércoles", "Jueves", "Viernes", "Sábado"}
+
+func WeekdayName(d time.Weekday) string {
+	return weekdayNames[d]
+}
+
+// Weekdays are the days of the week starting on monday, as they are listed
+var Weekdays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+// ParseSlot reads a slot sent by a form, in Location
+func ParseSlot(s string) (time.Time, error) {
+	return time.ParseInLocation(SlotLayout, s, Location)
+}
+
+// ParseMinute reads an HH:MM time as minutes from midnight
+func ParseMinute(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Day are the open slots of a date
+type Day struct {
+	Date  time.Time
+	Slots []time.Time
+}
+
+// Label is the weekday and date, e.g. Lunes 20/10
+func (d *Day) Label() string {
+	return WeekdayName(d.Date.Weekday()) + " " + d.Date.Format("02/01")
+}
+
+// OpenSlots returns the slots of the agent that can be booked from now
+// for the next days, grouped by date. Days without open slots are left
+// out.
+func OpenSlots(ctx context.Context, agentId string, now time.Time, days int) ([]*Day, error) {
+	availability, err := db.FindAgentAvailability(ctx, agentId)
+	if err != nil {
+		return nil, err
+	}
+	if len(availability) == 0 {
+		return []*Day{}, nil
+	}
+
+	now = now.In(Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, Location)
+	end := today.AddDate(0, 0, days)
+
+	blocked, err := db.FindBlockedDates(ctx, agentId, today)
+	if err != nil {
+		return nil, err
+	}
+	blockedDates := map[string]bool{}
+	for _, b := range blocked {
+		blockedDates[b.Date.Format(time.DateOnly)] = true
+	}
+
+	visits, err := db.FindAgentVisits(ctx, agentId, today.Add(-SlotDuration), end.Add(SlotDuration))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Day{}
+	for date := today; date.Before(end); date = date.AddDate(0, 0, 1) {
+		if blockedDates[date.Format(time.DateOnly)] {
+			continue
+		}
+
+		day := &Day{Date: date}
+		for _, a := range availability {
+			if a.Weekday != date.Weekday() {
+				continue
+			}
+
+			slotMinutes := int(SlotDuration / time.Minute)
+			for m := a.StartMinute; m+slotMinutes <= a.EndMinute; m += slotMinutes {
+				slot := time.Date(date.Year(), date.Month(), date.Day(), m/60, m%60, 0, 0, Location)
+				if slot.Before(now.Add(MinNotice)) || overlaps(slot, visits) {
+					continue
+				}
+				day.Slots = append(day.Slots, slot)
+			}
+		}
+
+		if len(day.Slots) == 0 {
+			continue
+		}
+
+		slices.SortFunc(day.Slots, func(a, b time.Time) int { return a.Compare(b) })
+		day.Slots = slices.CompactFunc(day.Slots, func(a, b time.Time) bool { return a.Equal(b) })
+		result = append(result, day)
+	}
+
+	return result, nil
+}
+
+func overlaps(slot time.Time, visits []time.Time) bool {
+	for _, v := range visits {
+		diff := v.Sub(slot)
+		if diff > -SlotDuration && diff < SlotDuration {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckSlot returns ErrSlotNotOpen unless the slot is one of the open
+// slots of the agent
+func CheckSlot(ctx context.Context, agentId string, slot time.Time, now time.Time) error {
+	days, err := OpenSlots(ctx, agentId, now, BookingDays)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		for _, s := range day.Slots {
+			if s.Equal(slot) {
+				return nil
+			}
+		}
+	}
+
+	return ErrSlotNotOpen
+}
+
+// CheckAgentSlot returns ErrSlotNotOpen unless the slot is in the
+// availability of the agent, out of their days off and apart from their
+// visits. Unlike CheckSlot, it doesn't matter how soon the slot is, it is
+// used to hand over the visits already booked.
+func CheckAgentSlot(ctx context.Context, agentId string, slot time.Time) error {
+	return CheckSlot(ctx, agentId, slot, slot.Add(-MinNotice))
+}
@@ -0,0 +1,33 @@
+package visits
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverlaps(t *testing.T) {
+	slot := time.Date(2026, time.October, 20, 10, 0, 0, 0, Location)
+
+	tests := []struct {
+		name   string
+		visits []time.Time
+		want   bool
+	}{
+		{"no visits", nil, false},
+		{"same start", []time.Time{slot}, true},
+		{"starts during the slot", []time.Time{slot.Add(30 * time.Minute)}, true},
+		{"ends during the slot", []time.Time{slot.Add(-30 * time.Minute)}, true},
+		// A visit that ends when the slot starts, or starts when it ends,
+		// leaves the slot open
+		{"ends at the start", []time.Time{slot.Add(-SlotDuration)}, false},
+		{"starts at the end", []time.Time{slot.Add(SlotDuration)}, false},
+		{"one of many", []time.Time{slot.Add(-3 * time.Hour), slot.Add(59 * time.Minute), slot.Add(5 * time.Hour)}, true},
+		// The same instant in another time zone is the same visit
+		{"other zone", []time.Time{slot.UTC()}, true},
+	}
+	for _, tt := range tests {
+		if got := overlaps(slot, tt.visits); got != tt.want {
+			t.Errorf("%s: overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
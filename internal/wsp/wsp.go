@@ -9,6 +9,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+
+	"github.com/vladwithcode/sibra-site/internal/phone"
 )
 
 type TemplateVar map[string]any
@@ -39,6 +41,7 @@ var (
 	ErrPayloadInvalid      = errors.New("request payload is invalid")
 	ErrRequestFailed       = errors.New("request to facebook's api failed")
 	ErrRequestCreateFailed = errors.New("failed to create http.Request object")
+	ErrInvalidPhone        = errors.New("phone number is invalid")
 )
 
 const (
@@ -52,6 +55,23 @@ const (
 	baseUrl = "https://graph.facebook.com/%s/%v/messages"
 )
 
+// Enabled tells if the Cloud API is set, the messages are skipped when it
+// is not
+func Enabled() bool {
+	return os.Getenv(EnvVarPhoneNumberId) != "" && os.Getenv(EnvVarAccessToken) != ""
+}
+
+// toPhone returns the number in the form the API expects, E.164 without
+// the plus sign, whatever form it was stored in
+func toPhone(phoneNumber string) (string, error) {
+	n, err := phone.Parse(phoneNumber)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPhone, phoneNumber)
+	}
+
+	return n.WhatsApp(), nil
+}
+
 func postCloudAPIMessage(requestPayload any) error {
 	phoneNumberId := os.Getenv(EnvVarPhoneNumberId)
 	fbAccessToken := os.Getenv(EnvVarAccessToken)
@@ -96,6 +116,11 @@ func postCloudAPIMessage(requestPayload any) error {
 }
 
 func SendTemplateMessage(phoneNumber string, data TemplateData) error {
+	to, err := toPhone(phoneNumber)
+	if err != nil {
+		return err
+	}
+
 	var reqPayload struct {
 		MessagingProduct string          `json:"messaging_product"`
 		MessageType      string          `json:"type"`
@@ -125,7 +150,7 @@ func SendTemplateMessage(phoneNumber string, data TemplateData) error {
 
 	reqPayload.MessageType = "template"
 	reqPayload.MessagingProduct = "whatsapp"
-	reqPayload.ToPhone = phoneNumber
+	reqPayload.ToPhone = to
 	reqPayload.Template = templatePayload{
 		Name: data.TemplateName,
 		Language: struct {
@@ -136,3 +161,33 @@ func SendTemplateMessage(phoneNumber string, data TemplateData) error {
 
 	return postCloudAPIMessage(reqPayload)
 }
+
+type DocumentData struct {
+	// Public URL the document is downloaded from
+	Link     string `json:"link"`
+	Filename string `json:"filename,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+// SendDocumentMessage sends a file to the phone. Unlike the templates, it
+// is only delivered within 24 hours of the last message from the phone.
+func SendDocumentMessage(phoneNumber string, data DocumentData) error {
+	to, err := toPhone(phoneNumber)
+	if err != nil {
+		return err
+	}
+
+	var reqPayload struct {
+		MessagingProduct string       `json:"messaging_product"`
+		MessageType      string       `json:"type"`
+		ToPhone          string       `json:"to"`
+		Document         DocumentData `json:"document"`
+	}
+
+	reqPayload.MessageType = "document"
+	reqPayload.MessagingProduct = "whatsapp"
+	reqPayload.ToPhone = to
+	reqPayload.Document = data
+
+	return postCloudAPIMessage(reqPayload)
+}
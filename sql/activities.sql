@@ -0,0 +1,31 @@
+-- Notes and the calls and conversations the agents log on the requests
+CREATE TABLE IF NOT EXISTS request_activities (
+    id uuid PRIMARY KEY,
+    request uuid NOT NULL,
+    author uuid,
+    kind varchar(16) NOT NULL,
+    body text NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    FOREIGN KEY (request) REFERENCES requests ON DELETE CASCADE,
+    FOREIGN KEY (author) REFERENCES users ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS request_activities_request_idx ON request_activities (request, created_at DESC);
+
+-- Follow-ups of the requests, each one is for a single agent
+CREATE TABLE IF NOT EXISTS tasks (
+    id uuid PRIMARY KEY,
+    request uuid NOT NULL,
+    agent uuid NOT NULL,
+    title varchar(255) NOT NULL,
+    due_date timestamp with time zone NOT NULL,
+    done_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    FOREIGN KEY (request) REFERENCES requests ON DELETE CASCADE,
+    FOREIGN KEY (agent) REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS tasks_agent_idx ON tasks (agent, due_date) WHERE done_at IS NULL;
+CREATE INDEX IF NOT EXISTS tasks_request_idx ON tasks (request, due_date);
@@ -0,0 +1,27 @@
+-- Weekly hours in which the agents take visits, in minutes from midnight
+-- of America/Mexico_City
+CREATE TABLE IF NOT EXISTS agent_availability (
+    id uuid PRIMARY KEY,
+    agent uuid NOT NULL,
+    weekday smallint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_minute smallint NOT NULL CHECK (start_minute BETWEEN 0 AND 1440),
+    end_minute smallint NOT NULL CHECK (end_minute BETWEEN 0 AND 1440),
+
+    CHECK (start_minute < end_minute),
+    FOREIGN KEY (agent) REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS agent_availability_agent_idx ON agent_availability (agent, weekday);
+
+-- Days off of the agents, no visits are offered on them
+CREATE TABLE IF NOT EXISTS agent_blocked_dates (
+    id uuid PRIMARY KEY,
+    agent uuid NOT NULL,
+    date date NOT NULL,
+    reason varchar(256) NOT NULL DEFAULT '',
+
+    UNIQUE (agent, date),
+    FOREIGN KEY (agent) REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS requests_agent_visits_idx ON requests (agent, scheduled_date) WHERE type = 'cita';
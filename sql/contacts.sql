@@ -0,0 +1,80 @@
+-- The clients behind the requests, the requests with the same phone are
+-- linked to the same contact
+CREATE TABLE IF NOT EXISTS contacts (
+    id uuid PRIMARY KEY,
+    name varchar(255) NOT NULL DEFAULT '',
+    phone varchar(32) UNIQUE,
+    email varchar(255) UNIQUE,
+    -- Phones and emails of the contacts merged into this one
+    other_phones varchar(32)[] NOT NULL DEFAULT '{}',
+    other_emails varchar(255)[] NOT NULL DEFAULT '{}',
+    created_at timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone DEFAULT NOW()
+);
+
+-- Phones are compared by their 10 digits, without the 52 and 521 prefixes
+CREATE OR REPLACE FUNCTION normalize_contact_phone(phone text) RETURNS text AS $$
+    SELECT CASE
+        WHEN d ~ '^521\d{10}$' THEN substr(d, 4)
+        WHEN d ~ '^52\d{10}$' THEN substr(d, 3)
+        ELSE NULLIF(d, '')
+    END
+    FROM (SELECT regexp_replace(phone, '\D', '', 'g') AS d) p
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Returns the contact with the phone, or else with the email, creating it
+-- with new_id when there is none. Emails are compared lowercased.
+CREATE OR REPLACE FUNCTION request_contact(new_id uuid, phone text, email text, name text) RETURNS uuid AS $$
+DECLARE
+    norm_phone text := normalize_contact_phone(phone);
+    norm_email text := NULLIF(lower(trim(email)), '');
+    contact_id uuid;
+BEGIN
+    SELECT c.id INTO contact_id FROM contacts c
+    WHERE c.phone = norm_phone OR norm_phone = ANY(c.other_phones)
+    LIMIT 1;
+    IF contact_id IS NULL AND norm_email IS NOT NULL THEN
+        SELECT c.id INTO contact_id FROM contacts c
+        WHERE c.email = norm_email OR norm_email = ANY(c.other_emails)
+        LIMIT 1;
+    END IF;
+    IF contact_id IS NOT NULL THEN
+        RETURN contact_id;
+    END IF;
+
+    INSERT INTO contacts (id, name, phone, email)
+    VALUES (new_id, COALESCE(name, ''), norm_phone, norm_email)
+    ON CONFLICT DO NOTHING
+    RETURNING id INTO contact_id;
+
+    -- Another request created it at the same time
+    IF contact_id IS NULL THEN
+        SELECT c.id INTO contact_id FROM contacts c
+        WHERE c.phone = norm_phone OR c.email = norm_email
+        LIMIT 1;
+    END IF;
+
+    RETURN contact_id;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS contact uuid REFERENCES contacts ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS requests_contact_idx ON requests (contact, date DESC);
+
+-- Contacts of the requests sent before the contacts existed, the
+-- quarantined ones get theirs when they are released
+INSERT INTO contacts (id, name, phone, created_at, updated_at)
+SELECT
+    gen_random_uuid(),
+    (array_agg(name ORDER BY date DESC))[1],
+    normalize_contact_phone(phone),
+    min(date),
+    max(date)
+FROM requests
+WHERE contact IS NULL AND status <> 'spam' AND normalize_contact_phone(phone) IS NOT NULL
+GROUP BY normalize_contact_phone(phone)
+ON CONFLICT (phone) DO NOTHING;
+
+UPDATE requests r SET contact = c.id
+FROM contacts c
+WHERE r.contact IS NULL AND r.status <> 'spam' AND c.phone = normalize_contact_phone(r.phone);
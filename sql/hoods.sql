@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS hoods (
+    id uuid PRIMARY KEY,
+    name varchar(128) UNIQUE NOT NULL,
+    boundaries GEOMETRY(MultiPolygon, 4326),
+    -- Simplified boundaries, used to draw the hood on maps
+    display_boundaries GEOMETRY(MultiPolygon, 4326),
+    -- Price in effect, the full history lives in hood_prices
+    sq_meter_price NUMERIC(12,4) DEFAULT CAST(0.0 as NUMERIC),
+    location GEOMETRY(Point, 4326),
+    lon DOUBLE PRECISION,
+    lat DOUBLE PRECISION
+);
+
+-- The hoods created before the boundaries were managed
+ALTER TABLE hoods ADD COLUMN IF NOT EXISTS display_boundaries GEOMETRY(MultiPolygon, 4326);
+
+CREATE INDEX IF NOT EXISTS hoods_boundaries_idx ON hoods USING GIST (boundaries);
+
+CREATE TABLE IF NOT EXISTS hood_prices (
+    id uuid PRIMARY KEY,
+    hood uuid NOT NULL,
+    sq_meter_price NUMERIC(12,4) NOT NULL,
+    effective_date date NOT NULL,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    UNIQUE (hood, effective_date),
+    FOREIGN KEY (hood) REFERENCES hoods ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+-- Monthly market snapshots. Dimensions set to '*' hold the aggregate of
+-- every value of that dimension (e.g. hood = '*' is the whole city).
+CREATE TABLE IF NOT EXISTS market_snapshots (
+    id uuid PRIMARY KEY,
+    period date NOT NULL,
+    hood varchar(128) NOT NULL DEFAULT '*',
+    city varchar(128) NOT NULL DEFAULT '*',
+    contract varchar(16) NOT NULL DEFAULT '*',
+    property_type varchar(128) NOT NULL DEFAULT '*',
+    active_count int NOT NULL DEFAULT 0,
+    median_price NUMERIC(12,2),
+    median_sq_meter_price NUMERIC(12,2),
+    new_listing_count int NOT NULL DEFAULT 0,
+    median_days_on_market int,
+    sold_count int NOT NULL DEFAULT 0,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    UNIQUE (period, hood, city, contract, property_type)
+);
@@ -0,0 +1,10 @@
+-- Channels each user gets the notifications of an event by, the users
+-- without a row for an event get it by every channel
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id uuid NOT NULL,
+    event varchar(32) NOT NULL,
+    channels varchar(16)[] NOT NULL DEFAULT '{}',
+
+    PRIMARY KEY (user_id, event),
+    FOREIGN KEY (user_id) REFERENCES users ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+CREATE TABLE IF NOT EXISTS pois (
+    id uuid PRIMARY KEY,
+    name varchar(256) NOT NULL DEFAULT '',
+    category varchar(32) NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    lon DOUBLE PRECISION NOT NULL,
+    earth_coords point NOT NULL,
+    source varchar(128) NOT NULL DEFAULT '',
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    UNIQUE (category, name, lat, lon)
+);
+
+CREATE INDEX IF NOT EXISTS pois_category_idx ON pois (category);
+CREATE INDEX IF NOT EXISTS pois_earth_coords_idx ON pois USING GIST (earth_coords);
@@ -27,8 +27,15 @@ CREATE TABLE IF NOT EXISTS properties (
     agent uuid NOT NULL,
     slug varchar(512),
     features jsonb,
+    sold_at timestamp with time zone,
+    img_variants jsonb DEFAULT '{}',
+    watermark bool NOT NULL DEFAULT false,
 
     -- features          map[string]any,
 
     FOREIGN KEY (agent) REFERENCES users
 );
+
+ALTER TABLE properties ADD COLUMN IF NOT EXISTS sold_at timestamp with time zone;
+ALTER TABLE properties ADD COLUMN IF NOT EXISTS img_variants jsonb DEFAULT '{}';
+ALTER TABLE properties ADD COLUMN IF NOT EXISTS watermark bool NOT NULL DEFAULT false;
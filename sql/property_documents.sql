@@ -0,0 +1,19 @@
+-- The files are kept in the private store, public documents are served
+-- through the app
+CREATE TABLE IF NOT EXISTS property_documents (
+    id uuid PRIMARY KEY,
+    property_id uuid NOT NULL,
+    kind varchar(32) NOT NULL,
+    title varchar(256) NOT NULL DEFAULT '',
+    file_name varchar(256) NOT NULL,
+    content_type varchar(128) NOT NULL,
+    size bigint NOT NULL DEFAULT 0,
+    public bool NOT NULL DEFAULT false,
+    uploaded_by uuid,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    FOREIGN KEY (property_id) REFERENCES properties ON DELETE CASCADE,
+    FOREIGN KEY (uploaded_by) REFERENCES users ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS property_documents_property_idx ON property_documents (property_id, kind);
@@ -0,0 +1,36 @@
+-- The imgs and main_img columns of properties mirror this table, ordered
+-- by position, so the listings don't need to join it
+CREATE TABLE IF NOT EXISTS property_images (
+    id uuid PRIMARY KEY,
+    property_id uuid NOT NULL,
+    file_name varchar(256) NOT NULL,
+    position int NOT NULL DEFAULT 0,
+    is_main bool NOT NULL DEFAULT false,
+    caption varchar(256) NOT NULL DEFAULT '',
+    alt_text varchar(256) NOT NULL DEFAULT '',
+    room varchar(32) NOT NULL DEFAULT '',
+    -- Perceptual hash (dHash) of the original, to detect duplicated photos
+    phash bigint,
+    created_at timestamp with time zone DEFAULT NOW(),
+
+    UNIQUE (property_id, file_name),
+    FOREIGN KEY (property_id) REFERENCES properties ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS property_images_property_idx ON property_images (property_id, position);
+ALTER TABLE property_images ADD COLUMN IF NOT EXISTS phash bigint;
+
+CREATE UNIQUE INDEX IF NOT EXISTS property_images_main_idx ON property_images (property_id) WHERE is_main;
+
+-- Backfill from the columns used before the table existed
+INSERT INTO property_images (id, property_id, file_name, position, is_main)
+SELECT gen_random_uuid(), p.id, i.file_name, i.position - 1, false
+FROM properties p, unnest(p.imgs) WITH ORDINALITY AS i(file_name, position)
+WHERE i.file_name <> ''
+ON CONFLICT (property_id, file_name) DO NOTHING;
+
+INSERT INTO property_images (id, property_id, file_name, position, is_main)
+SELECT gen_random_uuid(), p.id, p.main_img, 0, true
+FROM properties p
+WHERE p.main_img <> ''
+ON CONFLICT (property_id, file_name) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Hits of the rate limited keys (e.g. the IP of a form), shared by every
+-- instance of the server. The window of a key starts with its first hit
+-- and the count starts over once it expires.
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key varchar(320) PRIMARY KEY,
+    count integer NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_expires_at_idx ON rate_limits (expires_at);
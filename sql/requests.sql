@@ -0,0 +1,78 @@
+-- Info and visit requests sent from the contact forms of the site
+CREATE TABLE IF NOT EXISTS requests (
+    id uuid PRIMARY KEY,
+    type varchar(16) NOT NULL,
+    phone varchar(32) NOT NULL,
+    name varchar(255) NOT NULL,
+    status varchar(32) NOT NULL DEFAULT 'pendiente',
+    scheduled_date timestamp with time zone,
+    agent uuid,
+    property uuid,
+    wsp_sent bool NOT NULL DEFAULT false,
+    -- When the current agent was assigned and every agent assigned so far,
+    -- unanswered requests are reassigned to an agent that has not had them
+    assigned_at timestamp with time zone,
+    tried_agents uuid[] NOT NULL DEFAULT '{}',
+    date timestamp with time zone DEFAULT NOW(),
+    updated_at timestamp with time zone DEFAULT NOW(),
+
+    FOREIGN KEY (agent) REFERENCES users ON DELETE SET NULL,
+    FOREIGN KEY (property) REFERENCES properties ON DELETE SET NULL
+);
+
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone DEFAULT NOW();
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS assigned_at timestamp with time zone;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS tried_agents uuid[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS requests_date_idx ON requests (date DESC);
+CREATE INDEX IF NOT EXISTS requests_status_idx ON requests (status, date DESC);
+CREATE INDEX IF NOT EXISTS requests_agent_idx ON requests (agent, assigned_at);
+
+-- First time the agent answered the request, by moving it out of pending
+-- or logging an activity, and when the SLA reminder and escalation of the
+-- pending request were sent
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS first_response_at timestamp with time zone;
+
+-- The requests that exist when the SLA columns are added count as reminded
+-- and escalated, so the first check doesn't send the whole backlog at once
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'requests' AND column_name = 'sla_reminded_at'
+    ) THEN
+        ALTER TABLE requests ADD COLUMN sla_reminded_at timestamp with time zone;
+        ALTER TABLE requests ADD COLUMN sla_escalated_at timestamp with time zone;
+        UPDATE requests SET sla_reminded_at = NOW(), sla_escalated_at = NOW();
+    END IF;
+END $$;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS sla_escalated_at timestamp with time zone;
+
+UPDATE requests SET first_response_at = updated_at
+WHERE first_response_at IS NULL AND status <> 'pendiente';
+
+CREATE INDEX IF NOT EXISTS requests_pending_idx ON requests (date) WHERE status = 'pendiente';
+
+-- The phone is stored in its E.164 form (+526181234567) and shown in its
+-- display form (618 123 4567), `manage phones normalize` rewrites the rows
+-- stored as typed
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS phone_display varchar(32);
+
+-- Why the request was quarantined as spam (status 'spam'), NULL otherwise
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS spam_reason varchar(32);
+
+-- First visit of the client to the site, from the attribution cookie set
+-- on their first page view
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS utm_source varchar(128);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS utm_medium varchar(128);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS utm_campaign varchar(128);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS utm_term varchar(128);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS utm_content varchar(128);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS referrer varchar(512);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS landing_page varchar(512);
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS first_touch_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS requests_source_idx ON requests (utm_source, utm_campaign, date);
+
+-- Email of the client, optional, for the ones that prefer it to WhatsApp
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS email varchar(255);
@@ -12,3 +12,16 @@ CREATE TABLE IF NOT EXISTS users (
 	golden_boy bool DEFAULT false,
 	img varchar(255) DEFAULT ''
 );
+
+-- Inactive users keep their properties but are left out of the lead routing
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active bool NOT NULL DEFAULT true;
+
+-- Secret of the calendar feed of the visits of the agent
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token varchar(64) UNIQUE;
+
+-- The phone in its display form, the phone column has the E.164 form
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_display varchar(32);
+
+-- Hash of the token of the link to reset the password, and when it expires
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_token varchar(64) UNIQUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_expires_at timestamp with time zone;
@@ -0,0 +1,47 @@
+// Proof of work of the request forms. Before htmx sends a form with
+// data-pow-difficulty, it looks for a nonce so the SHA-256 of
+// "<form-token>:<nonce>" starts with that many zero bits, the server checks
+// it in spam.VerifyWork.
+(function () {
+    function leadingZeroBits(bytes) {
+        let zeros = 0;
+        for (const b of bytes) {
+            if (b !== 0) {
+                return zeros + Math.clz32(b) - 24;
+            }
+            zeros += 8;
+        }
+        return zeros;
+    }
+
+    async function solve(token, difficulty) {
+        const encoder = new TextEncoder();
+        for (let nonce = 0; ; nonce++) {
+            const digest = await crypto.subtle.digest("SHA-256", encoder.encode(token + ":" + nonce));
+            if (leadingZeroBits(new Uint8Array(digest)) >= difficulty) {
+                return String(nonce);
+            }
+        }
+    }
+
+    document.addEventListener("htmx:confirm", function (evt) {
+        const form = evt.detail.elt;
+        const difficulty = Number(form.dataset && form.dataset.powDifficulty);
+        if (!difficulty || !form.elements["pow-nonce"] || form.elements["pow-nonce"].value) {
+            return;
+        }
+
+        evt.preventDefault();
+        const button = form.querySelector("button[type=submit]");
+        if (button) {
+            button.disabled = true;
+        }
+        solve(form.elements["form-token"].value, difficulty).then(function (nonce) {
+            form.elements["pow-nonce"].value = nonce;
+            if (button) {
+                button.disabled = false;
+            }
+            evt.detail.issueRequest();
+        });
+    });
+})();